@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// RequireAdmin lets only users with the admin role through. It must run
+// after JWTMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(claimsKey)
+		claims, _ := value.(jwt.MapClaims)
+		if IsAdmin(claims) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		c.Abort()
+	}
+}
+
+// IsAdmin reports whether the token was issued to an admin. The role is
+// taken from the user record at login, never from the username, so that
+// nobody becomes an admin by signing up under an admin's name.
+func IsAdmin(claims jwt.MapClaims) bool {
+	role, _ := claims["role"].(string)
+	return role == models.RoleAdmin
+}
+
+// SyncAdmins gives the admin role to the existing users named in admins
+// and takes it from every other user, so that the configuration decides
+// who is an admin. Tokens issued before keep their role until they expire.
+func SyncAdmins(ctx context.Context, users *mongo.Collection, admins []string) error {
+	if admins == nil {
+		admins = []string{}
+	}
+	_, err := users.UpdateMany(ctx,
+		bson.M{"username": bson.M{"$in": admins}, "role": bson.M{"$ne": models.RoleAdmin}},
+		bson.M{"$set": bson.M{"role": models.RoleAdmin}})
+	if err != nil {
+		return err
+	}
+	_, err = users.UpdateMany(ctx,
+		bson.M{"username": bson.M{"$nin": admins}, "role": models.RoleAdmin},
+		bson.M{"$set": bson.M{"role": models.RoleUser}})
+	return err
+}
+
+// IsReservedUsername reports whether username is one of admins, which
+// cannot be signed up: an admin account has to exist before it is listed.
+func IsReservedUsername(username string, admins []string) bool {
+	for _, admin := range admins {
+		if username == admin {
+			return true
+		}
+	}
+	return false
+}
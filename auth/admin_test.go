@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"admin role", jwt.MapClaims{"username": "alice", "role": models.RoleAdmin}, http.StatusOK},
+		{"user role", jwt.MapClaims{"username": "alice", "role": models.RoleUser}, http.StatusForbidden},
+		// Tokens issued before roles existed carry none.
+		{"no role", jwt.MapClaims{"username": "admin"}, http.StatusForbidden},
+		{"no claims", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set("Claims", tt.claims)
+				}
+				c.Next()
+			}, RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenCarriesRole(t *testing.T) {
+	for _, role := range []string{models.RoleUser, models.RoleAdmin} {
+		token, err := GenerateToken("alice", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := VerifyToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsAdmin(claims); got != (role == models.RoleAdmin) {
+			t.Errorf("IsAdmin of a %s token = %v", role, got)
+		}
+	}
+}
+
+func TestIsReservedUsername(t *testing.T) {
+	admins := []string{"root", "ops"}
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"root", true},
+		{"ops", true},
+		{"Root", false},
+		{"alice", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsReservedUsername(tt.username, admins); got != tt.want {
+			t.Errorf("IsReservedUsername(%q) = %v, want %v", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestSyncAdmins(t *testing.T) {
+	users := mongotest.Collection(mongotest.Connect(t), "users")
+	ctx := context.Background()
+	_, err := users.InsertMany(ctx, []interface{}{
+		models.User{Username: "alice", Role: models.RoleUser},
+		models.User{Username: "bob", Role: models.RoleAdmin},
+		models.User{Username: "carol"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SyncAdmins(ctx, users, []string{"alice", "dave"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cursor, err := users.Find(ctx, bson.M{"role": models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var admins []models.User
+	if err := cursor.All(ctx, &admins); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, admin := range admins {
+		names = append(names, admin.Username)
+	}
+	sort.Strings(names)
+	if len(names) != 1 || names[0] != "alice" {
+		t.Errorf("admins after sync = %v, want [alice]", names)
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"fmt"
 
 	"github.com/dgrijalva/jwt-go"
-)
 
-var vjwtSecret = []byte("mysecretkey")
+	"github.com/saifujnu/books-authors/config"
+)
 
 func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 
@@ -19,7 +19,7 @@ func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return vjwtSecret, nil
+		return []byte(config.Current.JWT.Secret), nil
 	})
 
 	if err != nil {
@@ -1,47 +1,108 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
-	"fmt"
-
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/requestlog"
+)
+
+// tracer records the token verification of each request.
+var tracer = otel.Tracer("github.com/saifujnu/books-authors/auth")
+
+// claimsKey and tokenErrorKey hold the outcome of verifying the request's
+// token in the gin context.
+const (
+	claimsKey     = "Claims"
+	tokenErrorKey = "TokenError"
+)
+
+var (
+	errNoToken     = errors.New("auth: no bearer token")
+	errTokenFormat = errors.New("auth: invalid token format")
 )
 
+// Claims returns the claims of the request's bearer token. The token is
+// verified on the first call only: the claims, or the error, are kept in
+// the context for the middleware that needs them next.
+func Claims(c *gin.Context) (jwt.MapClaims, error) {
+	if value, ok := c.Get(claimsKey); ok {
+		if claims, ok := value.(jwt.MapClaims); ok {
+			return claims, nil
+		}
+	}
+	if value, ok := c.Get(tokenErrorKey); ok {
+		return nil, value.(error)
+	}
+
+	claims, err := verifyRequest(c)
+	if err != nil {
+		c.Set(tokenErrorKey, err)
+		return nil, err
+	}
+	c.Set(claimsKey, claims)
+	return claims, nil
+}
+
+// verifyRequest verifies the token of the "Authorization: Bearer <token>"
+// header.
+func verifyRequest(c *gin.Context) (jwt.MapClaims, error) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, errNoToken
+	}
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return nil, errTokenFormat
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "auth.VerifyToken")
+	defer span.End()
+	claims, err := VerifyToken(tokenParts[1])
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if username, ok := claims["username"].(string); ok {
+		span.SetAttributes(attribute.String("enduser.id", username))
+	}
+	return claims, nil
+}
+
+// JWTMiddleware rejects requests without a valid token and makes the claims
+// available to the handlers under "Claims".
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract the JWT token from the request header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		claims, err := Claims(c)
+		switch {
+		case errors.Is(err, errNoToken):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
 			c.Abort()
 			return
-		}
-
-		// validate Token format: "Bearer tokenString"
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		case errors.Is(err, errTokenFormat):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
 			c.Abort()
 			return
-		}
-
-		tokenString := tokenParts[1]
-
-		// Verify the token and extract the user's claims
-		claims, err := VerifyToken(tokenString)
-		if err != nil {
+		case err != nil:
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		// Add the user's claims to the request context
-		c.Set("Claims", claims)
+		if username, ok := claims["username"].(string); ok {
+			requestlog.With(c, zap.String("user", username))
+		}
 
 		// Debugging: Log claims to console
-		fmt.Printf("Claims: %+v\n", claims)
+		requestlog.FromContext(c.Request.Context(), zap.NewNop()).Debug("Token verified", zap.Any("Claims", claims))
 
 		// Continue processing the request
 		c.Next()
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, err := GenerateToken("alice", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+		body   string
+	}{
+		{"valid token", "Bearer " + token, http.StatusOK, "alice"},
+		{"no header", "", http.StatusUnauthorized, `{"error":"Authorization header missing"}`},
+		{"not a bearer token", "Token " + token, http.StatusUnauthorized, `{"error":"Invalid token format"}`},
+		{"invalid token", "Bearer " + token + "x", http.StatusUnauthorized, `{"error":"Invalid token"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kept bool
+			router := gin.New()
+			router.GET("/books", func(c *gin.Context) {
+				// An earlier middleware, such as the rate limiter, reads the
+				// claims first; JWTMiddleware reuses the outcome.
+				Claims(c)
+				_, kept = c.Get(claimsKey)
+				if !kept {
+					_, kept = c.Get(tokenErrorKey)
+				}
+			}, JWTMiddleware(), func(c *gin.Context) {
+				claims, _ := Claims(c)
+				c.String(http.StatusOK, "%s", claims["username"])
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/books", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want || w.Body.String() != tt.body {
+				t.Errorf("got %d %s, want %d %s", w.Code, w.Body, tt.want, tt.body)
+			}
+			if !kept {
+				t.Error("outcome of the verification not kept in the context")
+			}
+		})
+	}
+}
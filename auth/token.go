@@ -6,22 +6,23 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-)
 
-var jwtSecret = []byte("mysecretkey")
+	"github.com/saifujnu/books-authors/config"
+)
 
 // Generate a token
-func GenerateToken(username string) (string, error) {
+func GenerateToken(username, role string) (string, error) {
 	//Define signed method
 	token := jwt.New(jwt.SigningMethodHS256)
 
 	//Define claims
 	claims := token.Claims.(jwt.MapClaims)
 	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix() // Token expires in 24 hours
+	claims["role"] = role
+	claims["exp"] = time.Now().Add(config.Current.JWT.TTL).Unix() // Token expires after the configured TTL
 
 	//Sign the JWT token with the secret key to generate the final JWT string
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString([]byte(config.Current.JWT.Secret))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	strongSecret := strings.Repeat("k", minJWTSecretLength)
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // part of the error; empty when valid
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"default secret in local env", func(c *Config) { c.Env = "local" }, ""},
+		{"default secret in production", func(c *Config) { c.Env = "production" }, "jwt.secret must be changed from the default"},
+		{"short secret in production", func(c *Config) { c.Env = "production"; c.JWT.Secret = "short-but-not-default" }, "jwt.secret must be at least 32 bytes"},
+		{"strong secret in production", func(c *Config) { c.Env = "production"; c.JWT.Secret = strongSecret }, ""},
+		{"empty secret", func(c *Config) { c.JWT.Secret = "" }, "jwt.secret must be set"},
+		{"bad http address", func(c *Config) { c.HTTP.Addr = "8080" }, `http.addr: "8080" is not a host:port address`},
+		{"negative write timeout", func(c *Config) { c.HTTP.WriteTimeout = -time.Second }, "http.write_timeout must not be negative"},
+		{"bad database uri", func(c *Config) { c.Database.URI = "postgres://db" }, "database.uri must start with mongodb://"},
+		{"bad database name", func(c *Config) { c.Database.Name = "a.b" }, "database.name"},
+		{"shared collection", func(c *Config) { c.Database.Collections.Authors = c.Database.Collections.Books }, "database.collections.books and database.collections.authors are both"},
+		{"wildcard origin with credentials", func(c *Config) {
+			c.CORS.AllowedOrigins = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, "cors.allowed_origins cannot be *"},
+		{"origin with a path", func(c *Config) { c.CORS.AllowedOrigins = []string{"https://example.com/app"} }, "is not an origin"},
+		{"bad log level", func(c *Config) { c.Log.Level = "loud" }, `log.level: "loud" is not a log level`},
+		{"file exporter without file", func(c *Config) { c.Tracing.Exporter = "file"; c.Tracing.File = "" }, "tracing.file must be set"},
+		{"bad route timeout", func(c *Config) { c.HTTP.RouteTimeouts = []string{"GET /books"} }, "http.route_timeouts"},
+		{"public url without scheme", func(c *Config) { c.HTTP.PublicURL = "books.example.com" }, "http.public_url"},
+		{"bad trusted proxy", func(c *Config) { c.HTTP.TrustedProxies = []string{"proxy"} }, "http.trusted_proxies"},
+		{"bad rate limit backend", func(c *Config) { c.RateLimit.Backend = "disk" }, "rate_limit.backend"},
+		{"redis backend without url", func(c *Config) { c.RateLimit.Backend = "redis"; c.RateLimit.RedisURL = "" }, "rate_limit.redis_url"},
+		{"bad rate policy", func(c *Config) { c.RateLimit.Policies = []string{"root=1/1m"} }, "rate_limit.policies"},
+		{"short api key", func(c *Config) { c.RateLimit.APIKeys = []string{"partner:short"} }, "rate_limit.api_keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Defaults()
+			tt.modify(&c)
+			err := c.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := Defaults()
+	c.HTTP.Addr = "nowhere"
+	c.JWT.TTL = 0
+	c.Log.Format = "xml"
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{"http.addr", "jwt.ttl", "log.format"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to mention %s", err, want)
+		}
+	}
+}
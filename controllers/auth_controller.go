@@ -1,52 +1,71 @@
 package controllers
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/saifujnu/books-authors/auth"
+	"github.com/saifujnu/books-authors/config"
 	"github.com/saifujnu/books-authors/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap" // Import the Zap logger package
 )
 
 // Signup handles user registration
 func (ac *AuthController) Signup(c *gin.Context) {
 	// Log the start of user registration.
-	ac.logger.Info("User registration started")
+	requestLogger(c, ac.logger).Info("User registration started")
 
 	// Parse the user data from the request
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		// Log the error and return a bad request response.
-		ac.logger.Error("Invalid JSON input", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid JSON input", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if user.Username == "" || user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password are required"})
+		return
+	}
+	// Admin names are kept for the accounts the operator lists, so that
+	// nobody can take one before its owner signs up.
+	if auth.IsReservedUsername(user.Username, config.Current.AdminUsers) {
+		requestLogger(c, ac.logger).Warn("Signup with a reserved username", zap.String("Username", user.Username))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Username is reserved"})
+		return
+	}
+
 	// Hash and salt the user's password
 	hashedPassword, err := HashPassword(user.Password)
 	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to hash password", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to hash password", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 	user.Password = hashedPassword
+	// The role is the server's to give, whatever the body said.
+	user.Role = models.RoleUser
 
 	// Store the user in the MongoDB collection
-	userCollection := ac.db.Database("book-authors").Collection("users")
-	_, err = userCollection.InsertOne(context.Background(), user)
+	userCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Users)
+	_, err = userCollection.InsertOne(c.Request.Context(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	}
 	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to create user", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to create user", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
 	// Log the successful user registration.
-	ac.logger.Debug("User registration completed")
+	requestLogger(c, ac.logger).Debug("User registration completed")
 
 	c.Status(http.StatusCreated)
 }
@@ -54,24 +73,24 @@ func (ac *AuthController) Signup(c *gin.Context) {
 // Login handles user login and JWT token generation
 func (ac *AuthController) Login(c *gin.Context) {
 	// Log the start of user login.
-	ac.logger.Info("User login started")
+	requestLogger(c, ac.logger).Info("User login started")
 
 	// Parse the user's login credentials from the request
 	var loginData models.User
 	if err := c.ShouldBindJSON(&loginData); err != nil {
 		// Log the error and return a bad request response.
-		ac.logger.Error("Invalid JSON input", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid JSON input", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Find the user by username
-	userCollection := ac.db.Database("book-authors").Collection("users")
+	userCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Users)
 	var user models.User
-	err := userCollection.FindOne(context.Background(), bson.M{"username": loginData.Username}).Decode(&user)
+	err := userCollection.FindOne(c.Request.Context(), bson.M{"username": loginData.Username}).Decode(&user)
 	if err != nil {
 		// Log the error and return an unauthorized response.
-		ac.logger.Error("Invalid credentials", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid credentials", zap.Error(err))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -79,22 +98,26 @@ func (ac *AuthController) Login(c *gin.Context) {
 	// Verify the credentials with the stored hashed password
 	if !VerifyPassword(loginData.Password, user.Password) {
 		// Log the error and return an unauthorized response.
-		ac.logger.Error("Invalid credentials")
+		requestLogger(c, ac.logger).Error("Invalid credentials")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Generate a JWT token using the GenerateToken function
-	token, err := auth.GenerateToken(user.Username)
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	token, err := auth.GenerateToken(user.Username, role)
 	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to generate token", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to generate token", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	// Log the successful user login.
-	ac.logger.Debug("User login completed", zap.String("Username", user.Username))
+	requestLogger(c, ac.logger).Debug("User login completed", zap.String("Username", user.Username))
 
 	// Respond with the token
 	c.JSON(http.StatusOK, gin.H{"token": token})
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestSignup(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client := mongotest.Connect(t)
+	if err := mongo.EnsureIndexes(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	config.Current.AdminUsers = []string{"root"}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/signup", NewAuthController(client, zap.NewNop()).Signup)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"new user", `{"username":"alice","password":"secret"}`, http.StatusCreated},
+		{"taken username", `{"username":"alice","password":"other"}`, http.StatusConflict},
+		{"reserved admin name", `{"username":"root","password":"secret"}`, http.StatusForbidden},
+		{"missing password", `{"username":"bob"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	var user models.User
+	users := mongotest.Collection(client, config.Current.Database.Collections.Users)
+	if err := users.FindOne(context.Background(), bson.M{"username": "alice"}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleUser {
+		t.Errorf("signed up user has role %q, want %q", user.Role, models.RoleUser)
+	}
+}
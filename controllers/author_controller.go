@@ -2,46 +2,65 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
 	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
 func (ac *AuthorController) CreateAuthor(c *gin.Context) {
 	// Log the start of author creation.
-	ac.logger.Debug("Creating author")
+	requestLogger(c, ac.logger).Debug("Creating author")
 
 	// Parse the JSON request body into an Author struct.
 	var author models.Author
 	if err := c.ShouldBindJSON(&author); err != nil {
 		// Log the error and return a bad request response.
-		ac.logger.Error("Invalid JSON input", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid JSON input", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Access the author collection in MongoDB.
-	authorCollection := ac.db.Database("book-authors").Collection("author")
+	// Every new document starts at version 1.
+	author.Version = 1
+	author.CreatedAt = models.Timestamp()
+	author.UpdatedAt = author.CreatedAt
 
-	// Insert the author document into the collection.
-	insertResult, err := authorCollection.InsertOne(context.Background(), author)
+	// Access the author collection in MongoDB.
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+
+	// Insert the author document into the collection and record the event
+	// in the same transaction.
+	err := ac.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		insertResult, err := authorCollection.InsertOne(tx, author)
+		if err != nil {
+			return err
+		}
+		author.ID = insertResult.InsertedID.(primitive.ObjectID)
+		return tx.Record(events.AuthorCreated, author.ID, author)
+	})
 	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to create author", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to create author", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create author"})
 		return
 	}
 
 	// Log the successful creation of the author.
-	ac.logger.Debug("Author created successfully", zap.String("AuthorID", insertResult.InsertedID.(primitive.ObjectID).Hex()))
+	requestLogger(c, ac.logger).Debug("Author created successfully", zap.String("AuthorID", author.ID.Hex()))
 
 	// Include the created author's information in the response.
+	c.Header("ETag", entityTag(author.ID, author.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Author created successfully",
 		"author":  author,
@@ -49,24 +68,24 @@ func (ac *AuthorController) CreateAuthor(c *gin.Context) {
 }
 
 func (ac *AuthorController) GetAuthors(c *gin.Context) {
-	ac.logger.Info("Fetching authors")
-	authorCollection := ac.db.Database("book-authors").Collection("author")
-	cursor, err := authorCollection.Find(context.Background(), bson.M{})
+	requestLogger(c, ac.logger).Info("Fetching authors")
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	cursor, err := authorCollection.Find(c.Request.Context(), bson.M{})
 	if err != nil {
-		ac.logger.Error("Failed to fetch authors", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to fetch authors", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
 		return
 	}
 	defer cursor.Close(context.Background())
 
 	var authors []models.Author
-	if err := cursor.All(context.Background(), &authors); err != nil {
-		ac.logger.Error("Failed to decode authors", zap.Error(err))
+	if err := cursor.All(c.Request.Context(), &authors); err != nil {
+		requestLogger(c, ac.logger).Error("Failed to decode authors", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode authors"})
 		return
 	}
 
-	ac.logger.Debug("Authors fetched successfully")
+	requestLogger(c, ac.logger).Debug("Authors fetched successfully")
 	c.JSON(http.StatusOK, authors)
 }
 
@@ -74,95 +93,159 @@ func (ac *AuthorController) GetAuthorByID(c *gin.Context) {
 	authorID := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(authorID)
 	if err != nil {
-		ac.logger.Error("Invalid author ID", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid author ID", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
 		return
 	}
 
-	ac.logger.Info("Fetching author by ID", zap.String("AuthorID", authorID))
-	authorCollection := ac.db.Database("book-authors").Collection("author")
+	requestLogger(c, ac.logger).Info("Fetching author by ID", zap.String("AuthorID", authorID))
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
 	var author models.Author
-	err = authorCollection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&author)
-	if err != nil {
-		ac.logger.Error("Author not found", zap.Error(err))
+	err = authorCollection.FindOne(c.Request.Context(), bson.M{"_id": objectID}).Decode(&author)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		requestLogger(c, ac.logger).Error("Author not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
 		return
 	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, ac.logger).Error("Failed to fetch author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+		return
+	}
 
-	ac.logger.Debug("Author fetched successfully", zap.String("AuthorID", authorID))
-	c.JSON(http.StatusOK, author)
-}
+	if format := representation(c); format != gin.MIMEJSON {
+		ac.serveAuthorLinkedData(c, author, format)
+		return
+	}
 
-func (ac *AuthorController) UpdateAuthor(c *gin.Context) {
-	authorID := c.Param("id")
-	authorObjID, err := primitive.ObjectIDFromHex(authorID)
-	if err != nil {
-		ac.logger.Error("Invalid author ID", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+	etag := entityTag(author.ID, author.Version)
+	c.Header("ETag", etag)
+	if notModified(c, etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
-	// Log the start of updating an author.
-	ac.logger.Debug("Updating author", zap.String("AuthorID", authorID))
+	requestLogger(c, ac.logger).Debug("Author fetched successfully", zap.String("AuthorID", authorID))
+	c.JSON(http.StatusOK, author)
+}
 
-	var existingAuthor models.Author
-	authorCollection := ac.db.Database("book-authors").Collection("author")
-	err = authorCollection.FindOne(context.Background(), bson.M{"_id": authorObjID}).Decode(&existingAuthor)
-	if err != nil {
-		// Log the error and return a not found response.
-		ac.logger.Error("Author not found", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+func (ac *AuthorController) UpdateAuthor(c *gin.Context) {
+	existingAuthor, ok := ac.findAuthorForWrite(c)
+	if !ok {
 		return
 	}
 
+	// PUT replaces the whole document, so every required field must be present.
 	var updateAuthor models.Author
 	if err := c.ShouldBindJSON(&updateAuthor); err != nil {
 		// Log the error and return a bad request response.
-		ac.logger.Error("Invalid JSON input", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid JSON input", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Define the update operations for individual fields
-	updateFields := bson.M{}
+	ac.replaceAuthor(c, existingAuthor, updateAuthor)
+}
 
-	if updateAuthor.FirstName != "" {
-		updateFields["firstName"] = updateAuthor.FirstName
-	} else {
-		updateFields["firstName"] = existingAuthor.FirstName
+func (ac *AuthorController) PatchAuthor(c *gin.Context) {
+	existingAuthor, ok := ac.findAuthorForWrite(c)
+	if !ok {
+		return
 	}
 
-	if updateAuthor.LastName != "" {
-		updateFields["lastName"] = updateAuthor.LastName
-	} else {
-		updateFields["lastName"] = existingAuthor.LastName
+	var patchedAuthor models.Author
+	if err := applyPatch(c, existingAuthor, &patchedAuthor); err != nil {
+		// Log the error and return the status matching the failure.
+		requestLogger(c, ac.logger).Error("Failed to patch author", zap.Error(err))
+		abortWithPatchError(c, err)
+		return
 	}
 
-	// Define the update filter
-	filter := bson.M{"_id": authorObjID}
+	ac.replaceAuthor(c, existingAuthor, patchedAuthor)
+}
+
+// findAuthorForWrite loads the author addressed by the :id parameter and
+// checks the If-Match precondition. It writes the error response itself and
+// returns false when the write must not go ahead.
+func (ac *AuthorController) findAuthorForWrite(c *gin.Context) (models.Author, bool) {
+	var existingAuthor models.Author
 
-	// Perform the update and return the updated document
-	update := bson.M{"$set": updateFields}
-	updatedResult := authorCollection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
-	if updatedResult.Err() != nil {
+	authorID := c.Param("id")
+	authorObjID, err := primitive.ObjectIDFromHex(authorID)
+	if err != nil {
+		requestLogger(c, ac.logger).Error("Invalid author ID", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return existingAuthor, false
+	}
+
+	// Log the start of updating an author.
+	requestLogger(c, ac.logger).Debug("Updating author", zap.String("AuthorID", authorID))
+
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	err = authorCollection.FindOne(c.Request.Context(), bson.M{"_id": authorObjID}).Decode(&existingAuthor)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Log the error and return a not found response.
+		requestLogger(c, ac.logger).Error("Author not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+		return existingAuthor, false
+	}
+	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to update author", zap.Error(updatedResult.Err()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update author"})
-		return
+		requestLogger(c, ac.logger).Error("Failed to fetch author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+		return existingAuthor, false
+	}
+
+	// Reject the update when the client edited an outdated representation.
+	if preconditionFailed(c, entityTag(existingAuthor.ID, existingAuthor.Version)) {
+		requestLogger(c, ac.logger).Info("Author ETag mismatch", zap.String("AuthorID", authorID))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Author has been modified"})
+		return existingAuthor, false
 	}
 
+	return existingAuthor, true
+}
+
+// replaceAuthor stores author as the next version of existingAuthor and
+// responds with the stored document.
+func (ac *AuthorController) replaceAuthor(c *gin.Context, existingAuthor, author models.Author) {
+	// The ID, version and timestamps are owned by the server, whatever the body said.
+	author.ID = existingAuthor.ID
+	author.Version = existingAuthor.Version + 1
+	author.CreatedAt = models.CreationTime(existingAuthor.ID, existingAuthor.CreatedAt)
+	author.UpdatedAt = models.Timestamp()
+
+	// Only apply the update if nobody else changed the author since it was read
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	filter := models.Versioned(existingAuthor.ID, existingAuthor.Version)
+
+	// Perform the replacement and record the event in the same transaction
 	var updatedAuthor models.Author
-	if err := updatedResult.Decode(&updatedAuthor); err != nil {
+	err := ac.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		err := authorCollection.FindOneAndReplace(tx, filter, author, options.FindOneAndReplace().SetReturnDocument(options.After)).Decode(&updatedAuthor)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.AuthorUpdated, updatedAuthor.ID, updatedAuthor)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		requestLogger(c, ac.logger).Info("Author modified concurrently", zap.String("AuthorID", author.ID.Hex()))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Author has been modified"})
+		return
+	}
+	if err != nil {
 		// Log the error and return an internal server error response.
-		ac.logger.Error("Failed to decode updated author", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode updated author"})
+		requestLogger(c, ac.logger).Error("Failed to update author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update author"})
 		return
 	}
 
 	// Log the successful update of the author.
-	ac.logger.Debug("Author updated successfully", zap.String("AuthorID", authorID))
+	requestLogger(c, ac.logger).Debug("Author updated successfully", zap.String("AuthorID", updatedAuthor.ID.Hex()))
 
 	// Return the updated document in the response
+	c.Header("ETag", entityTag(updatedAuthor.ID, updatedAuthor.Version))
 	c.JSON(http.StatusOK, updatedAuthor)
 }
 
@@ -170,21 +253,59 @@ func (ac *AuthorController) DeleteAuthor(c *gin.Context) {
 	authorID := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(authorID)
 	if err != nil {
-		ac.logger.Error("Invalid author ID", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Invalid author ID", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
 		return
 	}
 
-	ac.logger.Info("Deleting author", zap.String("AuthorID", authorID))
-	authorCollection := ac.db.Database("book-authors").Collection("author")
-	_, err = authorCollection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	requestLogger(c, ac.logger).Info("Deleting author", zap.String("AuthorID", authorID))
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	filter := bson.M{"_id": objectID}
+
+	// A conditional delete only removes the version the client has seen.
+	if c.GetHeader("If-Match") != "" {
+		var existingAuthor models.Author
+		err = authorCollection.FindOne(c.Request.Context(), filter).Decode(&existingAuthor)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			requestLogger(c, ac.logger).Error("Author not found", zap.Error(err))
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+		if err != nil {
+			// Log the error and return an internal server error response.
+			requestLogger(c, ac.logger).Error("Failed to fetch author", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+			return
+		}
+		if preconditionFailed(c, entityTag(existingAuthor.ID, existingAuthor.Version)) {
+			requestLogger(c, ac.logger).Info("Author ETag mismatch", zap.String("AuthorID", authorID))
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Author has been modified"})
+			return
+		}
+		filter = models.Versioned(existingAuthor.ID, existingAuthor.Version)
+	}
+
+	var deleteResult *mongo.DeleteResult
+	err = ac.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		var err error
+		if deleteResult, err = authorCollection.DeleteOne(tx, filter); err != nil || deleteResult.DeletedCount == 0 {
+			return err
+		}
+		return tx.Record(events.AuthorDeleted, objectID, nil)
+	})
 	if err != nil {
-		ac.logger.Error("Failed to delete author", zap.Error(err))
+		requestLogger(c, ac.logger).Error("Failed to delete author", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete author"})
 		return
 	}
+	if deleteResult.DeletedCount == 0 && filter["version"] != nil {
+		// The author changed between the read and the delete.
+		requestLogger(c, ac.logger).Info("Author modified concurrently", zap.String("AuthorID", authorID))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Author has been modified"})
+		return
+	}
 
-	ac.logger.Debug("Author deleted successfully", zap.String("AuthorID", authorID))
+	requestLogger(c, ac.logger).Debug("Author deleted successfully", zap.String("AuthorID", authorID))
 	c.Status(http.StatusNoContent)
 }
 
@@ -2,20 +2,25 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
 	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
 func (bc *BookController) CreateBook(c *gin.Context) {
 	// Log the start of book creation.
-	bc.logger.Debug("Creating book")
+	requestLogger(c, bc.logger).Debug("Creating book")
 
 	var book models.Book
 
@@ -23,24 +28,35 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&book); err != nil {
 		// Log the error and return a bad request response.
-		bc.logger.Error("Invalid JSON input", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Invalid JSON input", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	bookCollection := bc.db.Database("book-authors").Collection("book")
-	insertResult, err := bookCollection.InsertOne(context.Background(), book)
+	// Every new document starts at version 1.
+	book.Version = 1
+	book.CreatedAt = models.Timestamp()
+	book.UpdatedAt = book.CreatedAt
+
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	err := bc.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		if _, err := bookCollection.InsertOne(tx, book); err != nil {
+			return err
+		}
+		return tx.Record(events.BookCreated, book.ID, book)
+	})
 	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to create book", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to create book", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create book"})
 		return
 	}
 
 	// Log the successful creation of the book.
-	bc.logger.Debug("Book created successfully", zap.String("BookID", insertResult.InsertedID.(primitive.ObjectID).Hex()))
+	requestLogger(c, bc.logger).Debug("Book created successfully", zap.String("BookID", book.ID.Hex()))
 
 	// Include the created book's information in the response.
+	c.Header("ETag", entityTag(book.ID, book.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Book created successfully",
 		"book":    book,
@@ -49,28 +65,28 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 
 func (bc *BookController) GetBooks(c *gin.Context) {
 	// Log the start of fetching books.
-	bc.logger.Debug("Fetching books")
+	requestLogger(c, bc.logger).Debug("Fetching books")
 
-	bookCollection := bc.db.Database("book-authors").Collection("book")
-	cursor, err := bookCollection.Find(context.Background(), bson.M{})
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	cursor, err := bookCollection.Find(c.Request.Context(), bson.M{})
 	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to fetch books", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to fetch books", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
 		return
 	}
 	defer cursor.Close(context.Background())
 
 	var books []models.Book
-	if err := cursor.All(context.Background(), &books); err != nil {
+	if err := cursor.All(c.Request.Context(), &books); err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to decode books", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to decode books", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode books"})
 		return
 	}
 
 	// Log the successful fetching of books.
-	bc.logger.Debug("Books fetched successfully")
+	requestLogger(c, bc.logger).Debug("Books fetched successfully")
 	c.JSON(http.StatusOK, books)
 }
 
@@ -79,93 +95,165 @@ func (bc *BookController) GetBookByID(c *gin.Context) {
 	bookObjID, err := primitive.ObjectIDFromHex(bookID)
 	if err != nil {
 		// Log the error and return a bad request response.
-		bc.logger.Error("Invalid book ID", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Invalid book ID", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 		return
 	}
 
 	// Log the start of fetching a book by ID.
-	bc.logger.Info("Fetching book by ID", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Info("Fetching book by ID", zap.String("BookID", bookID))
 
-	bookCollection := bc.db.Database("book-authors").Collection("book")
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
 	var book models.Book
-	err = bookCollection.FindOne(context.Background(), bson.M{"_id": bookObjID}).Decode(&book)
-	if err != nil {
+	err = bookCollection.FindOne(c.Request.Context(), bson.M{"_id": bookObjID}).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
 		// Log the error and return a not found response.
-		bc.logger.Error("Book not found", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Book not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
 		return
 	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, bc.logger).Error("Failed to fetch book", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch book"})
+		return
+	}
+
+	if format := representation(c); format != gin.MIMEJSON {
+		bc.serveBookLinkedData(c, book, format)
+		return
+	}
+
+	etag := entityTag(book.ID, book.Version)
+	c.Header("ETag", etag)
+	if notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 
 	// Log the successful fetching of the book.
-	bc.logger.Debug("Book fetched successfully", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Debug("Book fetched successfully", zap.String("BookID", bookID))
 	c.JSON(http.StatusOK, book)
 }
 
 func (bc *BookController) UpdateBook(c *gin.Context) {
+	existingBook, ok := bc.findBookForWrite(c)
+	if !ok {
+		return
+	}
+
+	// PUT replaces the whole document, so every required field must be present.
+	var updateBook models.Book
+	if err := c.ShouldBindJSON(&updateBook); err != nil {
+		// Log the error and return a bad request response.
+		requestLogger(c, bc.logger).Error("Invalid JSON input", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bc.replaceBook(c, existingBook, updateBook)
+}
+
+func (bc *BookController) PatchBook(c *gin.Context) {
+	existingBook, ok := bc.findBookForWrite(c)
+	if !ok {
+		return
+	}
+
+	var patchedBook models.Book
+	if err := applyPatch(c, existingBook, &patchedBook); err != nil {
+		// Log the error and return the status matching the failure.
+		requestLogger(c, bc.logger).Error("Failed to patch book", zap.Error(err))
+		abortWithPatchError(c, err)
+		return
+	}
+
+	bc.replaceBook(c, existingBook, patchedBook)
+}
+
+// findBookForWrite loads the book addressed by the :id parameter and checks
+// the If-Match precondition. It writes the error response itself and returns
+// false when the write must not go ahead.
+func (bc *BookController) findBookForWrite(c *gin.Context) (models.Book, bool) {
+	var existingBook models.Book
+
 	bookID := c.Param("id")
 	bookObjID, err := primitive.ObjectIDFromHex(bookID)
 	if err != nil {
 		// Log the error and return a bad request response.
-		bc.logger.Error("Invalid book ID", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Invalid book ID", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
-		return
+		return existingBook, false
 	}
 
 	// Log the start of updating a book.
-	bc.logger.Debug("Updating book", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Debug("Updating book", zap.String("BookID", bookID))
 
-	var existingBook models.Book
-	bookCollection := bc.db.Database("book-authors").Collection("book")
-	err = bookCollection.FindOne(context.Background(), bson.M{"_id": bookObjID}).Decode(&existingBook)
-	if err != nil {
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	err = bookCollection.FindOne(c.Request.Context(), bson.M{"_id": bookObjID}).Decode(&existingBook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
 		// Log the error and return a not found response.
-		bc.logger.Error("Book not found", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Book not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
-		return
+		return existingBook, false
 	}
-
-	var updateBook models.Book
-	if err := c.ShouldBindJSON(&updateBook); err != nil {
-		// Log the error and return a bad request response.
-		bc.logger.Error("Invalid JSON input", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, bc.logger).Error("Failed to fetch book", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch book"})
+		return existingBook, false
 	}
 
-	// Preserve the existing authorId if not provided in the update
-	emptyObjectID := primitive.NilObjectID
-	if updateBook.AuthorID == emptyObjectID {
-		updateBook.AuthorID = existingBook.AuthorID
+	// Reject the update when the client edited an outdated representation.
+	if preconditionFailed(c, entityTag(existingBook.ID, existingBook.Version)) {
+		requestLogger(c, bc.logger).Info("Book ETag mismatch", zap.String("BookID", bookID))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+		return existingBook, false
 	}
 
-	// Define the update filter and update document
-	filter := bson.M{"_id": bookObjID}
-	update := bson.M{
-		"$set": updateBook,
-	}
+	return existingBook, true
+}
 
-	// Perform the update and return the updated document
-	updatedResult := bookCollection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
-	if updatedResult.Err() != nil {
-		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to update book", zap.Error(updatedResult.Err()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
-		return
-	}
+// replaceBook stores book as the next version of existingBook and responds
+// with the stored document.
+func (bc *BookController) replaceBook(c *gin.Context, existingBook, book models.Book) {
+	// The ID, version and timestamps are owned by the server, whatever the body said.
+	book.ID = existingBook.ID
+	book.Version = existingBook.Version + 1
+	book.CreatedAt = models.CreationTime(existingBook.ID, existingBook.CreatedAt)
+	book.UpdatedAt = models.Timestamp()
 
+	// Only apply the update if nobody else changed the book since it was read
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	filter := models.Versioned(existingBook.ID, existingBook.Version)
+
+	// Perform the replacement and record the event in the same transaction
 	var updatedBook models.Book
-	if err := updatedResult.Decode(&updatedBook); err != nil {
+	err := bc.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		err := bookCollection.FindOneAndReplace(tx, filter, book, options.FindOneAndReplace().SetReturnDocument(options.After)).Decode(&updatedBook)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.BookUpdated, updatedBook.ID, updatedBook)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Log the conflict and return a precondition failed response.
+		requestLogger(c, bc.logger).Info("Book modified concurrently", zap.String("BookID", book.ID.Hex()))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+		return
+	}
+	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to decode updated book", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode updated book"})
+		requestLogger(c, bc.logger).Error("Failed to update book", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
 		return
 	}
 
 	// Log the successful update of the book.
-	bc.logger.Debug("Book updated successfully", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Debug("Book updated successfully", zap.String("BookID", updatedBook.ID.Hex()))
 
 	// Return the updated document in the response
+	c.Header("ETag", entityTag(updatedBook.ID, updatedBook.Version))
 	c.JSON(http.StatusOK, updatedBook)
 }
 
@@ -174,77 +262,100 @@ func (bc *BookController) DeleteBook(c *gin.Context) {
 	bookObjID, err := primitive.ObjectIDFromHex(bookID)
 	if err != nil {
 		// Log the error and return a bad request response.
-		bc.logger.Error("Invalid book ID", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Invalid book ID", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 		return
 	}
 
 	// Log the start of deleting a book.
-	bc.logger.Info("Deleting book", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Info("Deleting book", zap.String("BookID", bookID))
 
-	bookCollection := bc.db.Database("book-authors").Collection("book")
-	_, err = bookCollection.DeleteOne(context.Background(), bson.M{"_id": bookObjID})
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	filter := bson.M{"_id": bookObjID}
+
+	// A conditional delete only removes the version the client has seen.
+	if c.GetHeader("If-Match") != "" {
+		var existingBook models.Book
+		err = bookCollection.FindOne(c.Request.Context(), filter).Decode(&existingBook)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Log the error and return a not found response.
+			requestLogger(c, bc.logger).Error("Book not found", zap.Error(err))
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+		if err != nil {
+			// Log the error and return an internal server error response.
+			requestLogger(c, bc.logger).Error("Failed to fetch book", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch book"})
+			return
+		}
+		if preconditionFailed(c, entityTag(existingBook.ID, existingBook.Version)) {
+			requestLogger(c, bc.logger).Info("Book ETag mismatch", zap.String("BookID", bookID))
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+			return
+		}
+		filter = models.Versioned(existingBook.ID, existingBook.Version)
+	}
+
+	var deleteResult *mongo.DeleteResult
+	err = bc.outbox.Write(c.Request.Context(), func(tx *outbox.Tx) error {
+		var err error
+		if deleteResult, err = bookCollection.DeleteOne(tx, filter); err != nil || deleteResult.DeletedCount == 0 {
+			return err
+		}
+		return tx.Record(events.BookDeleted, bookObjID, nil)
+	})
 	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to delete book", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to delete book", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book"})
 		return
 	}
+	if deleteResult.DeletedCount == 0 && filter["version"] != nil {
+		// The book changed between the read and the delete.
+		requestLogger(c, bc.logger).Info("Book modified concurrently", zap.String("BookID", bookID))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+		return
+	}
 
 	// Log the successful deletion of the book.
-	bc.logger.Debug("Book deleted successfully", zap.String("BookID", bookID))
+	requestLogger(c, bc.logger).Debug("Book deleted successfully", zap.String("BookID", bookID))
 	c.Status(http.StatusNoContent)
 }
 
 // combine controller
 func (bc *BookController) GetAllBooksAndAuthors(c *gin.Context) {
-	bookCollection := bc.db.Database("book-authors").Collection("book")
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
 	//authorCollection := bc.db.Database("book-authors").Collection("author")
 
 	// Define the aggregation pipeline
-	pipeline := []bson.M{
-		{
-			"$lookup": bson.M{
-				"from":         "author",
-				"localField":   "authorId",
-				"foreignField": "_id",
-				"as":           "authorInfo",
-			},
+	pipeline := append(models.AuthorLookup(config.Current.Database.Collections.Authors), bson.M{
+		"$project": bson.M{
+			"_id":        1,
+			"title":      1,
+			"authorInfo": 1,
 		},
-		{
-			"$unwind": bson.M{
-				"path":                       "$authorInfo",
-				"preserveNullAndEmptyArrays": true,
-			},
-		},
-		{
-			"$project": bson.M{
-				"_id":        1,
-				"title":      1,
-				"authorInfo": 1,
-			},
-		},
-	}
+	})
 
-	cursor, err := bookCollection.Aggregate(context.Background(), pipeline)
+	cursor, err := bookCollection.Aggregate(c.Request.Context(), pipeline)
 	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to aggregate books and authors", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to aggregate books and authors", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate books and authors"})
 		return
 	}
 	defer cursor.Close(context.Background())
 
 	var combinedList []bson.M
-	if err := cursor.All(context.Background(), &combinedList); err != nil {
+	if err := cursor.All(c.Request.Context(), &combinedList); err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to decode books and authors", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to decode books and authors", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode books and authors"})
 		return
 	}
 
 	// Log the successful aggregation and response.
-	bc.logger.Debug("Books and authors aggregated successfully")
+	requestLogger(c, bc.logger).Debug("Books and authors aggregated successfully")
 	c.JSON(http.StatusOK, combinedList)
 }
 
@@ -252,37 +363,43 @@ func (bc *BookController) GetBooksByAuthorName(c *gin.Context) {
 	authorName := c.Param("authorName")
 
 	// Find the author by first name
-	authorCollection := bc.db.Database("book-authors").Collection("author")
+	authorCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
 	var author models.Author
 
-	err := authorCollection.FindOne(context.Background(), bson.M{"firstName": authorName}).Decode(&author)
-	if err != nil {
+	err := authorCollection.FindOne(c.Request.Context(), bson.M{"firstName": authorName}).Decode(&author)
+	if errors.Is(err, mongo.ErrNoDocuments) {
 		// Log the error and return a not found response.
-		bc.logger.Error("Author not found", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Author not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
 		return
 	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, bc.logger).Error("Failed to fetch author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+		return
+	}
 
 	// Find the books by author ID
-	bookCollection := bc.db.Database("book-authors").Collection("book")
-	cursor, err := bookCollection.Find(context.Background(), bson.M{"authorId": author.ID})
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	cursor, err := bookCollection.Find(c.Request.Context(), bson.M{"authorId": author.ID})
 	if err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to fetch books", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to fetch books", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
 		return
 	}
 	defer cursor.Close(context.Background())
 
 	var books []models.Book
-	if err := cursor.All(context.Background(), &books); err != nil {
+	if err := cursor.All(c.Request.Context(), &books); err != nil {
 		// Log the error and return an internal server error response.
-		bc.logger.Error("Failed to decode books", zap.Error(err))
+		requestLogger(c, bc.logger).Error("Failed to decode books", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode books"})
 		return
 	}
 
 	// Log the successful response.
-	bc.logger.Debug("Books fetched by author name successfully", zap.String("AuthorName", authorName))
+	requestLogger(c, bc.logger).Debug("Books fetched by author name successfully", zap.String("AuthorName", authorName))
 	c.JSON(http.StatusOK, books)
 }
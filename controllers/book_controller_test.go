@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/deadline"
+)
+
+func TestGetBookByIDLookupErrors(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	id := seedBooks(t, client, "Dune")[0]
+
+	tests := []struct {
+		name    string
+		id      string
+		timeout time.Duration
+		want    int
+	}{
+		{"found", id.Hex(), 0, http.StatusOK},
+		{"unknown book", primitive.NewObjectID().Hex(), 0, http.StatusNotFound},
+		// A lookup cut by the deadline is not a missing book.
+		{"deadline", id.Hex(), time.Nanosecond, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(deadline.Middleware(tt.timeout, nil))
+			router.GET("/books/:id", books.GetBookByID)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+tt.id, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+// maxBulkOperations caps the number of operations accepted in one request.
+const maxBulkOperations = 1000
+
+const (
+	bulkCreate = "create"
+	bulkUpdate = "update"
+	bulkDelete = "delete"
+)
+
+// bulkOperation is one entry of a bulk request body. Updates are full
+// replacements; Version optionally pins the version the client has seen.
+type bulkOperation struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	Version *int64          `json:"version,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// bulkItemResult reports the outcome of one bulkOperation.
+type bulkItemResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkDecoder decodes and validates the data of a create or update operation
+// and returns the document to store under id and version. createdAt is kept
+// from the stored document on updates; updatedAt is the time of the batch.
+type bulkDecoder func(data json.RawMessage, id primitive.ObjectID, version int64, createdAt, updatedAt time.Time) (interface{}, error)
+
+func decodeBulkBook(data json.RawMessage, id primitive.ObjectID, version int64, createdAt, updatedAt time.Time) (interface{}, error) {
+	var book models.Book
+	if err := json.Unmarshal(data, &book); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(&book); err != nil {
+		return nil, err
+	}
+	book.ID = id
+	book.Version = version
+	book.CreatedAt = createdAt
+	book.UpdatedAt = updatedAt
+	return book, nil
+}
+
+func decodeBulkAuthor(data json.RawMessage, id primitive.ObjectID, version int64, createdAt, updatedAt time.Time) (interface{}, error) {
+	var author models.Author
+	if err := json.Unmarshal(data, &author); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(&author); err != nil {
+		return nil, err
+	}
+	author.ID = id
+	author.Version = version
+	author.CreatedAt = createdAt
+	author.UpdatedAt = updatedAt
+	return author, nil
+}
+
+func (bc *BookController) BulkBooks(c *gin.Context) {
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	runBulk(c, bc.outbox, bookCollection, decodeBulkBook, bookEvents, requestLogger(c, bc.logger))
+}
+
+func (ac *AuthorController) BulkAuthors(c *gin.Context) {
+	authorCollection := ac.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	runBulk(c, ac.outbox, authorCollection, decodeBulkAuthor, authorEvents, requestLogger(c, ac.logger))
+}
+
+// errBulkConflict aborts an atomic batch when a document changed between the
+// version check and the write.
+var errBulkConflict = errors.New("a document was modified concurrently")
+
+// errNoTransactions refuses atomic batches on servers without transactions.
+var errNoTransactions = errors.New("atomic batches need a replica set")
+
+// errBulkRetry ends a transaction that a write error aborted, so that the
+// rest of the batch can run again without the failed operations.
+var errBulkRetry = errors.New("a write error aborted the transaction")
+
+// bulkPlan is the set of write models built from a request together with the
+// bookkeeping needed to map driver results back to request items.
+type bulkPlan struct {
+	models    []mongo.WriteModel
+	items     []int         // result index of every write model
+	documents []interface{} // stored document of every write model, nil for deletes
+	versions  []int64       // version every write model leaves stored, 0 for deletes
+	updatedAt time.Time     // updatedAt of every stored document
+	updates   int           // number of replacements
+	deletes   int           // number of deletions
+}
+
+// runBulk executes a bulk request against collection. Every operation is
+// checked up front. The valid ones run in a single unordered BulkWrite,
+// together with the events of those that succeeded; with atomic=true they
+// run in order in one transaction, together with all their events, and fail
+// as a whole.
+func runBulk(c *gin.Context, ob *outbox.Outbox, collection *mongo.Collection, decode bulkDecoder, types eventTypes, logger *zap.Logger) {
+	atomic := false
+	if raw := c.Query("atomic"); raw != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "atomic must be a boolean"})
+			return
+		}
+	}
+
+	if atomic && !ob.Transactional() {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errNoTransactions.Error() + "; send the batch without atomic=true"})
+		return
+	}
+
+	var operations []bulkOperation
+	if err := c.ShouldBindJSON(&operations); err != nil {
+		// Log the error and return a bad request response.
+		logger.Error("Invalid JSON input", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(operations) == 0 || len(operations) > maxBulkOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a bulk request must contain between 1 and %d operations", maxBulkOperations)})
+		return
+	}
+
+	logger.Debug("Running bulk operations", zap.String("Collection", collection.Name()), zap.Int("Count", len(operations)), zap.Bool("Atomic", atomic))
+
+	results := make([]bulkItemResult, len(operations))
+	plan, err := planBulk(c.Request.Context(), collection, operations, results, decode)
+	if err != nil {
+		// Log the error and return an internal server error response.
+		logger.Error("Failed to prepare bulk operations", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare bulk operations"})
+		return
+	}
+
+	if atomic && len(plan.items) != len(operations) {
+		// Nothing is written when any item of an atomic batch is invalid.
+		markPending(results, plan.items, http.StatusFailedDependency, "not executed: batch rejected")
+		c.JSON(http.StatusUnprocessableEntity, bulkResponse(results))
+		return
+	}
+	if len(plan.models) == 0 {
+		c.JSON(http.StatusOK, bulkResponse(results))
+		return
+	}
+
+	if !atomic {
+		runSeparateBulk(c.Request.Context(), ob, collection, plan, results, types, logger)
+		logger.Debug("Bulk operations completed", zap.String("Collection", collection.Name()))
+		c.JSON(http.StatusOK, bulkResponse(results))
+		return
+	}
+
+	// Atomic batches that failed are reported with the status of the failure.
+	status := http.StatusOK
+	var writeException mongo.BulkWriteException
+	switch err := runAtomicBulk(c.Request.Context(), ob, collection, plan, results, types); {
+	case err == nil:
+	case errors.As(err, &writeException):
+		for _, writeError := range writeException.WriteErrors {
+			item := plan.items[writeError.Index]
+			results[item].Status = writeErrorStatus(writeError)
+			results[item].Error = writeError.Message
+		}
+		status = http.StatusConflict
+		markPending(results, plan.items, http.StatusFailedDependency, "rolled back")
+	case errors.Is(err, errBulkConflict):
+		status = http.StatusConflict
+		markPending(results, plan.items, http.StatusPreconditionFailed, err.Error())
+	default:
+		// Log the error and fail every item that was sent to the database.
+		logger.Error("Failed to run bulk operations", zap.Error(err))
+		status = http.StatusInternalServerError
+		markPending(results, plan.items, http.StatusInternalServerError, "Failed to run bulk operations")
+	}
+
+	logger.Debug("Bulk operations completed", zap.String("Collection", collection.Name()))
+	c.JSON(status, bulkResponse(results))
+}
+
+// planBulk validates every operation, records failures in results and builds
+// the write models for the rest. Successful items are pre-filled with their
+// success status so that only failures need to be written afterwards.
+func planBulk(ctx context.Context, collection *mongo.Collection, operations []bulkOperation, results []bulkItemResult, decode bulkDecoder) (*bulkPlan, error) {
+	ids := make([]primitive.ObjectID, len(operations))
+	seen := make(map[primitive.ObjectID]bool)
+	var existingIDs []primitive.ObjectID
+
+	for i, op := range operations {
+		results[i] = bulkItemResult{Index: i, Op: op.Op, ID: op.ID}
+		switch op.Op {
+		case bulkCreate:
+			if op.ID != "" {
+				results[i].Status, results[i].Error = http.StatusBadRequest, "id is assigned by the server"
+			}
+		case bulkUpdate, bulkDelete:
+			id, err := primitive.ObjectIDFromHex(op.ID)
+			if err != nil {
+				results[i].Status, results[i].Error = http.StatusBadRequest, "invalid id"
+				continue
+			}
+			// Unordered bulk writes may run in any order, so an ID may only appear once.
+			if seen[id] {
+				results[i].Status, results[i].Error = http.StatusConflict, "id appears more than once in the batch"
+				continue
+			}
+			seen[id] = true
+			ids[i] = id
+			existingIDs = append(existingIDs, id)
+		default:
+			results[i].Status, results[i].Error = http.StatusBadRequest, "op must be create, update or delete"
+		}
+	}
+
+	stored, err := storedStates(ctx, collection, existingIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &bulkPlan{updatedAt: models.Timestamp()}
+	for i, op := range operations {
+		if results[i].Status != 0 {
+			continue
+		}
+
+		var (
+			model      mongo.WriteModel
+			document   interface{}
+			newVersion int64
+		)
+		switch op.Op {
+		case bulkCreate:
+			id := primitive.NewObjectID()
+			var err error
+			document, err = decode(op.Data, id, 1, plan.updatedAt, plan.updatedAt)
+			if err != nil {
+				results[i].Status, results[i].Error = http.StatusUnprocessableEntity, err.Error()
+				continue
+			}
+			results[i].ID = id.Hex()
+			results[i].Status = http.StatusCreated
+			model = mongo.NewInsertOneModel().SetDocument(document)
+			newVersion = 1
+		case bulkUpdate, bulkDelete:
+			id := ids[i]
+			state, ok := stored[id]
+			version := state.Version
+			if !ok {
+				results[i].Status, results[i].Error = http.StatusNotFound, "not found"
+				continue
+			}
+			if op.Version != nil && *op.Version != version {
+				results[i].Status, results[i].Error = http.StatusPreconditionFailed, "version mismatch"
+				continue
+			}
+			filter := models.Versioned(id, version)
+			if op.Op == bulkDelete {
+				results[i].Status = http.StatusNoContent
+				model = mongo.NewDeleteOneModel().SetFilter(filter)
+				plan.deletes++
+				break
+			}
+			var err error
+			document, err = decode(op.Data, id, version+1, models.CreationTime(id, state.CreatedAt), plan.updatedAt)
+			if err != nil {
+				results[i].Status, results[i].Error = http.StatusUnprocessableEntity, err.Error()
+				continue
+			}
+			results[i].Status = http.StatusOK
+			model = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(document)
+			newVersion = version + 1
+			plan.updates++
+		}
+
+		plan.models = append(plan.models, model)
+		plan.items = append(plan.items, i)
+		plan.documents = append(plan.documents, document)
+		plan.versions = append(plan.versions, newVersion)
+	}
+	return plan, nil
+}
+
+// storedState is the part of a stored document a bulk write needs to know.
+type storedState struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Version   int64              `bson:"version"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+// storedStates returns the stored state of every existing document in ids.
+func storedStates(ctx context.Context, collection *mongo.Collection, ids []primitive.ObjectID) (map[primitive.ObjectID]storedState, error) {
+	states := make(map[primitive.ObjectID]storedState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	projection := bson.M{"version": 1, "createdAt": 1, "updatedAt": 1}
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var state storedState
+		if err := cursor.Decode(&state); err != nil {
+			return nil, err
+		}
+		states[state.ID] = state
+	}
+	return states, cursor.Err()
+}
+
+// runSeparateBulk runs the planned operations in one unordered BulkWrite,
+// which records the events of those that took effect, and stores the outcome
+// in results. A failed operation does not hold up the others. In a
+// transaction a write error aborts the whole write, so it runs again without
+// the operations that failed.
+func runSeparateBulk(ctx context.Context, ob *outbox.Outbox, collection *mongo.Collection, plan *bulkPlan, results []bulkItemResult, types eventTypes, logger *zap.Logger) {
+	pending := make([]int, len(plan.models)) // write models still to run
+	for j := range pending {
+		pending[j] = j
+	}
+
+	for len(pending) > 0 {
+		var failed map[int]mongo.BulkWriteError
+		var lost map[int]bool
+		err := ob.Write(ctx, func(tx *outbox.Tx) error {
+			var err error
+			failed, lost, err = writeSeparateBulk(tx, ob.Transactional(), collection, plan, pending, results, types)
+			return err
+		})
+
+		for j, writeError := range failed {
+			item := plan.items[j]
+			results[item].Status, results[item].Error = writeErrorStatus(writeError), writeError.Message
+		}
+		switch {
+		case err == nil:
+			for j := range lost {
+				item := plan.items[j]
+				results[item].Status, results[item].Error = http.StatusPreconditionFailed, "modified concurrently"
+			}
+			return
+		case errors.Is(err, errBulkRetry):
+			remaining := pending[:0]
+			for _, j := range pending {
+				if _, ok := failed[j]; !ok {
+					remaining = append(remaining, j)
+				}
+			}
+			pending = remaining
+		default:
+			// Log the error and fail every item that was sent to the database.
+			logger.Error("Failed to run bulk operations", zap.Error(err))
+			for _, j := range pending {
+				if _, ok := failed[j]; !ok {
+					item := plan.items[j]
+					results[item].Status, results[item].Error = http.StatusInternalServerError, "Failed to run bulk operations"
+				}
+			}
+			return
+		}
+	}
+}
+
+// writeSeparateBulk writes the pending write models of plan and records the
+// events of those that took effect. It returns the write errors and the
+// updates and deletes that lost a race, keyed by write model.
+func writeSeparateBulk(tx *outbox.Tx, transactional bool, collection *mongo.Collection, plan *bulkPlan, pending []int, results []bulkItemResult, types eventTypes) (map[int]mongo.BulkWriteError, map[int]bool, error) {
+	batch := make([]mongo.WriteModel, len(pending))
+	for k, j := range pending {
+		batch[k] = plan.models[j]
+	}
+
+	failed := make(map[int]mongo.BulkWriteError)
+	result, err := collection.BulkWrite(tx, batch, options.BulkWrite().SetOrdered(false))
+	var writeException mongo.BulkWriteException
+	switch {
+	case err == nil:
+	case errors.As(err, &writeException) && len(writeException.WriteErrors) > 0 && writeException.WriteConcernError == nil:
+		for _, writeError := range writeException.WriteErrors {
+			failed[pending[writeError.Index]] = writeError
+		}
+		if transactional {
+			return failed, nil, errBulkRetry
+		}
+	default:
+		return nil, nil, err
+	}
+
+	lost, err := lostBulkWrites(tx, collection, plan, pending, failed, result, results)
+	if err != nil {
+		return failed, nil, err
+	}
+
+	for _, j := range pending {
+		if _, ok := failed[j]; ok || lost[j] {
+			continue
+		}
+		if err := recordBulkEvent(tx, plan, j, results[plan.items[j]], types); err != nil {
+			return failed, nil, err
+		}
+	}
+	return failed, lost, nil
+}
+
+// lostBulkWrites returns the pending updates and deletes whose version filter
+// matched nothing because another writer won the race. BulkWrite only
+// reports totals, so the stored documents are read when they fall short: an
+// update took effect if the document has its version and the batch's
+// updatedAt, a delete if the document is gone.
+func lostBulkWrites(ctx context.Context, collection *mongo.Collection, plan *bulkPlan, pending []int, failed map[int]mongo.BulkWriteError, result *mongo.BulkWriteResult, results []bulkItemResult) (map[int]bool, error) {
+	var written []int
+	var ids []primitive.ObjectID
+	updates, deletes := 0, 0
+	for _, j := range pending {
+		item := plan.items[j]
+		if _, ok := failed[j]; ok || results[item].Op == bulkCreate {
+			continue
+		}
+		if results[item].Op == bulkDelete {
+			deletes++
+		} else {
+			updates++
+		}
+		id, _ := primitive.ObjectIDFromHex(results[item].ID)
+		written = append(written, j)
+		ids = append(ids, id)
+	}
+	if result.MatchedCount == int64(updates) && result.DeletedCount == int64(deletes) {
+		return nil, nil
+	}
+
+	stored, err := storedStates(ctx, collection, ids)
+	if err != nil {
+		return nil, err
+	}
+	lost := make(map[int]bool)
+	for k, j := range written {
+		state, exists := stored[ids[k]]
+		if plan.versions[j] == 0 && exists ||
+			plan.versions[j] != 0 && (!exists || state.Version != plan.versions[j] || !state.UpdatedAt.Equal(plan.updatedAt)) {
+			lost[j] = true
+		}
+	}
+	return lost, nil
+}
+
+func runAtomicBulk(ctx context.Context, ob *outbox.Outbox, collection *mongo.Collection, plan *bulkPlan, results []bulkItemResult, types eventTypes) error {
+	return ob.Write(ctx, func(tx *outbox.Tx) error {
+		result, err := collection.BulkWrite(tx, plan.models, options.BulkWrite().SetOrdered(true))
+		if err != nil {
+			return err
+		}
+		// A version filter that matched nothing means another writer won the race.
+		if result.MatchedCount != int64(plan.updates) || result.DeletedCount != int64(plan.deletes) {
+			return errBulkConflict
+		}
+		return recordBulkEvents(tx, plan, results, types)
+	})
+}
+
+// recordBulkEvents records an event for every planned item that succeeded.
+func recordBulkEvents(tx *outbox.Tx, plan *bulkPlan, results []bulkItemResult, types eventTypes) error {
+	for j, item := range plan.items {
+		if err := recordBulkEvent(tx, plan, j, results[item], types); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordBulkEvent records the event of the j-th write model of plan, whose
+// item has result, unless the item failed.
+func recordBulkEvent(tx *outbox.Tx, plan *bulkPlan, j int, result bulkItemResult, types eventTypes) error {
+	if result.Status >= http.StatusBadRequest {
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(result.ID)
+	if err != nil {
+		return nil
+	}
+	switch result.Op {
+	case bulkCreate:
+		return tx.Record(types.created, id, plan.documents[j])
+	case bulkUpdate:
+		return tx.Record(types.updated, id, plan.documents[j])
+	case bulkDelete:
+		return tx.Record(types.deleted, id, nil)
+	}
+	return nil
+}
+
+// markPending overwrites the status of every planned item that has not
+// failed on its own.
+func markPending(results []bulkItemResult, items []int, status int, message string) {
+	for _, item := range items {
+		if results[item].Status < http.StatusBadRequest {
+			results[item].Status, results[item].Error = status, message
+		}
+	}
+}
+
+func writeErrorStatus(writeError mongo.BulkWriteError) int {
+	if mongo.IsDuplicateKeyError(writeError) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func bulkResponse(results []bulkItemResult) gin.H {
+	succeeded := 0
+	for _, result := range results {
+		if result.Status < http.StatusBadRequest {
+			succeeded++
+		}
+	}
+	return gin.H{
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	}
+}
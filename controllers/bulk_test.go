@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+type bulkResult struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []bulkItemResult `json:"results"`
+}
+
+func bulkStatuses(result bulkResult) []int {
+	statuses := make([]int, len(result.Results))
+	for i, item := range result.Results {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func TestBulkBooks(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	ids := seedBooks(t, client, "Dune", "Emma", "Ulysses")
+
+	body := fmt.Sprintf(`[
+		{"op": "create", "data": {"title": "Middlemarch"}},
+		{"op": "create", "data": {}},
+		{"op": "update", "id": %q, "version": 1, "data": {"title": "Dune Messiah"}},
+		{"op": "update", "id": %q, "version": 9, "data": {"title": "Persuasion"}},
+		{"op": "delete", "id": %q},
+		{"op": "delete", "id": %q},
+		{"op": "rename", "id": %q}
+	]`, ids[0].Hex(), ids[1].Hex(), ids[2].Hex(), primitive.NewObjectID().Hex(), ids[0].Hex())
+
+	w := serve(books.BulkBooks, http.MethodPost, "/books/bulk", "/books/bulk", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var result bulkResult
+	decode(t, w, &result)
+	want := []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusOK, http.StatusPreconditionFailed,
+		http.StatusNoContent, http.StatusNotFound, http.StatusBadRequest}
+	if got := bulkStatuses(result); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if result.Succeeded != 3 || result.Failed != 4 {
+		t.Errorf("succeeded %d and failed %d, want 3 and 4", result.Succeeded, result.Failed)
+	}
+
+	// Every operation that took effect, and only those, recorded its event.
+	recorded := map[string]events.Type{}
+	for _, event := range mongotest.RecordedEvents(t, client) {
+		recorded[event.Subject] = event.Type
+	}
+	wantEvents := map[string]events.Type{
+		result.Results[0].ID: events.BookCreated,
+		ids[0].Hex():         events.BookUpdated,
+		ids[2].Hex():         events.BookDeleted,
+	}
+	if fmt.Sprint(recorded) != fmt.Sprint(wantEvents) {
+		t.Errorf("recorded events %v, want %v", recorded, wantEvents)
+	}
+}
+
+func TestBulkBooksWriteErrors(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	ids := seedBooks(t, client, "Dune", "Emma")
+	_, err := mongotest.Collection(client, config.Current.Database.Collections.Books).Indexes().CreateOne(context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "title", Value: 1}}, Options: options.Index().SetUnique(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The create fails on the unique title without holding up the update.
+	body := fmt.Sprintf(`[
+		{"op": "create", "data": {"title": "Emma"}},
+		{"op": "update", "id": %q, "version": 1, "data": {"title": "Dune Messiah"}}
+	]`, ids[0].Hex())
+
+	w := serve(books.BulkBooks, http.MethodPost, "/books/bulk", "/books/bulk", body, nil)
+	var result bulkResult
+	decode(t, w, &result)
+	want := []int{http.StatusConflict, http.StatusOK}
+	if got := bulkStatuses(result); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v: %s", got, want, w.Body)
+	}
+	recorded := mongotest.RecordedEvents(t, client)
+	if len(recorded) != 1 || recorded[0].Type != events.BookUpdated || recorded[0].Subject != ids[0].Hex() {
+		t.Errorf("recorded events %+v, want the update of %s only", recorded, ids[0].Hex())
+	}
+}
+
+func TestBulkBooksLostRace(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, ob, _, _ := testCatalog(t)
+	ids := seedBooks(t, client, "Dune", "Emma", "Ulysses")
+	collection := mongotest.Collection(client, config.Current.Database.Collections.Books)
+
+	operations := []bulkOperation{
+		{Op: bulkUpdate, ID: ids[0].Hex(), Data: []byte(`{"title": "Dune Messiah"}`)},
+		{Op: bulkUpdate, ID: ids[1].Hex(), Data: []byte(`{"title": "Persuasion"}`)},
+		{Op: bulkDelete, ID: ids[2].Hex()},
+	}
+	results := make([]bulkItemResult, len(operations))
+	plan, err := planBulk(context.Background(), collection, operations, results, decodeBulkBook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another writer changes two of the books between the plan and the write.
+	for _, id := range []primitive.ObjectID{ids[1], ids[2]} {
+		if _, err := collection.UpdateByID(context.Background(), id, bson.M{"$inc": bson.M{"version": 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runSeparateBulk(context.Background(), ob, collection, plan, results, bookEvents, zap.NewNop())
+	want := []int{http.StatusOK, http.StatusPreconditionFailed, http.StatusPreconditionFailed}
+	if got := bulkStatuses(bulkResult{Results: results}); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	recorded := mongotest.RecordedEvents(t, client)
+	if len(recorded) != 1 || recorded[0].Subject != ids[0].Hex() {
+		t.Errorf("recorded events %+v, want the update of %s only", recorded, ids[0].Hex())
+	}
+}
+
+func TestBulkBooksAtomic(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, ob, books, _ := testCatalog(t)
+	ids := seedBooks(t, client, "Dune")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid batch", fmt.Sprintf(`[{"op": "create", "data": {"title": "Emma"}}, {"op": "update", "id": %q, "data": {"title": "Dune Messiah"}}]`, ids[0].Hex()), http.StatusOK},
+		{"invalid item", `[{"op": "create", "data": {"title": "Emma"}}, {"op": "create", "data": {}}]`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if !ob.Transactional() {
+				// Standalone servers cannot run atomic batches at all.
+				want = http.StatusNotImplemented
+			}
+			w := serve(books.BulkBooks, http.MethodPost, "/books/bulk", "/books/bulk?atomic=true", tt.body, nil)
+			if w.Code != want {
+				t.Errorf("status = %d, want %d: %s", w.Code, want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+)
+
+const (
+	// changesKeepAlive is how often an idle change stream sends a comment,
+	// so that proxies do not close the connection.
+	changesKeepAlive = 15 * time.Second
+	// changesRetry is the reconnect delay suggested to clients, in
+	// milliseconds.
+	changesRetry = "3000"
+	// changesWriteMargin is how long before the server's write timeout a
+	// stream is ended, so that it closes cleanly rather than being cut.
+	changesWriteMargin = 5 * time.Second
+)
+
+// StreamChanges streams book and author events as Server-Sent Events. Every
+// event carries its resume token as the SSE id, so a client that reconnects
+// with Last-Event-ID (or resume_after) continues where it left off. types
+// optionally narrows the stream to a comma separated list of event types.
+func (cc *ChangeController) StreamChanges(c *gin.Context) {
+	token := c.GetHeader("Last-Event-ID")
+	if token == "" {
+		token = c.Query("resume_after")
+	}
+
+	var wanted map[events.Type]bool
+	if raw := c.Query("types"); raw != "" {
+		wanted = make(map[events.Type]bool)
+		for _, t := range strings.Split(raw, ",") {
+			if !events.Valid(events.Type(t)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type " + t})
+				return
+			}
+			wanted[events.Type(t)] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	cursor, err := cc.changes.Watch(ctx, token)
+	switch {
+	case errors.Is(err, events.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resume token"})
+		return
+	case errors.Is(err, events.ErrHistoryLost):
+		// The client has to reload whatever it mirrors and start afresh.
+		c.JSON(http.StatusGone, gin.H{"error": "Changes after the resume token are no longer available"})
+		return
+	case err != nil:
+		requestLogger(c, cc.logger).Error("Failed to watch changes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch changes"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	requestLogger(c, cc.logger).Debug("Change stream opened", zap.Bool("Resumed", token != ""))
+
+	// The cursor blocks, so it is read in its own goroutine while this one
+	// also keeps the connection alive.
+	changes := make(chan events.Change)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			change, err := cursor.Next(ctx)
+			if err != nil {
+				failed <- err
+				return
+			}
+			if wanted != nil && !wanted[change.Event.Type] {
+				continue
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	// The reader must be gone before the cursor is closed.
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Send the headers right away; a block with only a retry field
+	// dispatches no event.
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteString("retry: " + changesRetry + "\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(changesKeepAlive)
+	defer keepAlive.Stop()
+
+	// The write timeout bounds the whole response, so the stream ends just
+	// before it and the client resumes on a new connection.
+	var expired <-chan time.Time
+	if timeout := config.Current.HTTP.WriteTimeout; timeout > 0 {
+		lifetime := timeout - changesWriteMargin
+		if lifetime <= 0 {
+			lifetime = timeout / 2
+		}
+		timer := time.NewTimer(lifetime)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case change := <-changes:
+			c.Render(-1, sse.Event{Id: change.Token, Event: string(change.Event.Type), Data: change.Event})
+			return true
+		case <-keepAlive.C:
+			_, err := w.Write([]byte(": keep-alive\n\n"))
+			return err == nil
+		case err := <-failed:
+			if ctx.Err() == nil {
+				// Let the client reconnect and resume after its last event.
+				requestLogger(c, cc.logger).Error("Change stream failed", zap.Error(err))
+			}
+			return false
+		case <-ctx.Done():
+			return false
+		case <-expired:
+			return false
+		case <-cc.stop:
+			return false
+		}
+	})
+}
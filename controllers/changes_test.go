@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+)
+
+func TestStreamChanges(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	gin.SetMode(gin.TestMode)
+	// Streams end 100ms in, half the write timeout, so the whole body can
+	// be read.
+	config.Current.HTTP.WriteTimeout = 200 * time.Millisecond
+
+	bus := events.NewBus(10)
+	first, err := bus.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for _, typ := range []events.Type{events.BookCreated, events.AuthorCreated, events.BookUpdated} {
+		event, err := events.New(typ, primitive.NewObjectID(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bus.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		change, err := first.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, change.Token)
+	}
+
+	router := gin.New()
+	router.GET("/changes", NewChangeController(zap.NewNop(), bus).StreamChanges)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		status int
+		want   []string // event types, in order
+	}{
+		{"from now on", "/changes", nil, http.StatusOK, nil},
+		{"resumed with Last-Event-ID", "/changes", http.Header{"Last-Event-Id": {tokens[0]}}, http.StatusOK, []string{"author.created", "book.updated"}},
+		{"resumed with resume_after", "/changes?resume_after=" + tokens[1], nil, http.StatusOK, []string{"book.updated"}},
+		{"filtered by type", "/changes?types=book.created,book.updated&resume_after=" + tokens[0], nil, http.StatusOK, []string{"book.updated"}},
+		{"unknown type", "/changes?types=book.read", nil, http.StatusBadRequest, nil},
+		{"invalid token", "/changes?resume_after=yesterday", nil, http.StatusBadRequest, nil},
+		{"lost history", "/changes?resume_after=" + primitive.NewObjectID().Hex() + "-1", nil, http.StatusGone, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.target, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("Content-Type = %s", got)
+			}
+			if !strings.HasPrefix(string(body), "retry: "+changesRetry+"\n\n") {
+				t.Errorf("stream does not start with the retry delay: %q", body)
+			}
+
+			var got []string
+			for _, line := range strings.Split(string(body), "\n") {
+				if strings.HasPrefix(line, "event:") {
+					got = append(got, strings.TrimSpace(strings.TrimPrefix(line, "event:")))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("events %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdownEndsStreams(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	gin.SetMode(gin.TestMode)
+	config.Current.HTTP.WriteTimeout = 0
+
+	changes := NewChangeController(zap.NewNop(), events.NewBus(10))
+	router := gin.New()
+	router.GET("/changes", changes.StreamChanges)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	ended := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		ended <- err
+	}()
+	changes.Shutdown()
+	changes.Shutdown() // a second call is harmless
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Errorf("stream ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after Shutdown")
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// entityTag builds the strong ETag of a document from its ID and version.
+func entityTag(id primitive.ObjectID, version int64) string {
+	return fmt.Sprintf("\"%s-%d\"", id.Hex(), version)
+}
+
+// etagListMatches reports whether a comma separated If-Match/If-None-Match
+// header value contains etag. Weak validators only match when weak is true.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed reports whether the request carries an If-Match header
+// that does not match the current ETag of the document.
+func preconditionFailed(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	return header != "" && !etagListMatches(header, etag, false)
+}
+
+// notModified reports whether the request carries an If-None-Match header
+// that matches the current ETag of the document. etag may be weak.
+func notModified(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+	return header != "" && etagListMatches(header, strings.TrimPrefix(etag, "W/"), true)
+}
+
+// variantTag derives the weak ETag of another representation of a document
+// from its strong ETag.
+func variantTag(etag, variant string) string {
+	return "W/" + strings.TrimSuffix(etag, "\"") + "-" + variant + "\""
+}
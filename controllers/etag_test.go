@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/config"
+)
+
+func TestEntityTag(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("64b7f0c2a1b2c3d4e5f60718")
+	if got, want := entityTag(id, 3), `"64b7f0c2a1b2c3d4e5f60718-3"`; got != want {
+		t.Errorf("entityTag = %s, want %s", got, want)
+	}
+	if got, want := variantTag(`"64b7f0c2a1b2c3d4e5f60718-3"`, "jsonld"), `W/"64b7f0c2a1b2c3d4e5f60718-3-jsonld"`; got != want {
+		t.Errorf("variantTag = %s, want %s", got, want)
+	}
+}
+
+func TestETagListMatches(t *testing.T) {
+	const etag = `"abc-1"`
+	tests := []struct {
+		name   string
+		header string
+		weak   bool
+		want   bool
+	}{
+		{"same tag", `"abc-1"`, false, true},
+		{"other tag", `"abc-2"`, false, false},
+		{"in a list", `"abc-2", "abc-1"`, false, true},
+		{"list without spaces", `"abc-2","abc-1"`, false, true},
+		{"any", `*`, false, true},
+		{"weak tag, strong comparison", `W/"abc-1"`, false, false},
+		{"weak tag, weak comparison", `W/"abc-1"`, true, true},
+		{"unquoted", `abc-1`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagListMatches(tt.header, etag, tt.weak); got != tt.want {
+				t.Errorf("etagListMatches(%q, weak %v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	id := seedBooks(t, client, "Dune")[0]
+	v1, v2 := entityTag(id, 1), entityTag(id, 2)
+	target := "/books/" + id.Hex()
+
+	// The steps run in order against the same book.
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		method  string
+		body    string
+		header  string
+		value   string
+		want    int
+		etag    string
+	}{
+		{"get", books.GetBookByID, http.MethodGet, "", "", "", http.StatusOK, v1},
+		{"get if none match", books.GetBookByID, http.MethodGet, "", "If-None-Match", v1, http.StatusNotModified, v1},
+		{"get if none match weak", books.GetBookByID, http.MethodGet, "", "If-None-Match", "W/" + v1, http.StatusNotModified, v1},
+		{"get if none match stale", books.GetBookByID, http.MethodGet, "", "If-None-Match", v2, http.StatusOK, v1},
+		{"put if match stale", books.UpdateBook, http.MethodPut, `{"title": "Emma"}`, "If-Match", v2, http.StatusPreconditionFailed, ""},
+		{"put if match weak", books.UpdateBook, http.MethodPut, `{"title": "Emma"}`, "If-Match", "W/" + v1, http.StatusPreconditionFailed, ""},
+		{"put if match", books.UpdateBook, http.MethodPut, `{"title": "Dune Messiah"}`, "If-Match", v1, http.StatusOK, v2},
+		{"patch if match stale", books.PatchBook, http.MethodPatch, `{"title": "Emma"}`, "If-Match", v1, http.StatusPreconditionFailed, ""},
+		{"delete if match stale", books.DeleteBook, http.MethodDelete, "", "If-Match", v1, http.StatusPreconditionFailed, ""},
+		{"delete if match", books.DeleteBook, http.MethodDelete, "", "If-Match", v2, http.StatusNoContent, ""},
+		{"get deleted", books.GetBookByID, http.MethodGet, "", "", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Content-Type": {"application/json"}}
+		if tt.header != "" {
+			header.Set(tt.header, tt.value)
+		}
+		w := serve(tt.handler, tt.method, "/books/:id", target, tt.body, header)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s: ETag = %s, want %s", tt.name, got, tt.etag)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package controllers
+
+import "github.com/saifujnu/books-authors/events"
+
+// eventTypes are the event types of one resource, for code shared by books
+// and authors.
+type eventTypes struct {
+	created, updated, deleted events.Type
+}
+
+var (
+	bookEvents   = eventTypes{events.BookCreated, events.BookUpdated, events.BookDeleted}
+	authorEvents = eventTypes{events.AuthorCreated, events.AuthorUpdated, events.AuthorDeleted}
+)
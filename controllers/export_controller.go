@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/export"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// exportBatchSize is the cursor batch size used while streaming an export,
+// and how many records are written between flushes.
+const exportBatchSize = 500
+
+// Export streams the catalog, or the subset selected by the authorId, title
+// and isbn query parameters, in the format given by ?format=.
+func (bc *BookController) Export(c *gin.Context) {
+	format, err := export.Lookup(strings.ToLower(c.DefaultQuery("format", "ndjson")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "formats": export.Names()})
+		return
+	}
+
+	filter, err := exportFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Log the start of the export.
+	requestLogger(c, bc.logger).Debug("Exporting books", zap.String("Format", format.Name))
+
+	pipeline := append([]bson.M{{"$match": filter}}, models.AuthorLookup(config.Current.Database.Collections.Authors)...)
+	bookCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	cursor, err := bookCollection.Aggregate(c.Request.Context(), pipeline, options.Aggregate().SetBatchSize(exportBatchSize))
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, bc.logger).Error("Failed to export books", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export books"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	c.Header("Content-Type", format.ContentType)
+	c.Header("Content-Disposition", `attachment; filename="catalog.`+format.Extension+`"`)
+	c.Status(http.StatusOK)
+
+	// From here on the status is sent; failures can only cut the stream short.
+	encoder := format.New(c.Writer)
+	if err := encoder.Begin(); err != nil {
+		requestLogger(c, bc.logger).Error("Failed to write export", zap.Error(err))
+		return
+	}
+
+	count := 0
+	for cursor.Next(c.Request.Context()) {
+		var record models.BookWithAuthor
+		if err := cursor.Decode(&record); err != nil {
+			requestLogger(c, bc.logger).Error("Failed to decode exported book", zap.Error(err))
+			return
+		}
+		if err := encoder.Encode(record); err != nil {
+			requestLogger(c, bc.logger).Error("Failed to write export", zap.Error(err))
+			return
+		}
+		count++
+		if count%exportBatchSize == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		requestLogger(c, bc.logger).Error("Failed to read exported books", zap.Error(err))
+		return
+	}
+	if err := encoder.End(); err != nil {
+		requestLogger(c, bc.logger).Error("Failed to write export", zap.Error(err))
+		return
+	}
+
+	// Log the successful export.
+	requestLogger(c, bc.logger).Debug("Books exported successfully", zap.String("Format", format.Name), zap.Int("Count", count))
+}
+
+// exportFilter builds the $match filter from the export query parameters.
+func exportFilter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+	if authorID := c.Query("authorId"); authorID != "" {
+		id, err := primitive.ObjectIDFromHex(authorID)
+		if err != nil {
+			return nil, err
+		}
+		filter["authorId"] = id
+	}
+	if title := c.Query("title"); title != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(title), Options: "i"}
+	}
+	if isbn := c.Query("isbn"); isbn != "" {
+		filter["isbn"] = isbn
+	}
+	return filter, nil
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/feeds"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// feedSize is the number of books in an Atom or RSS feed.
+const feedSize = 50
+
+// bookFeed is a feed of recently added or updated books before it is
+// rendered as Atom or RSS.
+type bookFeed struct {
+	id      string
+	title   string
+	path    string // path of the feed without the .atom/.rss extension
+	books   []models.BookWithAuthor
+	updated time.Time
+}
+
+// BooksAtom serves the Atom feed of the most recently added or updated books.
+func (fc *FeedController) BooksAtom(c *gin.Context) {
+	fc.serveBooks(c, fc.renderAtom)
+}
+
+// BooksRSS serves the RSS 2.0 feed of the most recently added or updated
+// books.
+func (fc *FeedController) BooksRSS(c *gin.Context) {
+	fc.serveBooks(c, fc.renderRSS)
+}
+
+// AuthorBooksAtom serves the Atom feed of one author's most recently added
+// or updated books.
+func (fc *FeedController) AuthorBooksAtom(c *gin.Context) {
+	fc.serveAuthorBooks(c, fc.renderAtom)
+}
+
+// AuthorBooksRSS serves the RSS 2.0 feed of one author's most recently added
+// or updated books.
+func (fc *FeedController) AuthorBooksRSS(c *gin.Context) {
+	fc.serveAuthorBooks(c, fc.renderRSS)
+}
+
+func (fc *FeedController) serveBooks(c *gin.Context, render func(*gin.Context, bookFeed)) {
+	books, err := fc.recentBooks(c.Request.Context(), bson.M{})
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to fetch books for feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		return
+	}
+
+	fc.serveFeed(c, bookFeed{
+		id:    "urn:books-authors:feed:books",
+		title: "Books and Authors: new and updated books",
+		path:  "/feeds/books",
+		books: books,
+	}, render)
+}
+
+func (fc *FeedController) serveAuthorBooks(c *gin.Context, render func(*gin.Context, bookFeed)) {
+	authorObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Invalid author ID", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+
+	authorCollection := fc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	var author models.Author
+	err = authorCollection.FindOne(c.Request.Context(), bson.M{"_id": authorObjID}).Decode(&author)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Log the error and return a not found response.
+		requestLogger(c, fc.logger).Error("Author not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+		return
+	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to fetch author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+		return
+	}
+
+	books, err := fc.recentBooks(c.Request.Context(), bson.M{"authorId": author.ID})
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to fetch books for feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		return
+	}
+
+	fc.serveFeed(c, bookFeed{
+		id:    "urn:books-authors:feed:author:" + author.ID.Hex(),
+		title: "Books by " + feeds.AuthorDisplayName(author),
+		path:  "/feeds/authors/" + author.ID.Hex() + "/books",
+		books: books,
+		// An author without books still has a stable Last-Modified.
+		updated: author.LastModified(),
+	}, render)
+}
+
+// serveFeed answers conditional requests for the feed and renders it
+// otherwise. The feed changes exactly when one of its books does, so
+// Last-Modified is the latest book change and the ETag is derived from the
+// IDs and versions of the books.
+func (fc *FeedController) serveFeed(c *gin.Context, feed bookFeed, render func(*gin.Context, bookFeed)) {
+	for _, book := range feed.books {
+		if updated := feeds.BookUpdated(book.Book); updated.After(feed.updated) {
+			feed.updated = updated
+		}
+	}
+	if feed.updated.IsZero() {
+		feed.updated = time.Unix(0, 0).UTC()
+	}
+
+	etag := feedTag(c.Request.URL.Path, feed.books)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", feed.updated.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "public, max-age=60")
+
+	if feedNotModified(c, etag, feed.updated) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	render(c, feed)
+}
+
+// recentBooks returns the most recently changed books matching filter, joined
+// with their authors. Books written before timestamps were recorded have no
+// updatedAt and come last, newest ID first.
+func (fc *FeedController) recentBooks(ctx context.Context, filter bson.M) ([]models.BookWithAuthor, error) {
+	bookCollection := fc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	pipeline := append([]bson.M{
+		{"$match": filter},
+		{"$sort": bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{"$limit": feedSize},
+	}, models.AuthorLookup(config.Current.Database.Collections.Authors)...)
+	cursor, err := bookCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var books []models.BookWithAuthor
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// feedTag builds the weak ETag of a feed. The path is included so that the
+// Atom and RSS representations get different tags.
+func feedTag(path string, books []models.BookWithAuthor) string {
+	hash := sha1.New()
+	fmt.Fprintln(hash, path)
+	for _, book := range books {
+		fmt.Fprintf(hash, "%s-%d\n", book.ID.Hex(), book.Version)
+		if book.Author != nil {
+			fmt.Fprintf(hash, "%s-%d\n", book.Author.ID.Hex(), book.Author.Version)
+		}
+	}
+	return "W/\"" + hex.EncodeToString(hash.Sum(nil)) + "\""
+}
+
+// feedNotModified evaluates If-None-Match and, when it is absent,
+// If-Modified-Since, as RFC 9110 orders them.
+func feedNotModified(c *gin.Context, etag string, updated time.Time) bool {
+	if c.GetHeader("If-None-Match") != "" {
+		return notModified(c, etag)
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !updated.Truncate(time.Second).After(since)
+}
+
+// baseURL returns the scheme and host clients reach the server at, as feeds
+// need absolute links: http.public_url, or else those of the request.
+// X-Forwarded-Proto is not read: feeds are cached publicly, so a link one
+// client made up would be served to everyone.
+func baseURL(c *gin.Context) string {
+	if public := config.Current.HTTP.PublicURL; public != "" {
+		return strings.TrimSuffix(public, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func (fc *FeedController) renderAtom(c *gin.Context, feed bookFeed) {
+	base := baseURL(c)
+
+	atom := feeds.NewFeed(feed.id, feed.title, feed.updated)
+	atom.Author = &feeds.Person{Name: "Books and Authors"}
+	atom.Links = []feeds.Link{
+		{Rel: feeds.RelSelf, Href: base + feed.path + ".atom", Type: feeds.AtomContentType},
+		{Rel: feeds.RelAlternate, Href: base + feed.path + ".rss", Type: feeds.RSSContentType},
+	}
+	for _, book := range feed.books {
+		links := []feeds.Link{
+			{Rel: feeds.RelAlternate, Href: base + "/books/" + book.ID.Hex(), Type: "application/json"},
+		}
+		authorHref := ""
+		if book.Author != nil {
+			authorHref = base + "/feeds/authors/" + book.Author.ID.Hex() + "/books.atom"
+		}
+		atom.Entries = append(atom.Entries, feeds.BookEntry(book, links, authorHref))
+	}
+
+	body, err := atom.Marshal()
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to render Atom feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render feed"})
+		return
+	}
+	c.Data(http.StatusOK, feeds.AtomContentType, body)
+}
+
+func (fc *FeedController) renderRSS(c *gin.Context, feed bookFeed) {
+	base := baseURL(c)
+
+	rss := feeds.NewRSS(feed.title, base+feed.path+".atom", feed.title, base+feed.path+".rss", feed.updated)
+	for _, book := range feed.books {
+		rss.Channel.Items = append(rss.Channel.Items, feeds.BookItem(book, base+"/books/"+book.ID.Hex()))
+	}
+
+	body, err := rss.Marshal()
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to render RSS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render feed"})
+		return
+	}
+	c.Data(http.StatusOK, feeds.RSSContentType, body)
+}
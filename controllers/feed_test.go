@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/feeds"
+	"github.com/saifujnu/books-authors/models"
+)
+
+func TestFeedTag(t *testing.T) {
+	book := models.BookWithAuthor{Book: models.Book{ID: primitive.NewObjectID(), Version: 1}}
+	updated := book
+	updated.Version = 2
+	withAuthor := book
+	withAuthor.Author = &models.Author{ID: primitive.NewObjectID(), Version: 1}
+
+	base := feedTag("/feeds/books.atom", []models.BookWithAuthor{book})
+	if !strings.HasPrefix(base, `W/"`) {
+		t.Errorf("feed tag %s is not weak", base)
+	}
+	tests := []struct {
+		name  string
+		path  string
+		books []models.BookWithAuthor
+	}{
+		{"other representation", "/feeds/books.rss", []models.BookWithAuthor{book}},
+		{"book updated", "/feeds/books.atom", []models.BookWithAuthor{updated}},
+		{"author joined", "/feeds/books.atom", []models.BookWithAuthor{withAuthor}},
+		{"no books", "/feeds/books.atom", nil},
+	}
+	for _, tt := range tests {
+		if got := feedTag(tt.path, tt.books); got == base {
+			t.Errorf("%s: feed tag unchanged", tt.name)
+		}
+	}
+}
+
+func TestServeFeed(t *testing.T) {
+	fc := NewFeedController(nil, zap.NewNop())
+	updated := time.Date(2024, 2, 1, 7, 30, 0, 0, time.UTC)
+	feed := bookFeed{
+		id:    "urn:books-authors:books",
+		title: "Recently added or updated books",
+		path:  "/feeds/books",
+		books: []models.BookWithAuthor{{Book: models.Book{ID: primitive.NewObjectID(), Title: "Dune", Version: 2, UpdatedAt: updated}}},
+	}
+	etag := feedTag("/feeds/books.atom", feed.books)
+	lastModified := updated.Format(http.TimeFormat)
+
+	tests := []struct {
+		name        string
+		render      func(*gin.Context, bookFeed)
+		target      string
+		header      http.Header
+		status      int
+		contentType string
+	}{
+		{"atom", fc.renderAtom, "/feeds/books.atom", nil, http.StatusOK, feeds.AtomContentType},
+		{"rss", fc.renderRSS, "/feeds/books.rss", nil, http.StatusOK, feeds.RSSContentType},
+		{"if none match", fc.renderAtom, "/feeds/books.atom", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, ""},
+		{"if none match other representation", fc.renderRSS, "/feeds/books.rss", http.Header{"If-None-Match": {etag}}, http.StatusOK, feeds.RSSContentType},
+		{"if modified since", fc.renderAtom, "/feeds/books.atom", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified, ""},
+		{"if modified since earlier", fc.renderAtom, "/feeds/books.atom", http.Header{"If-Modified-Since": {updated.Add(-time.Second).Format(http.TimeFormat)}}, http.StatusOK, feeds.AtomContentType},
+		{"if none match wins", fc.renderAtom, "/feeds/books.atom", http.Header{"If-None-Match": {`W/"stale"`}, "If-Modified-Since": {lastModified}}, http.StatusOK, feeds.AtomContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(c *gin.Context) { fc.serveFeed(c, feed, tt.render) }
+			w := serve(handler, http.MethodGet, tt.target, tt.target, "", tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Last-Modified"); got != lastModified {
+				t.Errorf("Last-Modified = %s, want %s", got, lastModified)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("no ETag")
+			}
+			if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %s, want %s", w.Header().Get("Content-Type"), tt.contentType)
+			}
+		})
+	}
+}
+
+func TestFeedLinks(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	fc := NewFeedController(nil, zap.NewNop())
+	feed := bookFeed{id: "urn:books-authors:books", title: "Books", path: "/feeds/books"}
+	handler := func(c *gin.Context) { fc.serveFeed(c, feed, fc.renderRSS) }
+	forwarded := http.Header{"X-Forwarded-Proto": {"https"}}
+
+	tests := []struct {
+		name      string
+		publicURL string
+		want      string
+	}{
+		// The forwarded scheme is ignored, as anyone can send it.
+		{"request", "", `href="http://example.com/feeds/books.rss"`},
+		{"public URL", "https://books.example.org/api/", `href="https://books.example.org/api/feeds/books.rss"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Current.HTTP.PublicURL = tt.publicURL
+			w := serve(handler, http.MethodGet, "/feeds/books.rss", "/feeds/books.rss", "", forwarded)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("self link not %s:\n%s", tt.want, w.Body)
+			}
+			// A feed without books is stamped with the epoch rather than the zero time.
+			if got, want := w.Header().Get("Last-Modified"), time.Unix(0, 0).UTC().Format(http.TimeFormat); got != want {
+				t.Errorf("Last-Modified = %s, want %s", got, want)
+			}
+		})
+	}
+}
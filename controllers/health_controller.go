@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/health"
+)
+
+// Healthz reports that the process is up and serving requests. It checks no
+// dependency, so that a database outage does not get the process restarted.
+func (hc *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readyz checks the dependencies and reports each one's status and latency.
+// It fails while a critical dependency is down and once shutdown has begun.
+func (hc *HealthController) Readyz(c *gin.Context) {
+	report := hc.health.Check(c.Request.Context())
+	if report.Status != health.StatusReady {
+		requestLogger(c, hc.logger).Warn("Service not ready", zap.String("Status", report.Status), zap.Any("Checks", report.Checks))
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/health"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var mongoErr error
+	checker := health.NewChecker(time.Second)
+	checker.Add("mongodb", true, func(context.Context) error { return mongoErr })
+	hc := NewHealthController(zap.NewNop(), checker)
+
+	// The steps run in order against the same checker.
+	tests := []struct {
+		name    string
+		before  func()
+		healthz int
+		readyz  int
+		status  string
+	}{
+		{"up", func() {}, http.StatusOK, http.StatusOK, health.StatusReady},
+		{"database down", func() { mongoErr = errors.New("no reachable servers") }, http.StatusOK, http.StatusServiceUnavailable, health.StatusNotReady},
+		{"database back", func() { mongoErr = nil }, http.StatusOK, http.StatusOK, health.StatusReady},
+		{"draining", checker.Drain, http.StatusOK, http.StatusServiceUnavailable, health.StatusDraining},
+	}
+	for _, tt := range tests {
+		tt.before()
+		if w := serve(hc.Healthz, http.MethodGet, "/healthz", "/healthz", "", nil); w.Code != tt.healthz {
+			t.Errorf("%s: /healthz status = %d, want %d", tt.name, w.Code, tt.healthz)
+		}
+		w := serve(hc.Readyz, http.MethodGet, "/readyz", "/readyz", "", nil)
+		if w.Code != tt.readyz {
+			t.Errorf("%s: /readyz status = %d, want %d", tt.name, w.Code, tt.readyz)
+		}
+		var report health.Report
+		decode(t, w, &report)
+		if report.Status != tt.status || report.Checks["mongodb"].Error != errorText(mongoErr) {
+			t.Errorf("%s: report %+v, want %s", tt.name, report, tt.status)
+		}
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
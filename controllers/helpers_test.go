@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/mongotest"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+// testCatalog connects to the test database and returns the book and author
+// controllers, sharing an outbox whose relay does not run, so that the
+// recorded events stay in the outbox collection.
+func testCatalog(t *testing.T) (*mongo.Client, *outbox.Outbox, *BookController, *AuthorController) {
+	t.Helper()
+	client := mongotest.Connect(t)
+	replicated := dbmongo.IsReplicated(context.Background(), client)
+	ob := outbox.New(client, zap.NewNop(), map[string]events.Publisher{"test": events.Discard}, replicated)
+	return client, ob, NewBookController(client, zap.NewNop(), ob), NewAuthorController(client, zap.NewNop(), ob)
+}
+
+// seedBooks stores a book of version 1 under each title and returns their IDs.
+func seedBooks(t *testing.T, client *mongo.Client, titles ...string) []primitive.ObjectID {
+	t.Helper()
+	ids := make([]primitive.ObjectID, len(titles))
+	for i, title := range titles {
+		ids[i] = primitive.NewObjectID()
+		book := models.Book{ID: ids[i], Title: title, Version: 1}
+		if _, err := mongotest.Collection(client, config.Current.Database.Collections.Books).InsertOne(context.Background(), book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// serve runs one request through handler, registered on route, and returns
+// the response.
+func serve(handler gin.HandlerFunc, method, route, target, body string, header http.Header) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, handler)
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals the JSON body of w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+}
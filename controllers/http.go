@@ -1,20 +1,30 @@
 package controllers
 
 import (
+	"sync"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/health"
+	"github.com/saifujnu/books-authors/jobs"
+	"github.com/saifujnu/books-authors/outbox"
+	"github.com/saifujnu/books-authors/webhooks"
 )
 
 // //////////for author controller///////////////
 type AuthorController struct {
 	db     *mongo.Client
 	logger *zap.Logger // Add a logger field
+	outbox *outbox.Outbox
 }
 
-func NewAuthorController(db *mongo.Client, logger *zap.Logger) *AuthorController {
+func NewAuthorController(db *mongo.Client, logger *zap.Logger, outbox *outbox.Outbox) *AuthorController {
 	return &AuthorController{
 		db:     db,
 		logger: logger, // Initialize the logger field
+		outbox: outbox,
 	}
 }
 
@@ -22,12 +32,14 @@ func NewAuthorController(db *mongo.Client, logger *zap.Logger) *AuthorController
 type BookController struct {
 	db     *mongo.Client
 	logger *zap.Logger // Add a logger field
+	outbox *outbox.Outbox
 }
 
-func NewBookController(db *mongo.Client, logger *zap.Logger) *BookController {
+func NewBookController(db *mongo.Client, logger *zap.Logger, outbox *outbox.Outbox) *BookController {
 	return &BookController{
 		db:     db,
 		logger: logger, // Initialize the logger field
+		outbox: outbox,
 	}
 }
 
@@ -44,3 +56,87 @@ func NewAuthController(db *mongo.Client, logger *zap.Logger) *AuthController {
 		logger: logger, // Initialize the logger field
 	}
 }
+
+// ----------------------------------------------------------------
+
+type ImportController struct {
+	db     *mongo.Client
+	logger *zap.Logger // Add a logger field
+	jobs   *jobs.Manager
+	outbox *outbox.Outbox
+}
+
+func NewImportController(db *mongo.Client, logger *zap.Logger, jobs *jobs.Manager, outbox *outbox.Outbox) *ImportController {
+	return &ImportController{
+		db:     db,
+		logger: logger, // Initialize the logger field
+		jobs:   jobs,
+		outbox: outbox,
+	}
+}
+
+// ----------------------------------------------------------------
+
+type FeedController struct {
+	db     *mongo.Client
+	logger *zap.Logger // Add a logger field
+}
+
+func NewFeedController(db *mongo.Client, logger *zap.Logger) *FeedController {
+	return &FeedController{
+		db:     db,
+		logger: logger, // Initialize the logger field
+	}
+}
+
+// ----------------------------------------------------------------
+
+type WebhookController struct {
+	logger   *zap.Logger // Add a logger field
+	webhooks *webhooks.Dispatcher
+}
+
+func NewWebhookController(logger *zap.Logger, webhooks *webhooks.Dispatcher) *WebhookController {
+	return &WebhookController{
+		logger:   logger, // Initialize the logger field
+		webhooks: webhooks,
+	}
+}
+
+// ----------------------------------------------------------------
+
+type ChangeController struct {
+	logger  *zap.Logger // Add a logger field
+	changes events.Source
+	// stop is closed by Shutdown to end the open streams.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewChangeController(logger *zap.Logger, changes events.Source) *ChangeController {
+	return &ChangeController{
+		logger:  logger, // Initialize the logger field
+		changes: changes,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Shutdown ends every open change stream, so that the server can close the
+// connections; clients reconnect to another instance and resume.
+func (cc *ChangeController) Shutdown() {
+	cc.stopOnce.Do(func() { close(cc.stop) })
+}
+
+// ----------------------------------------------------------------
+
+type HealthController struct {
+	logger *zap.Logger // Add a logger field
+	health *health.Checker
+}
+
+func NewHealthController(logger *zap.Logger, health *health.Checker) *HealthController {
+	return &HealthController{
+		logger: logger, // Initialize the logger field
+		health: health,
+	}
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/importer"
+)
+
+const (
+	// importJobKind identifies import jobs in the job manager.
+	importJobKind = "import"
+	// maxImportSize caps the size of an uploaded import file.
+	maxImportSize = 256 << 20
+	// maxONIXSize caps the size of an uploaded ONIX message.
+	maxONIXSize = 2 << 30
+)
+
+// Import accepts a CSV, NDJSON, MARC 21 or MARCXML file of books, either as the "file" field of
+// a multipart form or as the raw request body. With dry_run=true the file is
+// checked synchronously and the planned changes are returned; otherwise the
+// import runs as a background job.
+func (ic *ImportController) Import(c *gin.Context) {
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be a boolean"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	source, format, err := importSource(c)
+	if err != nil {
+		// Log the error and return a bad request response.
+		requestLogger(c, ic.logger).Error("Invalid import upload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer source.Close()
+
+	imp := importer.New(
+		ic.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books),
+		ic.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors),
+		ic.outbox,
+	)
+
+	if dryRun {
+		requestLogger(c, ic.logger).Debug("Running import dry run", zap.String("Format", format))
+
+		rows, err := importer.NewRowReader(format, source)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		report, err := imp.Run(c.Request.Context(), rows, true)
+		if err != nil {
+			// Log the error and return an internal server error response.
+			requestLogger(c, ic.logger).Error("Import dry run failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Import dry run failed", "report": report})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	spool, err := spoolUpload(source)
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, ic.logger).Error("Failed to spool import file", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store import file"})
+		return
+	}
+
+	job := ic.jobs.Start(importJobKind, func(ctx context.Context) (interface{}, error) {
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		rows, err := importer.NewRowReader(format, spool)
+		if err != nil {
+			return nil, err
+		}
+		return imp.Run(ctx, rows, false)
+	})
+
+	requestLogger(c, ic.logger).Info("Import job started", zap.String("JobID", job.ID), zap.String("Format", format))
+	c.Header("Location", "/import/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ImportONIX accepts an ONIX for Books 3.0 message as the "file" field of a
+// multipart form or as the raw request body and applies it in a background
+// job. The message is streamed to disk and then parsed one product at a time,
+// so memory use does not grow with the size of the feed. With dry_run=true
+// the job only reports what it would change.
+func (ic *ImportController) ImportONIX(c *gin.Context) {
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be a boolean"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxONIXSize)
+	source := io.ReadCloser(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if source, err = header.Open(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	defer source.Close()
+
+	spool, err := spoolUpload(source)
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, ic.logger).Error("Failed to spool ONIX message", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store ONIX message"})
+		return
+	}
+
+	imp := importer.New(
+		ic.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books),
+		ic.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors),
+		ic.outbox,
+	)
+	job := ic.jobs.Start(importJobKind, func(ctx context.Context) (interface{}, error) {
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		return imp.RunONIX(ctx, spool, dryRun)
+	})
+
+	requestLogger(c, ic.logger).Info("ONIX import job started", zap.String("JobID", job.ID), zap.Bool("DryRun", dryRun))
+	c.Header("Location", "/import/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImport reports the status of an import job.
+func (ic *ImportController) GetImport(c *gin.Context) {
+	job, ok := ic.jobs.Get(c.Param("id"))
+	if !ok || job.Kind != importJobKind {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// GetImportErrors downloads the row-level error report of a finished import
+// job as CSV.
+func (ic *ImportController) GetImportErrors(c *gin.Context) {
+	job, ok := ic.jobs.Get(c.Param("id"))
+	if !ok || job.Kind != importJobKind {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+
+	report, ok := job.Result.(*importer.Report)
+	if job.FinishedAt == nil || !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Import job has no report yet", "status": job.Status})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="import-`+job.ID+`-errors.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"line", "title", "isbn", "error"})
+	for _, rowErr := range report.Errors {
+		w.Write([]string{strconv.Itoa(rowErr.Line), rowErr.Title, rowErr.ISBN, rowErr.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		requestLogger(c, ic.logger).Error("Failed to write import error report", zap.Error(err))
+	}
+}
+
+// spoolUpload copies an upload to a temporary file and rewinds it. Uploads
+// are spooled because the request body is gone once the handler returns. The
+// caller removes the file.
+func spoolUpload(source io.Reader) (*os.File, error) {
+	spool, err := os.CreateTemp("", "books-import-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(spool, source); err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, err
+	}
+	return spool, nil
+}
+
+// importSource returns the uploaded file and its format. The format comes
+// from the format query parameter, the file extension or the content type.
+func importSource(c *gin.Context) (io.ReadCloser, string, error) {
+	var (
+		source      io.ReadCloser
+		filename    string
+		contentType = c.ContentType()
+	)
+
+	if strings.HasPrefix(contentType, "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		source, filename, contentType = file, header.Filename, header.Header.Get("Content-Type")
+	} else {
+		source = c.Request.Body
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = importFormat(filename, contentType)
+	}
+	if !importer.IsFormat(format) {
+		source.Close()
+		return nil, "", errUnknownImportFormat
+	}
+	return source, format, nil
+}
+
+var errUnknownImportFormat = errors.New("cannot tell the import format; pass format=csv, ndjson, marc or marcxml")
+
+func importFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return importer.FormatCSV
+	case ".ndjson", ".jsonl":
+		return importer.FormatNDJSON
+	case ".mrc", ".marc":
+		return importer.FormatMARC
+	case ".xml":
+		return importer.FormatMARCXML
+	}
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return importer.FormatCSV
+	case strings.HasPrefix(contentType, "application/marcxml+xml"):
+		return importer.FormatMARCXML
+	case strings.HasPrefix(contentType, "application/marc"):
+		return importer.FormatMARC
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonlines"):
+		return importer.FormatNDJSON
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/linkeddata"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// representation negotiates the media type of a single book or author from
+// the Accept header. Plain JSON is served unless JSON-LD or RDF/XML is asked
+// for, also when nothing offered is acceptable.
+func representation(c *gin.Context) string {
+	c.Header("Vary", "Accept")
+	format := c.NegotiateFormat(gin.MIMEJSON, linkeddata.JSONLDContentType, linkeddata.RDFXMLContentType)
+	if format == "" {
+		return gin.MIMEJSON
+	}
+	return format
+}
+
+// representationVariant names a linked data media type in ETags.
+func representationVariant(format string) string {
+	if format == linkeddata.RDFXMLContentType {
+		return "rdf"
+	}
+	return "ld"
+}
+
+// serveBookLinkedData writes the schema.org JSON-LD or Dublin Core
+// description of a book. Both name the author, so the author's version is
+// part of the ETag.
+func (bc *BookController) serveBookLinkedData(c *gin.Context, book models.Book, format string) {
+	var author *models.Author
+	if !book.AuthorID.IsZero() {
+		authorCollection := bc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+		var found models.Author
+		err := authorCollection.FindOne(c.Request.Context(), bson.M{"_id": book.AuthorID}).Decode(&found)
+		switch {
+		case err == nil:
+			author = &found
+		case !errors.Is(err, mongo.ErrNoDocuments):
+			// Log the error and return an internal server error response.
+			requestLogger(c, bc.logger).Error("Failed to fetch author of book", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+			return
+		}
+	}
+
+	variant := representationVariant(format)
+	authorHref := ""
+	if author != nil {
+		variant += fmt.Sprintf("-%s-%d", author.ID.Hex(), author.Version)
+		authorHref = baseURL(c) + "/authors/" + author.ID.Hex()
+	}
+	etag := variantTag(entityTag(book.ID, book.Version), variant)
+	c.Header("ETag", etag)
+	if notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	href := baseURL(c) + "/books/" + book.ID.Hex()
+	writeLinkedData(c, requestLogger(c, bc.logger), format,
+		func() interface{} { return linkeddata.BookDocument(book, href, author, authorHref) },
+		func() *linkeddata.RDF { return linkeddata.BookRDF(book, href, author, authorHref) })
+}
+
+// serveAuthorLinkedData writes the schema.org JSON-LD or FOAF description of
+// an author.
+func (ac *AuthorController) serveAuthorLinkedData(c *gin.Context, author models.Author, format string) {
+	etag := variantTag(entityTag(author.ID, author.Version), representationVariant(format))
+	c.Header("ETag", etag)
+	if notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	href := baseURL(c) + "/authors/" + author.ID.Hex()
+	writeLinkedData(c, requestLogger(c, ac.logger), format,
+		func() interface{} { return linkeddata.AuthorDocument(author, href) },
+		func() *linkeddata.RDF { return linkeddata.AuthorRDF(author, href) })
+}
+
+// writeLinkedData renders and writes the representation for format; only
+// the builder for that format is called.
+func writeLinkedData(c *gin.Context, logger *zap.Logger, format string, jsonld func() interface{}, rdf func() *linkeddata.RDF) {
+	var (
+		body []byte
+		err  error
+	)
+	if format == linkeddata.RDFXMLContentType {
+		body, err = rdf().Marshal()
+	} else {
+		body, err = json.Marshal(jsonld())
+	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		logger.Error("Failed to render linked data", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render linked data"})
+		return
+	}
+	c.Data(http.StatusOK, format+"; charset=utf-8", body)
+}
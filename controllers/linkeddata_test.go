@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/linkeddata"
+)
+
+func TestRepresentation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", gin.MIMEJSON},
+		{"*/*", gin.MIMEJSON},
+		{"application/json", gin.MIMEJSON},
+		{"application/ld+json", linkeddata.JSONLDContentType},
+		{"application/rdf+xml", linkeddata.RDFXMLContentType},
+		{"application/ld+json, application/rdf+xml", linkeddata.JSONLDContentType},
+		{"text/html", gin.MIMEJSON},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		if tt.accept != "" {
+			c.Request.Header.Set("Accept", tt.accept)
+		}
+		if got := representation(c); got != tt.want {
+			t.Errorf("representation for Accept %q = %s, want %s", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestLinkedDataRepresentations(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	id := seedBooks(t, client, "Dune")[0]
+	etag := entityTag(id, 1)
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		etag        string
+		body        string
+	}{
+		{"JSON", "application/json", "application/json; charset=utf-8", etag, `"title":"Dune"`},
+		{"JSON-LD", linkeddata.JSONLDContentType, linkeddata.JSONLDContentType + "; charset=utf-8", variantTag(etag, "ld"), `"@type":"Book"`},
+		{"RDF/XML", linkeddata.RDFXMLContentType, linkeddata.RDFXMLContentType + "; charset=utf-8", variantTag(etag, "rdf"), `<dc:title>Dune</dc:title>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(books.GetBookByID, http.MethodGet, "/books/:id", "/books/"+id.Hex(), "", http.Header{"Accept": {tt.accept}})
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %s, want %s", got, tt.contentType)
+			}
+			if got := w.Header().Get("ETag"); got != tt.etag {
+				t.Errorf("ETag = %s, want %s", got, tt.etag)
+			}
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Vary = %q, want Accept", w.Header().Get("Vary"))
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("body lacks %s: %s", tt.body, w.Body)
+			}
+
+			// The representation's own tag answers conditional requests.
+			w = serve(books.GetBookByID, http.MethodGet, "/books/:id", "/books/"+id.Hex(), "", http.Header{"Accept": {tt.accept}, "If-None-Match": {tt.etag}})
+			if w.Code != http.StatusNotModified {
+				t.Errorf("conditional status = %d, want 304", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/requestlog"
+)
+
+// requestLogger returns the logger of the request, whose entries carry its
+// ID, route and user, or fallback when the request has none.
+func requestLogger(c *gin.Context, fallback *zap.Logger) *zap.Logger {
+	return requestlog.FromContext(c.Request.Context(), fallback)
+}
@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/feeds"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// opdsPageSize is the number of entries per OPDS feed page.
+const opdsPageSize = 50
+
+// opdsPage is a catalog page before it is rendered as OPDS 1.2 Atom or
+// OPDS 2.0 JSON. A page has either navigation links or books.
+type opdsPage struct {
+	id         string
+	title      string
+	path       string     // path of the page below the OPDS prefix
+	query      url.Values // query parameters other than page
+	navigation []feeds.Link
+	books      []models.BookWithAuthor
+	total      int64
+	page       int
+}
+
+// opdsPrefix returns the route prefix of the OPDS version being served.
+func opdsPrefix(c *gin.Context) string {
+	if strings.HasPrefix(c.FullPath(), "/opds/v2") {
+		return "/opds/v2"
+	}
+	return "/opds"
+}
+
+func opdsPageNumber(c *gin.Context) (int, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, fmt.Errorf("page must be a positive integer")
+	}
+	return page, nil
+}
+
+// OPDSRoot serves the root navigation feed of the catalog.
+func (fc *FeedController) OPDSRoot(c *gin.Context) {
+	prefix := opdsPrefix(c)
+	fc.renderOPDS(c, opdsPage{
+		id:    "urn:books-authors:catalog",
+		title: "Books and Authors catalog",
+		navigation: []feeds.Link{
+			{Rel: feeds.RelSubsection, Href: prefix + "/books", Type: feeds.OPDSAcquisitionType, Title: "All books"},
+			{Rel: feeds.RelSubsection, Href: prefix + "/authors", Type: feeds.OPDSNavigationType, Title: "Authors"},
+		},
+	})
+}
+
+// OPDSBooks serves the acquisition feed of all books, oldest first.
+func (fc *FeedController) OPDSBooks(c *gin.Context) {
+	page, err := opdsPageNumber(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, total, err := fc.bookPage(c.Request.Context(), bson.M{}, page)
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to fetch books for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		return
+	}
+
+	fc.renderOPDS(c, opdsPage{
+		id:    "urn:books-authors:books",
+		title: "All books",
+		path:  "/books",
+		books: books,
+		total: total,
+		page:  page,
+	})
+}
+
+// OPDSAuthors serves a navigation feed with one entry per author.
+func (fc *FeedController) OPDSAuthors(c *gin.Context) {
+	page, err := opdsPageNumber(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorCollection := fc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	total, err := authorCollection.CountDocuments(c.Request.Context(), bson.M{})
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to count authors for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "lastName", Value: 1}, {Key: "firstName", Value: 1}}).
+		SetSkip(int64((page - 1) * opdsPageSize)).
+		SetLimit(opdsPageSize)
+	cursor, err := authorCollection.Find(c.Request.Context(), bson.M{}, findOptions)
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to fetch authors for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authors"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var authors []models.Author
+	if err := cursor.All(c.Request.Context(), &authors); err != nil {
+		requestLogger(c, fc.logger).Error("Failed to decode authors for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode authors"})
+		return
+	}
+
+	prefix := opdsPrefix(c)
+	navigation := make([]feeds.Link, 0, len(authors))
+	for _, author := range authors {
+		navigation = append(navigation, feeds.Link{
+			Rel:   feeds.RelSubsection,
+			Href:  prefix + "/authors/" + author.ID.Hex(),
+			Type:  feeds.OPDSAcquisitionType,
+			Title: feeds.AuthorDisplayName(author),
+		})
+	}
+
+	fc.renderOPDS(c, opdsPage{
+		id:         "urn:books-authors:authors",
+		title:      "Authors",
+		path:       "/authors",
+		navigation: navigation,
+		total:      total,
+		page:       page,
+	})
+}
+
+// OPDSAuthorBooks serves the acquisition feed of one author's books. Like
+// GetBooksByAuthorName it resolves the author first and then selects the
+// books by author ID.
+func (fc *FeedController) OPDSAuthorBooks(c *gin.Context) {
+	page, err := opdsPageNumber(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Invalid author ID", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+
+	authorCollection := fc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	var author models.Author
+	err = authorCollection.FindOne(c.Request.Context(), bson.M{"_id": authorObjID}).Decode(&author)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Log the error and return a not found response.
+		requestLogger(c, fc.logger).Error("Author not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+		return
+	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to fetch author", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch author"})
+		return
+	}
+
+	books, total, err := fc.bookPage(c.Request.Context(), bson.M{"authorId": author.ID}, page)
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to fetch books for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		return
+	}
+
+	fc.renderOPDS(c, opdsPage{
+		id:    "urn:books-authors:author:" + author.ID.Hex(),
+		title: "Books by " + feeds.AuthorDisplayName(author),
+		path:  "/authors/" + author.ID.Hex(),
+		books: books,
+		total: total,
+		page:  page,
+	})
+}
+
+// OPDSSearch serves the acquisition feed of books whose title contains q.
+func (fc *FeedController) OPDSSearch(c *gin.Context) {
+	page, err := opdsPageNumber(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	terms := strings.TrimSpace(c.Query("q"))
+	if terms == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	filter := bson.M{"title": primitive.Regex{Pattern: regexp.QuoteMeta(terms), Options: "i"}}
+	books, total, err := fc.bookPage(c.Request.Context(), filter, page)
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to search books for OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search books"})
+		return
+	}
+
+	fc.renderOPDS(c, opdsPage{
+		id:    "urn:books-authors:search:" + url.QueryEscape(terms),
+		title: "Search results for " + terms,
+		path:  "/search",
+		query: url.Values{"q": {terms}},
+		books: books,
+		total: total,
+		page:  page,
+	})
+}
+
+// OPDSOpenSearch serves the OpenSearch description of the catalog search.
+func (fc *FeedController) OPDSOpenSearch(c *gin.Context) {
+	description := feeds.NewOpenSearchDescription(
+		"Books and Authors",
+		"Search the Books and Authors catalog by title",
+		feeds.OpenSearchURL{Type: feeds.OPDSAcquisitionType, Template: "/opds/search?q={searchTerms}"},
+		feeds.OpenSearchURL{Type: feeds.OPDS2Type, Template: "/opds/v2/search?q={searchTerms}"},
+	)
+	body, err := description.Marshal()
+	if err != nil {
+		requestLogger(c, fc.logger).Error("Failed to render OpenSearch description", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render OpenSearch description"})
+		return
+	}
+	c.Data(http.StatusOK, feeds.OpenSearchDescriptor+"; charset=utf-8", body)
+}
+
+// bookPage returns one page of the books matching filter, joined with their
+// authors, together with the total number of matches.
+func (fc *FeedController) bookPage(ctx context.Context, filter bson.M, page int) ([]models.BookWithAuthor, int64, error) {
+	bookCollection := fc.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+	total, err := bookCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pipeline := append([]bson.M{
+		{"$match": filter},
+		{"$sort": bson.M{"_id": 1}},
+		{"$skip": (page - 1) * opdsPageSize},
+		{"$limit": opdsPageSize},
+	}, models.AuthorLookup(config.Current.Database.Collections.Authors)...)
+	cursor, err := bookCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var books []models.BookWithAuthor
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// pageHref returns the URL of page n of p.
+func (p opdsPage) pageHref(prefix string, n int) string {
+	query := url.Values{}
+	for key, values := range p.query {
+		query[key] = values
+	}
+	if n > 1 {
+		query.Set("page", strconv.Itoa(n))
+	}
+	if encoded := query.Encode(); encoded != "" {
+		return prefix + p.path + "?" + encoded
+	}
+	return prefix + p.path
+}
+
+// pagingLinks returns the self, first, previous, next and last links of a
+// paginated page.
+func (p opdsPage) pagingLinks(prefix, pageType string) []feeds.Link {
+	links := []feeds.Link{{Rel: feeds.RelSelf, Href: p.pageHref(prefix, p.page), Type: pageType}}
+	if p.page == 0 {
+		return links
+	}
+
+	lastPage := int((p.total + opdsPageSize - 1) / opdsPageSize)
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	links = append(links, feeds.Link{Rel: feeds.RelFirst, Href: p.pageHref(prefix, 1), Type: pageType})
+	if p.page > 1 {
+		links = append(links, feeds.Link{Rel: feeds.RelPrevious, Href: p.pageHref(prefix, p.page-1), Type: pageType})
+	}
+	if p.page < lastPage {
+		links = append(links, feeds.Link{Rel: feeds.RelNext, Href: p.pageHref(prefix, p.page+1), Type: pageType})
+	}
+	links = append(links, feeds.Link{Rel: feeds.RelLast, Href: p.pageHref(prefix, lastPage), Type: pageType})
+	return links
+}
+
+// renderOPDS writes the page as an OPDS 1.2 Atom feed or, below /opds/v2, as
+// an OPDS 2.0 JSON feed.
+func (fc *FeedController) renderOPDS(c *gin.Context, p opdsPage) {
+	var (
+		body        []byte
+		err         error
+		contentType = feeds.AtomContentType
+		prefix      = opdsPrefix(c)
+	)
+	if prefix == "/opds/v2" {
+		contentType = feeds.OPDS2ContentType
+		body, err = json.Marshal(p.opds2(prefix))
+	} else {
+		body, err = p.atom(prefix).Marshal()
+	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, fc.logger).Error("Failed to render OPDS feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render OPDS feed"})
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+func (p opdsPage) atom(prefix string) *feeds.Feed {
+	pageType := feeds.OPDSAcquisitionType
+	if p.navigation != nil {
+		pageType = feeds.OPDSNavigationType
+	}
+
+	feed := feeds.NewFeed(p.id, p.title, time.Now())
+	feed.Author = &feeds.Person{Name: "Books and Authors"}
+	feed.Links = append(p.pagingLinks(prefix, pageType),
+		feeds.Link{Rel: feeds.RelStart, Href: prefix, Type: feeds.OPDSNavigationType},
+		feeds.Link{Rel: feeds.RelSearch, Href: "/opds/opensearch.xml", Type: feeds.OpenSearchDescriptor},
+	)
+	if p.page > 0 {
+		feed.TotalResults = p.total
+		feed.ItemsPerPage = opdsPageSize
+		feed.StartIndex = int64((p.page-1)*opdsPageSize + 1)
+	}
+
+	for _, link := range p.navigation {
+		feed.Entries = append(feed.Entries, feeds.Entry{
+			ID:      "urn:books-authors:nav:" + strings.TrimPrefix(link.Href, prefix),
+			Title:   link.Title,
+			Updated: feed.Updated,
+			Links:   []feeds.Link{link},
+		})
+	}
+	for _, book := range p.books {
+		links := []feeds.Link{
+			{Rel: feeds.RelAcquisition, Href: "/books/" + book.ID.Hex(), Type: "application/json"},
+		}
+		authorHref := ""
+		if book.Author != nil {
+			authorHref = prefix + "/authors/" + book.Author.ID.Hex()
+			links = append(links, feeds.Link{Rel: feeds.RelAlternate, Href: authorHref, Type: feeds.OPDSAcquisitionType, Title: "More by this author"})
+		}
+		feed.Entries = append(feed.Entries, feeds.BookEntry(book, links, authorHref))
+	}
+	return feed
+}
+
+func (p opdsPage) opds2(prefix string) feeds.OPDS2Feed {
+	feed := feeds.OPDS2Feed{
+		Metadata: feeds.OPDS2Metadata{Title: p.title},
+		Links: []feeds.OPDS2Link{
+			{Rel: feeds.RelStart, Href: prefix, Type: feeds.OPDS2Type},
+			{Rel: feeds.RelSearch, Href: prefix + "/search{?q}", Type: feeds.OPDS2Type, Templated: true},
+		},
+	}
+	for _, link := range p.pagingLinks(prefix, feeds.OPDS2Type) {
+		feed.Links = append(feed.Links, feeds.OPDS2Link{Rel: link.Rel, Href: link.Href, Type: link.Type})
+	}
+	if p.page > 0 {
+		feed.Metadata.NumberOfItems = p.total
+		feed.Metadata.ItemsPerPage = opdsPageSize
+		feed.Metadata.CurrentPage = p.page
+	}
+
+	for _, link := range p.navigation {
+		feed.Navigation = append(feed.Navigation, feeds.OPDS2Link{Href: link.Href, Title: link.Title, Type: feeds.OPDS2Type})
+	}
+	for _, book := range p.books {
+		links := []feeds.OPDS2Link{
+			{Rel: feeds.RelAcquisition, Href: "/books/" + book.ID.Hex(), Type: "application/json"},
+		}
+		authorHref := ""
+		if book.Author != nil {
+			authorHref = prefix + "/authors/" + book.Author.ID.Hex()
+		}
+		feed.Publications = append(feed.Publications, feeds.BookPublication(book, links, authorHref))
+	}
+	return feed
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/feeds"
+)
+
+func TestOPDSPagingLinks(t *testing.T) {
+	const kind = feeds.OPDSAcquisitionType
+	link := func(rel, href string) feeds.Link { return feeds.Link{Rel: rel, Href: href, Type: kind} }
+
+	tests := []struct {
+		name string
+		page opdsPage
+		want []feeds.Link
+	}{
+		{
+			name: "not paginated",
+			page: opdsPage{},
+			want: []feeds.Link{link(feeds.RelSelf, "/opds")},
+		},
+		{
+			name: "empty",
+			page: opdsPage{path: "/books", page: 1},
+			want: []feeds.Link{
+				link(feeds.RelSelf, "/opds/books"),
+				link(feeds.RelFirst, "/opds/books"),
+				link(feeds.RelLast, "/opds/books"),
+			},
+		},
+		{
+			name: "first of three",
+			page: opdsPage{path: "/books", total: 2*opdsPageSize + 1, page: 1},
+			want: []feeds.Link{
+				link(feeds.RelSelf, "/opds/books"),
+				link(feeds.RelFirst, "/opds/books"),
+				link(feeds.RelNext, "/opds/books?page=2"),
+				link(feeds.RelLast, "/opds/books?page=3"),
+			},
+		},
+		{
+			name: "middle with query",
+			page: opdsPage{path: "/search", query: url.Values{"q": {"dune & co"}}, total: 3 * opdsPageSize, page: 2},
+			want: []feeds.Link{
+				link(feeds.RelSelf, "/opds/search?page=2&q=dune+%26+co"),
+				link(feeds.RelFirst, "/opds/search?q=dune+%26+co"),
+				link(feeds.RelPrevious, "/opds/search?q=dune+%26+co"),
+				link(feeds.RelNext, "/opds/search?page=3&q=dune+%26+co"),
+				link(feeds.RelLast, "/opds/search?page=3&q=dune+%26+co"),
+			},
+		},
+		{
+			name: "last",
+			page: opdsPage{path: "/books", total: opdsPageSize + 1, page: 2},
+			want: []feeds.Link{
+				link(feeds.RelSelf, "/opds/books?page=2"),
+				link(feeds.RelFirst, "/opds/books"),
+				link(feeds.RelPrevious, "/opds/books"),
+				link(feeds.RelLast, "/opds/books?page=2"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.page.pagingLinks("/opds", kind); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pagingLinks = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOPDSRoot(t *testing.T) {
+	fc := NewFeedController(nil, zap.NewNop())
+
+	t.Run("OPDS 1.2", func(t *testing.T) {
+		w := serve(fc.OPDSRoot, http.MethodGet, "/opds", "/opds", "", nil)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != feeds.AtomContentType {
+			t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+		var feed feeds.Feed
+		if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		var hrefs []string
+		for _, entry := range feed.Entries {
+			hrefs = append(hrefs, entry.Links[0].Href)
+		}
+		if want := []string{"/opds/books", "/opds/authors"}; !reflect.DeepEqual(hrefs, want) {
+			t.Errorf("entries link to %v, want %v", hrefs, want)
+		}
+		if feed.Links[0].Type != feeds.OPDSNavigationType {
+			t.Errorf("self link type = %s, want navigation", feed.Links[0].Type)
+		}
+	})
+
+	t.Run("OPDS 2.0", func(t *testing.T) {
+		w := serve(fc.OPDSRoot, http.MethodGet, "/opds/v2", "/opds/v2", "", nil)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != feeds.OPDS2ContentType {
+			t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+		var feed feeds.OPDS2Feed
+		decode(t, w, &feed)
+		var hrefs []string
+		for _, link := range feed.Navigation {
+			hrefs = append(hrefs, link.Href)
+		}
+		if want := []string{"/opds/v2/books", "/opds/v2/authors"}; !reflect.DeepEqual(hrefs, want) {
+			t.Errorf("navigation links to %v, want %v", hrefs, want)
+		}
+		if search := feed.Links[1]; search.Rel != feeds.RelSearch || search.Href != "/opds/v2/search{?q}" || !search.Templated {
+			t.Errorf("search link = %+v", search)
+		}
+	})
+}
+
+func TestOPDSOpenSearch(t *testing.T) {
+	fc := NewFeedController(nil, zap.NewNop())
+	w := serve(fc.OPDSOpenSearch, http.MethodGet, "/opds/opensearch.xml", "/opds/opensearch.xml", "", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), feeds.OpenSearchDescriptor) {
+		t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var description feeds.OpenSearchDescription
+	if err := xml.Unmarshal(w.Body.Bytes(), &description); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range description.URLs {
+		if !strings.Contains(u.Template, "{searchTerms}") {
+			t.Errorf("template %q has no {searchTerms}", u.Template)
+		}
+	}
+	if len(description.URLs) != 2 {
+		t.Errorf("%d URLs, want one per OPDS version", len(description.URLs))
+	}
+}
+
+func TestOPDSBadRequests(t *testing.T) {
+	fc := NewFeedController(nil, zap.NewNop())
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		route   string
+		target  string
+	}{
+		{"page zero", fc.OPDSBooks, "/opds/books", "/opds/books?page=0"},
+		{"page not a number", fc.OPDSBooks, "/opds/v2/books", "/opds/v2/books?page=two"},
+		{"no search terms", fc.OPDSSearch, "/opds/search", "/opds/search?q=+"},
+		{"invalid author ID", fc.OPDSAuthorBooks, "/opds/authors/:id", "/opds/authors/herbert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, http.MethodGet, tt.route, tt.target, "", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	// RFC 7396 JSON Merge Patch
+	mergePatchContentType = "application/merge-patch+json"
+	// RFC 6902 JSON Patch
+	jsonPatchContentType = "application/json-patch+json"
+)
+
+// patchError carries the HTTP status a failed patch should be reported with.
+type patchError struct {
+	status int
+	err    error
+}
+
+func (e *patchError) Error() string {
+	return e.err.Error()
+}
+
+// applyPatch applies the PATCH request body to the JSON representation of
+// original, decodes the outcome into target and validates it. The returned
+// error is a *patchError.
+func applyPatch(c *gin.Context, original, target interface{}) error {
+	document, err := json.Marshal(original)
+	if err != nil {
+		return &patchError{http.StatusInternalServerError, err}
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return &patchError{http.StatusBadRequest, err}
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case mergePatchContentType:
+		if !json.Valid(body) {
+			return &patchError{http.StatusBadRequest, errors.New("invalid merge patch document")}
+		}
+		patched, err = jsonpatch.MergePatch(document, body)
+		if err != nil {
+			return &patchError{http.StatusBadRequest, err}
+		}
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return &patchError{http.StatusBadRequest, err}
+		}
+		patched, err = patch.Apply(document)
+		if err != nil {
+			// Failed "test" operations and missing paths leave the document untouched.
+			return &patchError{http.StatusConflict, err}
+		}
+	default:
+		return &patchError{
+			http.StatusUnsupportedMediaType,
+			errors.New("Content-Type must be " + mergePatchContentType + " or " + jsonPatchContentType),
+		}
+	}
+
+	if err := json.Unmarshal(patched, target); err != nil {
+		return &patchError{http.StatusUnprocessableEntity, err}
+	}
+
+	// Validation runs on the patched document, not on the patch itself.
+	if err := binding.Validator.ValidateStruct(target); err != nil {
+		return &patchError{http.StatusUnprocessableEntity, err}
+	}
+	return nil
+}
+
+// abortWithPatchError writes the response for an error returned by applyPatch.
+func abortWithPatchError(c *gin.Context, err error) {
+	var pe *patchError
+	if errors.As(err, &pe) {
+		c.JSON(pe.status, gin.H{"error": pe.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
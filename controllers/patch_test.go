@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestApplyPatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authorID, _ := primitive.ObjectIDFromHex("64b7f0c2a1b2c3d4e5f60718")
+	original := models.Book{Title: "Dune", AuthorID: authorID}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		want        models.Book
+	}{
+		{
+			name: "merge patch", contentType: mergePatchContentType,
+			body: `{"title": "Dune Messiah", "authorId": null}`,
+			want: models.Book{Title: "Dune Messiah"},
+		},
+		{
+			name: "JSON patch", contentType: jsonPatchContentType,
+			body: `[{"op": "test", "path": "/title", "value": "Dune"}, {"op": "replace", "path": "/title", "value": "Children of Dune"}, {"op": "remove", "path": "/authorId"}]`,
+			want: models.Book{Title: "Children of Dune"},
+		},
+		{
+			name: "content type with charset", contentType: mergePatchContentType + "; charset=utf-8",
+			body: `{"title": "Dune Messiah"}`,
+			want: models.Book{Title: "Dune Messiah", AuthorID: authorID},
+		},
+		{name: "failed test operation", contentType: jsonPatchContentType, body: `[{"op": "test", "path": "/title", "value": "Emma"}]`, status: http.StatusConflict},
+		{name: "missing path", contentType: jsonPatchContentType, body: `[{"op": "remove", "path": "/publication/place"}]`, status: http.StatusConflict},
+		{name: "invalid JSON patch", contentType: jsonPatchContentType, body: `{"op": "remove"}`, status: http.StatusBadRequest},
+		{name: "invalid merge patch", contentType: mergePatchContentType, body: `{"title": `, status: http.StatusBadRequest},
+		{name: "plain JSON", contentType: "application/json", body: `{"title": "Emma"}`, status: http.StatusUnsupportedMediaType},
+		{name: "invalid result", contentType: mergePatchContentType, body: `{"title": null}`, status: http.StatusUnprocessableEntity},
+		{name: "wrong type", contentType: mergePatchContentType, body: `{"title": 42}`, status: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPatch, "/books/1", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", tt.contentType)
+
+			var got models.Book
+			err := applyPatch(c, original, &got)
+			if tt.status != 0 {
+				pe, ok := err.(*patchError)
+				if !ok || pe.status != tt.status {
+					t.Fatalf("error = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patched = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchBook(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client, _, books, _ := testCatalog(t)
+	id := seedBooks(t, client, "Dune")[0]
+
+	header := http.Header{"Content-Type": {mergePatchContentType}}
+	w := serve(books.PatchBook, http.MethodPatch, "/books/:id", "/books/"+id.Hex(), `{"title": "Dune Messiah", "version": 7}`, header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var book models.Book
+	decode(t, w, &book)
+	// The version is the server's, whatever the patch said.
+	if book.Title != "Dune Messiah" || book.Version != 2 {
+		t.Errorf("patched book = %+v", book)
+	}
+
+	recorded := mongotest.RecordedEvents(t, client)
+	if len(recorded) != 1 || recorded[0].Type != events.BookUpdated || recorded[0].Subject != id.Hex() {
+		t.Errorf("recorded events %+v, want one %s", recorded, events.BookUpdated)
+	}
+}
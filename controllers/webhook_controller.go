@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/webhooks"
+)
+
+// CreateWebhook registers a subscription. The response is the only place
+// the signing secret is shown.
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	var sub webhooks.Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		// Log the error and return a bad request response.
+		requestLogger(c, wc.logger).Error("Invalid JSON input", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := wc.webhooks.Subscribe(c.Request.Context(), sub)
+	if errors.Is(err, webhooks.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, wc.logger).Error("Failed to create webhook", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	requestLogger(c, wc.logger).Info("Webhook created", zap.String("WebhookID", sub.ID.Hex()), zap.String("URL", sub.URL))
+	c.Header("Location", "/webhooks/"+sub.ID.Hex())
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (wc *WebhookController) GetWebhooks(c *gin.Context) {
+	subs, err := wc.webhooks.Subscriptions(c.Request.Context())
+	if err != nil {
+		// Log the error and return an internal server error response.
+		requestLogger(c, wc.logger).Error("Failed to fetch webhooks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (wc *WebhookController) GetWebhookByID(c *gin.Context) {
+	id, ok := wc.webhookID(c)
+	if !ok {
+		return
+	}
+
+	sub, err := wc.webhooks.Subscription(c.Request.Context(), id)
+	if err != nil {
+		wc.abortWithWebhookError(c, "Failed to fetch webhook", err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteWebhook removes a subscription and its delivery history.
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	id, ok := wc.webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := wc.webhooks.Unsubscribe(c.Request.Context(), id); err != nil {
+		wc.abortWithWebhookError(c, "Failed to delete webhook", err)
+		return
+	}
+
+	requestLogger(c, wc.logger).Info("Webhook deleted", zap.String("WebhookID", id.Hex()))
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries lists the latest deliveries of a subscription,
+// optionally only those with the given status.
+func (wc *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	id, ok := wc.webhookID(c)
+	if !ok {
+		return
+	}
+	if _, err := wc.webhooks.Subscription(c.Request.Context(), id); err != nil {
+		wc.abortWithWebhookError(c, "Failed to fetch webhook", err)
+		return
+	}
+
+	status := webhooks.Status(c.Query("status"))
+	switch status {
+	case "", webhooks.StatusPending, webhooks.StatusSucceeded, webhooks.StatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, succeeded or dead"})
+		return
+	}
+
+	deliveries, err := wc.webhooks.Deliveries(c.Request.Context(), id, status)
+	if err != nil {
+		wc.abortWithWebhookError(c, "Failed to fetch webhook deliveries", err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDeadLetters lists the deliveries of every subscription that ran out of
+// attempts.
+func (wc *WebhookController) GetDeadLetters(c *gin.Context) {
+	deliveries, err := wc.webhooks.Deliveries(c.Request.Context(), primitive.NilObjectID, webhooks.StatusDead)
+	if err != nil {
+		wc.abortWithWebhookError(c, "Failed to fetch dead letters", err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayDelivery queues a dead delivery again.
+func (wc *WebhookController) ReplayDelivery(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := wc.webhooks.Replay(c.Request.Context(), id)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead delivery not found"})
+		return
+	}
+	if err != nil {
+		wc.abortWithWebhookError(c, "Failed to replay delivery", err)
+		return
+	}
+
+	requestLogger(c, wc.logger).Info("Webhook delivery replayed", zap.String("DeliveryID", id.Hex()))
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func (wc *WebhookController) webhookID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// abortWithWebhookError answers 404 for unknown subscriptions and 500 with
+// message otherwise.
+func (wc *WebhookController) abortWithWebhookError(c *gin.Context, message string, err error) {
+	if errors.Is(err, webhooks.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	requestLogger(c, wc.logger).Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/saifujnu/books-authors/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -24,9 +26,16 @@ import (
 // 	connectTimeout = 20
 // )
 
-func Connect() (*mongo.Client, error) {
-	clientOptions := options.Client().ApplyURI(config.MongoURL)
-	ctx := context.TODO()
+// Connect connects to the configured database, giving up after its connect
+// timeout. Every command the client runs is reported to each of the
+// monitors.
+func Connect(monitors ...*event.CommandMonitor) (*mongo.Client, error) {
+	clientOptions := options.Client().ApplyURI(config.Current.Database.URI)
+	if len(monitors) > 0 {
+		clientOptions.SetMonitor(combineMonitors(monitors))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.Current.Database.ConnectTimeout)
+	defer cancel()
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
@@ -34,8 +43,48 @@ func Connect() (*mongo.Client, error) {
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
 	return client, nil
 }
+
+// IsReplicated reports whether the client is connected to a replica set or
+// a sharded cluster. Standalone servers support neither change streams nor
+// transactions.
+func IsReplicated(ctx context.Context, client *mongo.Client) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	return err == nil && (hello.SetName != "" || hello.Msg == "isdbgrid")
+}
+
+// combineMonitors returns a monitor that calls each of monitors in turn.
+func combineMonitors(monitors []*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
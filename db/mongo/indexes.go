@@ -0,0 +1,44 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/saifujnu/books-authors/config"
+)
+
+// EnsureIndexes creates the indexes the service relies on, for correctness
+// as much as for speed. Existing indexes are left alone, so it is safe to
+// run at every start.
+func EnsureIndexes(ctx context.Context, client *mongo.Client) error {
+	database := client.Database(config.Current.Database.Name)
+	indexes := []struct {
+		collection string
+		models     []mongo.IndexModel
+	}{
+		// Logins look users up by name, which must therefore be unique.
+		{config.Current.Database.Collections.Users, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}},
+		// An event is delivered at most once per subscription, and workers
+		// claim the pending delivery that is due first.
+		{config.Current.Database.Collections.WebhookDeliveries, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "subscriptionId", Value: 1}, {Key: "event.id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		}},
+		// The relay claims entries that are due.
+		{config.Current.Database.Collections.Outbox, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "nextAttemptAt", Value: 1}}},
+		}},
+	}
+	for _, index := range indexes {
+		if _, err := database.Collection(index.collection).Indexes().CreateMany(ctx, index.models); err != nil {
+			return fmt.Errorf("%s: %w", index.collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	client := mongotest.Connect(t)
+	ctx := context.Background()
+	// Indexes are only created once; a second start finds them there.
+	for i := 0; i < 2; i++ {
+		if err := EnsureIndexes(ctx, client); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	collections := config.Current.Database.Collections
+	tests := []struct {
+		collection string
+		first      bson.M
+		duplicate  bson.M
+	}{
+		{collections.Users, bson.M{"username": "alice"}, bson.M{"username": "alice"}},
+		{collections.WebhookDeliveries,
+			bson.M{"subscriptionId": 1, "event": bson.M{"id": "e1"}, "status": "pending"},
+			bson.M{"subscriptionId": 1, "event": bson.M{"id": "e1"}, "status": "dead"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collection, func(t *testing.T) {
+			coll := mongotest.Collection(client, tt.collection)
+			if _, err := coll.InsertOne(ctx, tt.first); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := coll.InsertOne(ctx, tt.duplicate); !mongo.IsDuplicateKeyError(err) {
+				t.Errorf("inserting a duplicate = %v, want a duplicate key error", err)
+			}
+		})
+	}
+}
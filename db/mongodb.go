@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 
+	"github.com/saifujnu/books-authors/config"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -12,7 +13,8 @@ var Client *mongo.Client
 
 func ConnectMongoDB(uri string) error {
 	clientOptions := options.Client().ApplyURI(uri)
-	ctx := context.TODO()
+	ctx, cancel := context.WithTimeout(context.Background(), config.Current.Database.ConnectTimeout)
+	defer cancel()
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return err
@@ -20,6 +22,7 @@ func ConnectMongoDB(uri string) error {
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
+		client.Disconnect(context.Background())
 		return err
 	}
 
@@ -29,7 +32,8 @@ func ConnectMongoDB(uri string) error {
 
 func GetMongoCon(uri string) (*mongo.Client, error) {
 	clientOptions := options.Client().ApplyURI(uri)
-	ctx := context.TODO()
+	ctx, cancel := context.WithTimeout(context.Background(), config.Current.Database.ConnectTimeout)
+	defer cancel()
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
@@ -37,6 +41,7 @@ func GetMongoCon(uri string) (*mongo.Client, error) {
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
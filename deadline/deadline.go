@@ -0,0 +1,90 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/saifujnu/books-authors/metrics"
+)
+
+// Middleware gives the context of every request a deadline: that of its
+// route in routes, keyed by "METHOD /route", or else fallback. A deadline
+// of 0 means none. Handlers pass the request context to the database, so
+// that a query stops once its request has timed out or the client has gone
+// away.
+//
+// A server error answered after that point is turned into a 504 Gateway
+// Timeout, or a 503 Service Unavailable when the request was cancelled, and
+// the requests that ran past their deadline are counted.
+func Middleware(fallback time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			timeout = fallback
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			metrics.Timeout(c.FullPath(), c.Request.Method)
+		}
+	}
+}
+
+// timeoutWriter replaces the server errors caused by the end of the request
+// context with an error that says so.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	replaced bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && !w.Written() {
+		switch err := w.ctx.Err(); {
+		case errors.Is(err, context.DeadlineExceeded):
+			code = http.StatusGatewayTimeout
+			w.replaced = true
+		case errors.Is(err, context.Canceled):
+			code = http.StatusServiceUnavailable
+			w.replaced = true
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if !w.replaced {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.Written() {
+		message := "Request timed out"
+		if w.Status() == http.StatusServiceUnavailable {
+			message = "Request cancelled"
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Del("Content-Length")
+		if _, err := w.ResponseWriter.Write([]byte(`{"error":"` + message + `"}`)); err != nil {
+			return 0, err
+		}
+	}
+	// The handler's own error body is dropped.
+	return len(data), nil
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,98 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeadlines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := map[string]time.Duration{
+		"POST /import": time.Minute,
+		"GET /changes": 0,
+	}
+
+	var deadline time.Duration // what the handler found, 0 for none
+	handler := func(c *gin.Context) {
+		deadline = 0
+		if d, ok := c.Request.Context().Deadline(); ok {
+			deadline = time.Until(d).Round(time.Second)
+		}
+		c.Status(http.StatusOK)
+	}
+	router := gin.New()
+	router.Use(Middleware(15*time.Second, routes))
+	router.GET("/books", handler)
+	router.POST("/import", handler)
+	router.GET("/changes", handler)
+
+	tests := []struct {
+		method, target string
+		want           time.Duration
+	}{
+		{http.MethodGet, "/books", 15 * time.Second},
+		{http.MethodPost, "/import", time.Minute},
+		{http.MethodGet, "/changes", 0},
+	}
+	for _, tt := range tests {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.target, nil))
+		if deadline != tt.want {
+			t.Errorf("%s %s: deadline in %v, want %v", tt.method, tt.target, deadline, tt.want)
+		}
+	}
+}
+
+func TestErrorsAfterTheDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(20*time.Millisecond, nil))
+	router.GET("/books/:outcome", func(c *gin.Context) {
+		switch c.Param("outcome") {
+		case "failed":
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		case "slow-failure":
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		case "slow-success":
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusOK, gin.H{"title": "Dune"})
+		case "not-found":
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+	})
+
+	tests := []struct {
+		name      string
+		target    string
+		cancelled bool
+		status    int
+		body      string
+	}{
+		{"error in time", "/books/failed", false, http.StatusInternalServerError, `{"error":"Failed to fetch books"}`},
+		{"error after the deadline", "/books/slow-failure", false, http.StatusGatewayTimeout, `{"error":"Request timed out"}`},
+		{"error after the client left", "/books/slow-failure", true, http.StatusServiceUnavailable, `{"error":"Request cancelled"}`},
+		{"success after the deadline", "/books/slow-success", false, http.StatusOK, `{"title":"Dune"}`},
+		{"client error after the deadline", "/books/not-found", false, http.StatusNotFound, `{"error":"Book not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cancelled {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status || w.Body.String() != tt.body {
+				t.Errorf("got %d %s, want %d %s", w.Code, w.Body, tt.status, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrHistoryLost is returned when a stream cannot resume after a token
+	// because the changes since then are no longer kept.
+	ErrHistoryLost = errors.New("events: changes after the resume token are no longer available")
+	// ErrInvalidToken is returned for resume tokens a source did not issue.
+	ErrInvalidToken = errors.New("events: invalid resume token")
+)
+
+// Change is an event together with the token to resume a stream after it.
+type Change struct {
+	Token string
+	Event Event
+}
+
+// Source is a live stream of changes that a client can resume.
+type Source interface {
+	// Watch returns the changes after the one with the given token, or the
+	// changes from now on when token is empty.
+	Watch(ctx context.Context, token string) (Cursor, error)
+}
+
+// Cursor reads a stream opened by Source.Watch.
+type Cursor interface {
+	// Next blocks until the next change or until ctx is done.
+	Next(ctx context.Context) (Change, error)
+	Close(ctx context.Context) error
+}
+
+// Bus is an in-process Publisher and Source. It keeps the last events in
+// memory so that clients can resume after a short disconnect, but it only
+// sees the events published in this process.
+type Bus struct {
+	// instance tells tokens of this process apart from those of an earlier
+	// one, whose sequence numbers mean nothing here.
+	instance string
+
+	mu      sync.Mutex
+	seq     uint64
+	history []Change // the last events, oldest first
+	size    int
+	changed chan struct{} // closed and replaced on every publish
+}
+
+// NewBus returns a bus that keeps the last size events.
+func NewBus(size int) *Bus {
+	return &Bus{
+		instance: primitive.NewObjectID().Hex(),
+		size:     size,
+		changed:  make(chan struct{}),
+	}
+}
+
+// Publish adds event to the bus. An event that is still in the history is
+// not added again.
+func (b *Bus) Publish(_ context.Context, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, change := range b.history {
+		if change.Event.ID == event.ID {
+			return nil
+		}
+	}
+
+	b.seq++
+	b.history = append(b.history, Change{Token: b.instance + "-" + strconv.FormatUint(b.seq, 10), Event: event})
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+	return nil
+}
+
+func (b *Bus) Watch(_ context.Context, token string) (Cursor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if token == "" {
+		return &busCursor{bus: b, last: b.seq}, nil
+	}
+
+	instance, raw, ok := strings.Cut(token, "-")
+	last, err := strconv.ParseUint(raw, 10, 64)
+	if !ok || err != nil {
+		return nil, ErrInvalidToken
+	}
+	if instance != b.instance || last > b.seq || last < b.oldest()-1 {
+		return nil, ErrHistoryLost
+	}
+	return &busCursor{bus: b, last: last}, nil
+}
+
+// oldest returns the sequence number of the oldest kept event. b.mu must
+// be held.
+func (b *Bus) oldest() uint64 {
+	return b.seq - uint64(len(b.history)) + 1
+}
+
+type busCursor struct {
+	bus  *Bus
+	last uint64
+}
+
+func (c *busCursor) Next(ctx context.Context) (Change, error) {
+	for {
+		c.bus.mu.Lock()
+		b := c.bus
+		if c.last < b.seq {
+			if c.last < b.oldest()-1 {
+				// The reader fell further behind than the bus remembers.
+				b.mu.Unlock()
+				return Change{}, ErrHistoryLost
+			}
+			change := b.history[len(b.history)-int(b.seq-c.last)]
+			c.last++
+			b.mu.Unlock()
+			return change, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Change{}, ctx.Err()
+		}
+	}
+}
+
+func (c *busCursor) Close(context.Context) error {
+	return nil
+}
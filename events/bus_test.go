@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func publish(t *testing.T, bus *Bus, types ...Type) []Event {
+	t.Helper()
+	published := make([]Event, len(types))
+	for i, typ := range types {
+		event, err := New(typ, primitive.NewObjectID(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bus.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		published[i] = event
+	}
+	return published
+}
+
+// next reads one change, failing the test if none is ready.
+func next(t *testing.T, cursor Cursor) (Change, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return cursor.Next(ctx)
+}
+
+func TestBusWatch(t *testing.T) {
+	bus := NewBus(3)
+	all, err := bus.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := publish(t, bus, BookCreated, BookUpdated, AuthorCreated, BookDeleted, AuthorDeleted)
+	// The bus keeps the last three events; the stream opened before them
+	// has fallen behind after reading none.
+	if _, err := next(t, all); !errors.Is(err, ErrHistoryLost) {
+		t.Fatalf("reading a stream that fell behind: %v, want ErrHistoryLost", err)
+	}
+
+	// Tokens of the kept events, read from a stream resumed at the oldest
+	// one that can still be resumed after.
+	last, err := bus.Watch(context.Background(), bus.instance+"-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := map[string]string{}
+	for _, event := range published[2:] {
+		change, err := next(t, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if change.Event.ID != event.ID {
+			t.Fatalf("got %s, want %s", change.Event.Type, event.Type)
+		}
+		tokens[string(event.Type)] = change.Token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+		want  []Event // the changes the stream starts with
+	}{
+		{"from now on", "", nil, nil},
+		{"after the oldest kept", tokens[string(AuthorCreated)], nil, published[3:]},
+		{"after the newest", tokens[string(AuthorDeleted)], nil, nil},
+		{"after a dropped event", bus.instance + "-1", ErrHistoryLost, nil},
+		{"from the future", bus.instance + "-9", ErrHistoryLost, nil},
+		{"from an earlier process", primitive.NewObjectID().Hex() + "-4", ErrHistoryLost, nil},
+		{"not a token", "yesterday", ErrInvalidToken, nil},
+		{"bad sequence number", bus.instance + "-x", ErrInvalidToken, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := bus.Watch(context.Background(), tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Watch = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer cursor.Close(context.Background())
+			for _, event := range tt.want {
+				change, err := next(t, cursor)
+				if err != nil || change.Event.ID != event.ID {
+					t.Fatalf("got %+v, %v, want %s", change, err, event.Type)
+				}
+			}
+			// Nothing else is pending.
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if change, err := cursor.Next(ctx); err != context.DeadlineExceeded {
+				t.Errorf("got %+v, %v, want no more changes", change, err)
+			}
+		})
+	}
+}
+
+func TestBusWakesWaitingReaders(t *testing.T) {
+	bus := NewBus(10)
+	cursor, err := bus.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan Change)
+	go func() {
+		change, _ := next(t, cursor)
+		received <- change
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	published := publish(t, bus, BookCreated)
+	// A redelivered event is not published twice.
+	if err := bus.Publish(context.Background(), published[0]); err != nil {
+		t.Fatal(err)
+	}
+	if change := <-received; change.Event.ID != published[0].ID {
+		t.Fatalf("woke with %+v, want %s", change, published[0].ID)
+	}
+	if bus.seq != 1 {
+		t.Errorf("%d events on the bus, want 1", bus.seq)
+	}
+}
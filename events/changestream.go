@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// Server error codes of change streams that cannot be resumed.
+const (
+	changeStreamFatalError  = 280
+	changeStreamHistoryLost = 286
+)
+
+// ChangeStream is a Source backed by MongoDB change streams. It sees every
+// write to the book and author collections, whichever client made it, and
+// uses the change stream resume tokens as its own.
+type ChangeStream struct {
+	db *mongo.Database
+}
+
+func NewChangeStream(db *mongo.Client) *ChangeStream {
+	return &ChangeStream{db: db.Database(config.Current.Database.Name)}
+}
+
+func (s *ChangeStream) Watch(ctx context.Context, token string) (Cursor, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"ns.coll":       bson.M{"$in": bson.A{config.Current.Database.Collections.Books, config.Current.Database.Collections.Authors}},
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if token != "" {
+		if _, err := hex.DecodeString(token); err != nil {
+			return nil, ErrInvalidToken
+		}
+		opts.SetStartAfter(bson.M{"_data": token})
+	}
+
+	stream, err := s.db.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, changeStreamError(err)
+	}
+	return &changeStreamCursor{stream: stream}, nil
+}
+
+func changeStreamError(err error) error {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && (serverErr.HasErrorCode(changeStreamHistoryLost) || serverErr.HasErrorCode(changeStreamFatalError)) {
+		return ErrHistoryLost
+	}
+	return err
+}
+
+type changeStreamCursor struct {
+	stream *mongo.ChangeStream
+}
+
+// changeEvent is the part of a change stream document the cursor reads.
+type changeEvent struct {
+	ID            bson.Raw            `bson:"_id"`
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Namespace     struct {
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument bson.Raw `bson:"fullDocument"`
+}
+
+func (c *changeStreamCursor) Next(ctx context.Context) (Change, error) {
+	if !c.stream.Next(ctx) {
+		if err := c.stream.Err(); err != nil {
+			return Change{}, changeStreamError(err)
+		}
+		return Change{}, ctx.Err()
+	}
+
+	var change changeEvent
+	if err := c.stream.Decode(&change); err != nil {
+		return Change{}, err
+	}
+	token, _ := change.ID.Lookup("_data").StringValueOK()
+
+	// Event types name the resource, whatever its collection is called.
+	resource := "author"
+	if change.Namespace.Coll == config.Current.Database.Collections.Books {
+		resource = "book"
+	}
+
+	var eventType Type
+	switch change.OperationType {
+	case "insert":
+		eventType = Type(resource + ".created")
+	case "update", "replace":
+		eventType = Type(resource + ".updated")
+	default:
+		eventType = Type(resource + ".deleted")
+	}
+
+	// Updates carry the document as it is when the change is read, which
+	// is missing when it has been deleted since.
+	var data interface{}
+	if len(change.FullDocument) > 0 {
+		var err error
+		if data, err = decodeDocument(resource, change.FullDocument); err != nil {
+			return Change{}, err
+		}
+	}
+
+	event, err := New(eventType, change.DocumentKey.ID, data)
+	if err != nil {
+		return Change{}, err
+	}
+	// The token is unique per change, so it also serves as the event ID and
+	// an event read twice is recognisable as the same.
+	event.ID = token
+	if change.ClusterTime.T != 0 {
+		event.Time = time.Unix(int64(change.ClusterTime.T), 0).UTC()
+	}
+	return Change{Token: token, Event: event}, nil
+}
+
+func (c *changeStreamCursor) Close(ctx context.Context) error {
+	return c.stream.Close(ctx)
+}
+
+// decodeDocument decodes a stored book or author, so that events from the
+// change stream look the same as those published by the API.
+func decodeDocument(resource string, raw bson.Raw) (interface{}, error) {
+	if resource == "book" {
+		var book models.Book
+		err := bson.Unmarshal(raw, &book)
+		return book, err
+	}
+	var author models.Author
+	err := bson.Unmarshal(raw, &author)
+	return author, err
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Type names a change to the catalog, as "<resource>.<action>".
+type Type string
+
+const (
+	BookCreated   Type = "book.created"
+	BookUpdated   Type = "book.updated"
+	BookDeleted   Type = "book.deleted"
+	AuthorCreated Type = "author.created"
+	AuthorUpdated Type = "author.updated"
+	AuthorDeleted Type = "author.deleted"
+)
+
+// Types lists every event type.
+var Types = []Type{BookCreated, BookUpdated, BookDeleted, AuthorCreated, AuthorUpdated, AuthorDeleted}
+
+// Valid reports whether t is a known event type.
+func Valid(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a change to one book or author. ID is unique per event, so
+// receivers can drop duplicates. Data holds the document as stored after the
+// change; it is empty for deletions.
+type Event struct {
+	ID      string          `json:"id" bson:"id"`
+	Type    Type            `json:"type" bson:"type"`
+	Subject string          `json:"subject" bson:"subject"`
+	Time    time.Time       `json:"time" bson:"time"`
+	Data    json.RawMessage `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+// New returns an event of type t about the document with the given ID. data
+// is encoded as JSON once, so every receiver sees the same bytes.
+func New(t Type, subject primitive.ObjectID, data interface{}) (Event, error) {
+	event := Event{
+		ID:      primitive.NewObjectID().Hex(),
+		Type:    t,
+		Subject: subject.Hex(),
+		Time:    time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return event, err
+		}
+		event.Data = raw
+	}
+	return event, nil
+}
+
+// Publisher hands events to whoever is interested in them.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Discard is a Publisher that drops every event.
+var Discard Publisher = discard{}
+
+type discard struct{}
+
+func (discard) Publish(context.Context, Event) error { return nil }
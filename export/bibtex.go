@@ -0,0 +1,75 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func init() {
+	register(Format{
+		Name:        "bibtex",
+		ContentType: "application/x-bibtex; charset=utf-8",
+		Extension:   "bib",
+		New:         func(w io.Writer) Encoder { return &bibtexEncoder{w: w} },
+	})
+}
+
+type bibtexEncoder struct {
+	w io.Writer
+}
+
+func (e *bibtexEncoder) Begin() error { return nil }
+
+func (e *bibtexEncoder) Encode(record models.BookWithAuthor) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@book{%s,\n", bibtexKey(record))
+	fmt.Fprintf(&b, "  title = {%s},\n", bibtexEscape(record.Title))
+	if author := authorName(record); author != "" {
+		fmt.Fprintf(&b, "  author = {%s},\n", bibtexEscape(author))
+	}
+	if record.ISBN != "" {
+		fmt.Fprintf(&b, "  isbn = {%s},\n", record.ISBN)
+	}
+	b.WriteString("}\n\n")
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *bibtexEncoder) End() error { return nil }
+
+// bibtexKey builds a citation key from the author's last name and the book ID,
+// which keeps keys unique across the catalog.
+func bibtexKey(record models.BookWithAuthor) string {
+	prefix := "book"
+	if record.Author != nil && record.Author.LastName != "" {
+		prefix = strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, record.Author.LastName)
+	}
+	return prefix + ":" + record.ID.Hex()
+}
+
+var bibtexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func bibtexEscape(s string) string {
+	return bibtexReplacer.Replace(s)
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func init() {
+	register(Format{
+		Name:        "csv",
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		New:         func(w io.Writer) Encoder { return &csvEncoder{w: csv.NewWriter(w)} },
+	})
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Begin() error {
+	return e.w.Write([]string{"id", "title", "isbn", "authorId", "authorFirstName", "authorLastName"})
+}
+
+func (e *csvEncoder) Encode(record models.BookWithAuthor) error {
+	var firstName, lastName string
+	if record.Author != nil {
+		firstName, lastName = record.Author.FirstName, record.Author.LastName
+	}
+	authorID := ""
+	if !record.AuthorID.IsZero() {
+		authorID = record.AuthorID.Hex()
+	}
+	return e.w.Write([]string{record.ID.Hex(), record.Title, record.ISBN, authorID, firstName, lastName})
+}
+
+func (e *csvEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
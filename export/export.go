@@ -0,0 +1,65 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// Encoder writes books in one export format. Begin is called once before the
+// first record and End once after the last one.
+type Encoder interface {
+	Begin() error
+	Encode(record models.BookWithAuthor) error
+	End() error
+}
+
+// Format describes a supported export format.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) Encoder
+}
+
+var formats = map[string]Format{}
+
+func register(format Format) {
+	formats[format.Name] = format
+}
+
+// Lookup returns the export format with the given name.
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unsupported export format %q", name)
+	}
+	return format, nil
+}
+
+// Names lists the supported export formats.
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// authorName returns the author of a record as "Last, First", the form
+// reference managers expect.
+func authorName(record models.BookWithAuthor) string {
+	if record.Author == nil {
+		return ""
+	}
+	switch {
+	case record.Author.LastName == "":
+		return record.Author.FirstName
+	case record.Author.FirstName == "":
+		return record.Author.LastName
+	}
+	return record.Author.LastName + ", " + record.Author.FirstName
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func objectID(hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// records are a book with an author whose name needs escaping and one
+// without an author.
+var records = []models.BookWithAuthor{
+	{
+		Book: models.Book{
+			ID:       objectID("64b7f0c2a1b2c3d4e5f60718"),
+			Title:    "Fish & Chips: 100% {British}",
+			ISBN:     "9780000000011",
+			AuthorID: objectID("64b7f0c2a1b2c3d4e5f60001"),
+		},
+		Author: &models.Author{ID: objectID("64b7f0c2a1b2c3d4e5f60001"), FirstName: "Zoë", LastName: "O'Brien-Smith"},
+	},
+	{
+		Book: models.Book{ID: objectID("64b7f0c2a1b2c3d4e5f60719"), Title: "Beowulf,\n\"the\" epic"},
+	},
+}
+
+func encode(t *testing.T, name string) string {
+	t.Helper()
+	format, err := Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := format.New(&buf)
+	if err := enc.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.End(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTextFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "id,title,isbn,authorId,authorFirstName,authorLastName\n" +
+			"64b7f0c2a1b2c3d4e5f60718,Fish & Chips: 100% {British},9780000000011,64b7f0c2a1b2c3d4e5f60001,Zoë,O'Brien-Smith\n" +
+			"64b7f0c2a1b2c3d4e5f60719,\"Beowulf,\n\"\"the\"\" epic\",,,,\n"},
+		{"bibtex", "@book{obriensmith:64b7f0c2a1b2c3d4e5f60718,\n" +
+			"  title = {Fish \\& Chips: 100\\% \\{British\\}},\n" +
+			"  author = {O'Brien-Smith, Zoë},\n" +
+			"  isbn = {9780000000011},\n" +
+			"}\n\n" +
+			"@book{book:64b7f0c2a1b2c3d4e5f60719,\n" +
+			"  title = {Beowulf,\n\"the\" epic},\n" +
+			"}\n\n"},
+		{"ris", "TY  - BOOK\r\nID  - 64b7f0c2a1b2c3d4e5f60718\r\nTI  - Fish & Chips: 100% {British}\r\nAU  - O'Brien-Smith, Zoë\r\nSN  - 9780000000011\r\nER  - \r\n\r\n" +
+			"TY  - BOOK\r\nID  - 64b7f0c2a1b2c3d4e5f60719\r\nTI  - Beowulf, \"the\" epic\r\nER  - \r\n\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := encode(t, tt.format); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader(encode(t, "ndjson")))
+	var got []models.BookWithAuthor
+	for scanner.Scan() {
+		var record models.BookWithAuthor
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %d: %v", len(got)+1, err)
+		}
+		got = append(got, record)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("decoded %+v, want %+v", got, records)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if got, want := Names(), []string{"bibtex", "csv", "marc", "marcxml", "ndjson", "ris"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	for _, name := range Names() {
+		format, err := Lookup(name)
+		if err != nil || format.ContentType == "" || format.Extension == "" {
+			t.Errorf("Lookup(%q) = %+v, %v", name, format, err)
+		}
+	}
+	if _, err := Lookup("pdf"); err == nil {
+		t.Error("Lookup of an unknown format succeeded")
+	}
+}
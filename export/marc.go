@@ -0,0 +1,49 @@
+package export
+
+import (
+	"io"
+
+	"github.com/saifujnu/books-authors/marc"
+	"github.com/saifujnu/books-authors/models"
+)
+
+func init() {
+	register(Format{
+		Name:        "marc",
+		ContentType: "application/marc",
+		Extension:   "mrc",
+		New:         func(w io.Writer) Encoder { return &marcEncoder{w: marc.NewWriter(w)} },
+	})
+	register(Format{
+		Name:        "marcxml",
+		ContentType: "application/marcxml+xml; charset=utf-8",
+		Extension:   "xml",
+		New:         func(w io.Writer) Encoder { return &marcXMLEncoder{w: marc.NewXMLWriter(w)} },
+	})
+}
+
+type marcEncoder struct {
+	w *marc.Writer
+}
+
+func (e *marcEncoder) Begin() error { return nil }
+
+func (e *marcEncoder) Encode(record models.BookWithAuthor) error {
+	return e.w.Write(marc.FromEntry(record.Book, record.Author))
+}
+
+func (e *marcEncoder) End() error { return nil }
+
+type marcXMLEncoder struct {
+	w *marc.XMLWriter
+}
+
+func (e *marcXMLEncoder) Begin() error { return nil }
+
+func (e *marcXMLEncoder) Encode(record models.BookWithAuthor) error {
+	return e.w.Write(marc.FromEntry(record.Book, record.Author))
+}
+
+func (e *marcXMLEncoder) End() error {
+	return e.w.Close()
+}
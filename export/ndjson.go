@@ -0,0 +1,30 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func init() {
+	register(Format{
+		Name:        "ndjson",
+		ContentType: "application/x-ndjson",
+		Extension:   "ndjson",
+		New:         func(w io.Writer) Encoder { return &ndjsonEncoder{enc: json.NewEncoder(w)} },
+	})
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Begin() error { return nil }
+
+// Encode writes the record as a single line; json.Encoder ends it with "\n".
+func (e *ndjsonEncoder) Encode(record models.BookWithAuthor) error {
+	return e.enc.Encode(record)
+}
+
+func (e *ndjsonEncoder) End() error { return nil }
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func init() {
+	register(Format{
+		Name:        "ris",
+		ContentType: "application/x-research-info-systems; charset=utf-8",
+		Extension:   "ris",
+		New:         func(w io.Writer) Encoder { return &risEncoder{w: w} },
+	})
+}
+
+// risEncoder writes RIS records; tags are followed by two spaces, a dash and
+// a space, and lines end with CRLF as the format requires.
+type risEncoder struct {
+	w io.Writer
+}
+
+func (e *risEncoder) Begin() error { return nil }
+
+func (e *risEncoder) Encode(record models.BookWithAuthor) error {
+	var b strings.Builder
+	tag := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s  - %s\r\n", name, strings.ReplaceAll(value, "\n", " "))
+		}
+	}
+
+	tag("TY", "BOOK")
+	tag("ID", record.ID.Hex())
+	tag("TI", record.Title)
+	tag("AU", authorName(record))
+	tag("SN", record.ISBN)
+	b.WriteString("ER  - \r\n\r\n")
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *risEncoder) End() error { return nil }
@@ -0,0 +1,91 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const (
+	AtomNamespace        = "http://www.w3.org/2005/Atom"
+	DublinCoreNamespace  = "http://purl.org/dc/terms/"
+	OPDSNamespace        = "http://opds-spec.org/2010/catalog"
+	OpenSearchNamespace  = "http://a9.com/-/spec/opensearch/1.1/"
+	AtomContentType      = "application/atom+xml; charset=utf-8"
+	OpenSearchDescriptor = "application/opensearchdescription+xml"
+)
+
+// Feed is an Atom 1.0 feed. The Dublin Core and OPDS namespaces are always
+// declared so entries can carry book metadata.
+type Feed struct {
+	XMLName      xml.Name `xml:"feed"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	XmlnsDC      string   `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string   `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string   `xml:"xmlns:opensearch,attr"`
+	ID           string   `xml:"id"`
+	Title        string   `xml:"title"`
+	Updated      string   `xml:"updated"`
+	Author       *Person  `xml:"author,omitempty"`
+	Links        []Link   `xml:"link"`
+	TotalResults int64    `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int      `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int64    `xml:"opensearch:startIndex,omitempty"`
+	Entries      []Entry  `xml:"entry"`
+}
+
+// NewFeed returns a feed with the namespaces declared.
+func NewFeed(id, title string, updated time.Time) *Feed {
+	return &Feed{
+		Xmlns:       AtomNamespace,
+		XmlnsDC:     DublinCoreNamespace,
+		XmlnsOPDS:   OPDSNamespace,
+		XmlnsSearch: OpenSearchNamespace,
+		ID:          id,
+		Title:       title,
+		Updated:     FormatTime(updated),
+	}
+}
+
+type Entry struct {
+	ID         string   `xml:"id"`
+	Title      string   `xml:"title"`
+	Updated    string   `xml:"updated"`
+	Published  string   `xml:"published,omitempty"`
+	Authors    []Person `xml:"author"`
+	Content    *Text    `xml:"content,omitempty"`
+	Links      []Link   `xml:"link"`
+	Identifier []string `xml:"dc:identifier,omitempty"`
+	Publisher  string   `xml:"dc:publisher,omitempty"`
+	Issued     string   `xml:"dc:issued,omitempty"`
+}
+
+type Person struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type Text struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// FormatTime formats t as an RFC 3339 timestamp in UTC, as Atom requires.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Marshal renders the feed with an XML declaration.
+func (f *Feed) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
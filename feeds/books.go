@@ -0,0 +1,88 @@
+package feeds
+
+import (
+	"time"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// BookID returns the permanent Atom ID of a book.
+func BookID(book models.Book) string {
+	return "urn:books-authors:book:" + book.ID.Hex()
+}
+
+// AuthorDisplayName returns "First Last".
+func AuthorDisplayName(author models.Author) string {
+	if author.LastName == "" {
+		return author.FirstName
+	}
+	if author.FirstName == "" {
+		return author.LastName
+	}
+	return author.FirstName + " " + author.LastName
+}
+
+// BookUpdated returns when a book was last changed.
+func BookUpdated(book models.Book) time.Time {
+	return book.LastModified()
+}
+
+// BookPublished returns when a book was added to the catalog.
+func BookPublished(book models.Book) time.Time {
+	return models.CreationTime(book.ID, book.CreatedAt)
+}
+
+// BookEntry builds the Atom entry of a book. authorHref, when set, links the
+// author element to the author's feed.
+func BookEntry(record models.BookWithAuthor, links []Link, authorHref string) Entry {
+	entry := Entry{
+		ID:        BookID(record.Book),
+		Title:     record.Title,
+		Updated:   FormatTime(BookUpdated(record.Book)),
+		Published: FormatTime(BookPublished(record.Book)),
+		Links:     links,
+	}
+	if record.Author != nil {
+		entry.Authors = append(entry.Authors, Person{Name: AuthorDisplayName(*record.Author), URI: authorHref})
+	}
+	for _, contributor := range record.Contributors {
+		entry.Authors = append(entry.Authors, Person{Name: contributor.Name})
+	}
+	if record.ISBN != "" {
+		entry.Identifier = append(entry.Identifier, "urn:isbn:"+record.ISBN)
+	}
+	if pub := record.Publication; pub != nil {
+		entry.Publisher = pub.Publisher
+		entry.Issued = pub.Date
+	}
+	return entry
+}
+
+// BookPublication builds the OPDS 2.0 publication of a book.
+func BookPublication(record models.BookWithAuthor, links []OPDS2Link, authorHref string) OPDS2Publication {
+	metadata := OPDS2PublicationMetadata{
+		Type:     "http://schema.org/Book",
+		Title:    record.Title,
+		Modified: FormatTime(BookUpdated(record.Book)),
+	}
+	if record.ISBN != "" {
+		metadata.Identifier = "urn:isbn:" + record.ISBN
+	}
+	if record.Author != nil {
+		author := OPDS2Contributor{Name: AuthorDisplayName(*record.Author)}
+		if authorHref != "" {
+			author.Links = []OPDS2Link{{Href: authorHref, Type: OPDS2Type}}
+		}
+		metadata.Author = append(metadata.Author, author)
+	}
+	for _, contributor := range record.Contributors {
+		metadata.Contributor = append(metadata.Contributor, OPDS2Contributor{Name: contributor.Name, Role: contributor.Role})
+	}
+	if pub := record.Publication; pub != nil {
+		if pub.Publisher != "" {
+			metadata.Publisher = []OPDS2Contributor{{Name: pub.Publisher}}
+		}
+		metadata.Published = pub.Date
+	}
+	return OPDS2Publication{Metadata: metadata, Links: links}
+}
@@ -0,0 +1,148 @@
+package feeds
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func objectID(hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+var (
+	created = time.Date(2023, 7, 19, 10, 0, 0, 0, time.UTC)
+	updated = time.Date(2024, 2, 1, 8, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	// dune has an author, a contributor, an ISBN and an imprint.
+	dune = models.BookWithAuthor{
+		Book: models.Book{
+			ID:           objectID("64b7f0c2a1b2c3d4e5f60718"),
+			Title:        "Dune",
+			ISBN:         "9780441013593",
+			Contributors: []models.Contributor{{Name: "Schoenherr, John", Role: "illustrator"}},
+			Publication:  &models.Publication{Publisher: "Ace", Date: "1990"},
+			CreatedAt:    created,
+			UpdatedAt:    updated,
+		},
+		Author: &models.Author{ID: objectID("64b7f0c2a1b2c3d4e5f60001"), FirstName: "Frank", LastName: "Herbert"},
+	}
+	// beowulf has only an ID, so its times come from the ID.
+	beowulf = models.BookWithAuthor{Book: models.Book{ID: objectID("64b7f0c2a1b2c3d4e5f60719"), Title: "Beowulf"}}
+)
+
+func TestAuthorDisplayName(t *testing.T) {
+	tests := []struct {
+		author models.Author
+		want   string
+	}{
+		{models.Author{FirstName: "Frank", LastName: "Herbert"}, "Frank Herbert"},
+		{models.Author{FirstName: "Homer"}, "Homer"},
+		{models.Author{LastName: "Anonymous"}, "Anonymous"},
+	}
+	for _, tt := range tests {
+		if got := AuthorDisplayName(tt.author); got != tt.want {
+			t.Errorf("AuthorDisplayName(%+v) = %q, want %q", tt.author, got, tt.want)
+		}
+	}
+}
+
+func TestBookEntry(t *testing.T) {
+	links := []Link{{Rel: RelAcquisition, Href: "/books/64b7f0c2a1b2c3d4e5f60718"}}
+	idTime := FormatTime(beowulf.ID.Timestamp())
+
+	tests := []struct {
+		name       string
+		record     models.BookWithAuthor
+		authorHref string
+		want       Entry
+	}{
+		{
+			name: "full record", record: dune, authorHref: "/opds/authors/64b7f0c2a1b2c3d4e5f60001",
+			want: Entry{
+				ID:        "urn:books-authors:book:64b7f0c2a1b2c3d4e5f60718",
+				Title:     "Dune",
+				Updated:   "2024-02-01T07:30:00Z",
+				Published: "2023-07-19T10:00:00Z",
+				Authors: []Person{
+					{Name: "Frank Herbert", URI: "/opds/authors/64b7f0c2a1b2c3d4e5f60001"},
+					{Name: "Schoenherr, John"},
+				},
+				Links:      links,
+				Identifier: []string{"urn:isbn:9780441013593"},
+				Publisher:  "Ace",
+				Issued:     "1990",
+			},
+		},
+		{
+			name: "bare record", record: beowulf,
+			want: Entry{
+				ID:        "urn:books-authors:book:64b7f0c2a1b2c3d4e5f60719",
+				Title:     "Beowulf",
+				Updated:   idTime,
+				Published: idTime,
+				Links:     links,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BookEntry(tt.record, links, tt.authorHref); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BookEntry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookPublication(t *testing.T) {
+	links := []OPDS2Link{{Rel: RelAcquisition, Href: "/books/64b7f0c2a1b2c3d4e5f60718"}}
+
+	tests := []struct {
+		name       string
+		record     models.BookWithAuthor
+		authorHref string
+		want       OPDS2PublicationMetadata
+	}{
+		{
+			name: "full record", record: dune, authorHref: "/opds/v2/authors/64b7f0c2a1b2c3d4e5f60001",
+			want: OPDS2PublicationMetadata{
+				Type:        "http://schema.org/Book",
+				Title:       "Dune",
+				Identifier:  "urn:isbn:9780441013593",
+				Author:      []OPDS2Contributor{{Name: "Frank Herbert", Links: []OPDS2Link{{Href: "/opds/v2/authors/64b7f0c2a1b2c3d4e5f60001", Type: OPDS2Type}}}},
+				Contributor: []OPDS2Contributor{{Name: "Schoenherr, John", Role: "illustrator"}},
+				Publisher:   []OPDS2Contributor{{Name: "Ace"}},
+				Published:   "1990",
+				Modified:    "2024-02-01T07:30:00Z",
+			},
+		},
+		{
+			name: "author without link", record: models.BookWithAuthor{Book: beowulf.Book, Author: dune.Author},
+			want: OPDS2PublicationMetadata{
+				Type:     "http://schema.org/Book",
+				Title:    "Beowulf",
+				Author:   []OPDS2Contributor{{Name: "Frank Herbert"}},
+				Modified: FormatTime(beowulf.ID.Timestamp()),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BookPublication(tt.record, links, tt.authorHref)
+			if !reflect.DeepEqual(got.Metadata, tt.want) {
+				t.Errorf("metadata = %+v, want %+v", got.Metadata, tt.want)
+			}
+			if !reflect.DeepEqual(got.Links, links) {
+				t.Errorf("links = %+v, want %+v", got.Links, links)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package feeds
+
+// OPDS 1.2 media types and link relations.
+const (
+	OPDSNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	OPDSAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+
+	RelStart       = "start"
+	RelSelf        = "self"
+	RelUp          = "up"
+	RelFirst       = "first"
+	RelPrevious    = "previous"
+	RelNext        = "next"
+	RelLast        = "last"
+	RelSearch      = "search"
+	RelSubsection  = "subsection"
+	RelAlternate   = "alternate"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+)
+
+// OPDS 2.0 media types.
+const (
+	OPDS2Type        = "application/opds+json"
+	OPDS2PubType     = "application/opds-publication+json"
+	OPDS2ContentType = "application/opds+json; charset=utf-8"
+)
+
+// OPDS2Feed is an OPDS 2.0 catalog feed.
+type OPDS2Feed struct {
+	Metadata     OPDS2Metadata      `json:"metadata"`
+	Links        []OPDS2Link        `json:"links"`
+	Navigation   []OPDS2Link        `json:"navigation,omitempty"`
+	Publications []OPDS2Publication `json:"publications,omitempty"`
+}
+
+type OPDS2Metadata struct {
+	Title         string `json:"title"`
+	NumberOfItems int64  `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type OPDS2Link struct {
+	Href      string `json:"href"`
+	Type      string `json:"type,omitempty"`
+	Rel       string `json:"rel,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+type OPDS2Publication struct {
+	Metadata OPDS2PublicationMetadata `json:"metadata"`
+	Links    []OPDS2Link              `json:"links"`
+}
+
+type OPDS2PublicationMetadata struct {
+	Type        string             `json:"@type"`
+	Title       string             `json:"title"`
+	Identifier  string             `json:"identifier,omitempty"`
+	Author      []OPDS2Contributor `json:"author,omitempty"`
+	Contributor []OPDS2Contributor `json:"contributor,omitempty"`
+	Publisher   []OPDS2Contributor `json:"publisher,omitempty"`
+	Published   string             `json:"published,omitempty"`
+	Modified    string             `json:"modified,omitempty"`
+}
+
+type OPDS2Contributor struct {
+	Name  string      `json:"name"`
+	Role  string      `json:"role,omitempty"`
+	Links []OPDS2Link `json:"links,omitempty"`
+}
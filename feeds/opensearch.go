@@ -0,0 +1,40 @@
+package feeds
+
+import "encoding/xml"
+
+// OpenSearchDescription describes the catalog search for OPDS clients.
+type OpenSearchDescription struct {
+	XMLName        xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	ShortName      string          `xml:"ShortName"`
+	Description    string          `xml:"Description"`
+	InputEncoding  string          `xml:"InputEncoding"`
+	OutputEncoding string          `xml:"OutputEncoding"`
+	URLs           []OpenSearchURL `xml:"Url"`
+}
+
+type OpenSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearchDescription describes a search whose results are served at
+// template, which must contain the {searchTerms} placeholder.
+func NewOpenSearchDescription(shortName, description string, urls ...OpenSearchURL) *OpenSearchDescription {
+	return &OpenSearchDescription{
+		Xmlns:          OpenSearchNamespace,
+		ShortName:      shortName,
+		Description:    description,
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URLs:           urls,
+	}
+}
+
+func (d *OpenSearchDescription) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,99 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+const (
+	DublinCoreElementsNamespace = "http://purl.org/dc/elements/1.1/"
+	RSSContentType              = "application/rss+xml; charset=utf-8"
+)
+
+// RSS is an RSS 2.0 document. The Atom namespace is declared for the
+// channel's self link and Dublin Core elements for item creators.
+type RSS struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	XmlnsAtom string     `xml:"xmlns:atom,attr"`
+	XmlnsDC   string     `xml:"xmlns:dc,attr"`
+	Channel   RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          *Link     `xml:"atom:link,omitempty"`
+	Items         []RSSItem `xml:"item"`
+}
+
+type RSSItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Creators    []string `xml:"dc:creator,omitempty"`
+	GUID        RSSGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// NewRSS returns an RSS document whose channel links to itself at selfHref.
+func NewRSS(title, link, description, selfHref string, updated time.Time) *RSS {
+	return &RSS{
+		Version:   "2.0",
+		XmlnsAtom: AtomNamespace,
+		XmlnsDC:   DublinCoreElementsNamespace,
+		Channel: RSSChannel{
+			Title:         title,
+			Link:          link,
+			Description:   description,
+			LastBuildDate: FormatRSSTime(updated),
+			Self:          &Link{Rel: RelSelf, Href: selfHref, Type: RSSContentType},
+		},
+	}
+}
+
+// FormatRSSTime formats t as an RFC 1123 date with a numeric zone, as RSS
+// requires.
+func FormatRSSTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+// Marshal renders the document with an XML declaration.
+func (r *RSS) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// BookItem builds the RSS item of a book. The GUID is the book's Atom ID, so
+// a book keeps its identity across both feeds; pubDate is its last change so
+// that readers pick up updates.
+func BookItem(record models.BookWithAuthor, link string) RSSItem {
+	item := RSSItem{
+		Title:   record.Title,
+		Link:    link,
+		GUID:    RSSGUID{Value: BookID(record.Book)},
+		PubDate: FormatRSSTime(BookUpdated(record.Book)),
+	}
+	if record.Author != nil {
+		item.Creators = append(item.Creators, AuthorDisplayName(*record.Author))
+	}
+	for _, contributor := range record.Contributors {
+		item.Creators = append(item.Creators, contributor.Name)
+	}
+	if record.ISBN != "" {
+		item.Description = "ISBN " + record.ISBN
+	}
+	return item
+}
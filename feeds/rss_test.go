@@ -0,0 +1,89 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func TestBookItem(t *testing.T) {
+	tests := []struct {
+		name   string
+		record models.BookWithAuthor
+		want   RSSItem
+	}{
+		{
+			name: "full record", record: dune,
+			want: RSSItem{
+				Title:       "Dune",
+				Link:        "https://example.org/books/64b7f0c2a1b2c3d4e5f60718",
+				Description: "ISBN 9780441013593",
+				Creators:    []string{"Frank Herbert", "Schoenherr, John"},
+				GUID:        RSSGUID{Value: "urn:books-authors:book:64b7f0c2a1b2c3d4e5f60718"},
+				PubDate:     "Thu, 01 Feb 2024 07:30:00 +0000",
+			},
+		},
+		{
+			name: "bare record", record: beowulf,
+			want: RSSItem{
+				Title:   "Beowulf",
+				Link:    "https://example.org/books/64b7f0c2a1b2c3d4e5f60718",
+				GUID:    RSSGUID{Value: "urn:books-authors:book:64b7f0c2a1b2c3d4e5f60719"},
+				PubDate: FormatRSSTime(beowulf.ID.Timestamp()),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BookItem(tt.record, "https://example.org/books/64b7f0c2a1b2c3d4e5f60718"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BookItem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedsMarshal(t *testing.T) {
+	at := time.Date(2024, 2, 1, 8, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	atom := NewFeed("urn:books-authors:books", "Books", at)
+	atom.Entries = []Entry{BookEntry(dune, nil, "")}
+	body, err := atom.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotAtom Feed
+	if err := xml.Unmarshal(body, &gotAtom); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, body)
+	}
+	if gotAtom.Updated != "2024-02-01T07:30:00Z" || len(gotAtom.Entries) != 1 || gotAtom.Entries[0].Title != "Dune" {
+		t.Errorf("Atom feed round trip = %+v", gotAtom)
+	}
+	for _, ns := range []string{`xmlns="` + AtomNamespace + `"`, `xmlns:dc="` + DublinCoreNamespace + `"`, "<dc:identifier>urn:isbn:9780441013593</dc:identifier>"} {
+		if !strings.Contains(string(body), ns) {
+			t.Errorf("Atom feed lacks %s", ns)
+		}
+	}
+
+	rss := NewRSS("Books", "https://example.org/feeds/books.atom", "Books", "https://example.org/feeds/books.rss", at)
+	rss.Channel.Items = []RSSItem{BookItem(dune, "")}
+	body, err = rss.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotRSS RSS
+	if err := xml.Unmarshal(body, &gotRSS); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, body)
+	}
+	if gotRSS.Version != "2.0" || gotRSS.Channel.LastBuildDate != "Thu, 01 Feb 2024 07:30:00 +0000" || len(gotRSS.Channel.Items) != 1 {
+		t.Errorf("RSS round trip = %+v", gotRSS)
+	}
+	for _, want := range []string{`<atom:link rel="self" href="https://example.org/feeds/books.rss"`, "<dc:creator>Frank Herbert</dc:creator>", `<guid isPermaLink="false">`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("RSS document lacks %s", want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package graphapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/outbox"
+	"github.com/saifujnu/books-authors/requestlog"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// maxDepth bounds query nesting, as author -> books -> author cycles let a
+// single query fan out without limit.
+const maxDepth = 10
+
+// Handler executes GraphQL requests.
+type Handler struct {
+	db     *mongo.Client
+	logger *zap.Logger
+	schema *graphql.Schema
+}
+
+// NewHandler parses the schema and binds it to the resolvers, which record
+// their events in outbox. It fails when the schema and resolvers disagree.
+func NewHandler(db *mongo.Client, logger *zap.Logger, outbox *outbox.Outbox) (*Handler, error) {
+	schema, err := graphql.ParseSchema(schemaSDL, &Resolver{db: db, logger: logger, outbox: outbox}, graphql.MaxDepth(maxDepth))
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{db: db, logger: logger, schema: schema}, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Serve handles POST /graphql. Errors are reported in the response body
+// with status 200, as GraphQL clients expect; only unreadable requests get
+// a 400.
+func (h *Handler) Serve(c *gin.Context) {
+	var req request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		requestlog.FromContext(c.Request.Context(), h.logger).Error("Invalid GraphQL request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := withLoaders(c.Request.Context(), newLoaders(h.db))
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	logger := requestlog.FromContext(ctx, h.logger)
+	for _, err := range response.Errors {
+		logger.Debug("GraphQL error", zap.String("operation", req.OperationName), zap.Error(err))
+	}
+	c.JSON(http.StatusOK, response)
+}
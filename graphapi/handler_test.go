@@ -0,0 +1,100 @@
+package graphapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/mongotest"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// execute runs query through handler and returns the response.
+func execute(t *testing.T, handler *Handler, query string, variables map[string]interface{}) response {
+	t.Helper()
+	body, _ := json.Marshal(request{Query: query, Variables: variables})
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.Serve(c)
+
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+	return resp
+}
+
+// field decodes the id of the object returned by the mutation name.
+func field(t *testing.T, resp response, name string) string {
+	t.Helper()
+	if len(resp.Errors) > 0 {
+		t.Fatalf("%s failed: %s", name, resp.Errors[0].Message)
+	}
+	var object struct{ ID string }
+	if err := json.Unmarshal(resp.Data[name], &object); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return object.ID
+}
+
+func TestMutationsRecordEvents(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client := mongotest.Connect(t)
+	ob := outbox.New(client, zap.NewNop(), map[string]events.Publisher{"test": events.Discard},
+		dbmongo.IsReplicated(context.Background(), client))
+	handler, err := NewHandler(client, zap.NewNop(), ob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorID := field(t, execute(t, handler, `mutation { createAuthor(input: {firstName: "Jane", lastName: "Austen"}) { id } }`, nil), "createAuthor")
+	bookID := field(t, execute(t, handler, `mutation($author: ID) { createBook(input: {title: "Emma", authorId: $author}) { id } }`,
+		map[string]interface{}{"author": authorID}), "createBook")
+	update := `mutation($id: ID!) { updateBook(id: $id, version: 1, input: {title: "Persuasion"}) { id } }`
+	field(t, execute(t, handler, update, map[string]interface{}{"id": bookID}), "updateBook")
+
+	// A stale version changes nothing and records nothing.
+	if resp := execute(t, handler, update, map[string]interface{}{"id": bookID}); len(resp.Errors) == 0 {
+		t.Error("update of a stale version succeeded")
+	}
+
+	for _, mutation := range []string{"deleteBook", "deleteAuthor"} {
+		id := bookID
+		if mutation == "deleteAuthor" {
+			id = authorID
+		}
+		resp := execute(t, handler, fmt.Sprintf(`mutation($id: ID!) { %s(id: $id) }`, mutation), map[string]interface{}{"id": id})
+		if len(resp.Errors) > 0 {
+			t.Fatalf("%s failed: %s", mutation, resp.Errors[0].Message)
+		}
+	}
+
+	var got []events.Type
+	for _, event := range mongotest.RecordedEvents(t, client) {
+		got = append(got, event.Type)
+	}
+	want := []events.Type{events.AuthorCreated, events.BookCreated, events.BookUpdated, events.BookDeleted, events.AuthorDeleted}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("recorded events %v, want %v", got, want)
+	}
+}
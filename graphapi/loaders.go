@@ -0,0 +1,88 @@
+package graphapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// errNotFound is returned by loaders for IDs that do not exist.
+var errNotFound = errors.New("not found")
+
+type loadersKey struct{}
+
+// loaders batch the lookups resolvers make while one query executes. They
+// are created per request so that no data is cached across requests.
+type loaders struct {
+	authors *dataloader.Loader[primitive.ObjectID, *models.Author]
+}
+
+func newLoaders(db *mongo.Client) *loaders {
+	authorCollection := db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+	return &loaders{
+		authors: dataloader.NewBatchedLoader(func(ctx context.Context, ids []primitive.ObjectID) []*dataloader.Result[*models.Author] {
+			return loadAuthors(ctx, authorCollection, ids)
+		}),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// loadAuthors fetches all authors of a batch with one query. Results must be
+// in the order of ids.
+func loadAuthors(ctx context.Context, collection *mongo.Collection, ids []primitive.ObjectID) []*dataloader.Result[*models.Author] {
+	results := make([]*dataloader.Result[*models.Author], len(ids))
+
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err == nil {
+		var authors []models.Author
+		if err = cursor.All(ctx, &authors); err == nil {
+			byID := make(map[primitive.ObjectID]*models.Author, len(authors))
+			for i := range authors {
+				byID[authors[i].ID] = &authors[i]
+			}
+			for i, id := range ids {
+				if author, ok := byID[id]; ok {
+					results[i] = &dataloader.Result[*models.Author]{Data: author}
+				} else {
+					results[i] = &dataloader.Result[*models.Author]{Error: errNotFound}
+				}
+			}
+			return results
+		}
+	}
+
+	for i := range results {
+		results[i] = &dataloader.Result[*models.Author]{Error: err}
+	}
+	return results
+}
+
+// loadAuthor resolves an author through the request's loader. A missing
+// author is not an error; the field is null.
+func (r *Resolver) loadAuthor(ctx context.Context, id primitive.ObjectID) (*authorResolver, error) {
+	if id.IsZero() {
+		return nil, nil
+	}
+	author, err := loadersFrom(ctx).authors.Load(ctx, id)()
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &authorResolver{root: r, author: *author}, nil
+}
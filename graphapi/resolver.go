@@ -0,0 +1,510 @@
+package graphapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin/binding"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
+	"github.com/saifujnu/books-authors/requestlog"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	errBookNotFound   = errors.New("book not found")
+	errAuthorNotFound = errors.New("author not found")
+	errModified       = errors.New("document has been modified")
+)
+
+// Resolver is the root resolver of the schema. It reads and writes the same
+// collections as the REST controllers and keeps their versioning rules. Its
+// writes record their events in the outbox, as theirs do.
+type Resolver struct {
+	db     *mongo.Client
+	logger *zap.Logger
+	outbox *outbox.Outbox
+}
+
+func (r *Resolver) books() *mongo.Collection {
+	return r.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+}
+
+func (r *Resolver) authors() *mongo.Collection {
+	return r.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+type booksArgs struct {
+	First *int32
+	After *string
+	Title *string
+}
+
+// Queries
+
+func (r *Resolver) Book(ctx context.Context, args struct{ ID graphql.ID }) (*bookResolver, error) {
+	id, err := objectID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	var book models.Book
+	err = r.books().FindOne(ctx, bson.M{"_id": id}).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bookResolver{root: r, book: book}, nil
+}
+
+func (r *Resolver) Author(ctx context.Context, args struct{ ID graphql.ID }) (*authorResolver, error) {
+	id, err := objectID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.loadAuthor(ctx, id)
+}
+
+func (r *Resolver) Books(ctx context.Context, args booksArgs) (*bookConnection, error) {
+	filter := bson.M{}
+	if args.Title != nil && *args.Title != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(*args.Title), Options: "i"}
+	}
+	return r.bookConnection(ctx, filter, pageArgs{First: args.First, After: args.After})
+}
+
+func (r *Resolver) Authors(ctx context.Context, args pageArgs) (*authorConnection, error) {
+	first, filter, err := page(bson.M{}, args)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.authors().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var authors []models.Author
+	if err := r.find(ctx, r.authors(), filter, first, &authors); err != nil {
+		return nil, err
+	}
+
+	connection := &authorConnection{totalCount: int32(total)}
+	if len(authors) > first {
+		authors = authors[:first]
+		connection.pageInfo.hasNextPage = true
+	}
+	for _, author := range authors {
+		// Prime the loader so books of this page resolve their author without
+		// another query.
+		author := author
+		loadersFrom(ctx).authors.Prime(ctx, author.ID, &author)
+		connection.edges = append(connection.edges, &authorEdge{
+			cursor: cursor(author.ID),
+			node:   &authorResolver{root: r, author: author},
+		})
+	}
+	if n := len(connection.edges); n > 0 {
+		connection.pageInfo.endCursor = &connection.edges[n-1].cursor
+	}
+	return connection, nil
+}
+
+// bookConnection returns a page of the books matching filter in _id order.
+func (r *Resolver) bookConnection(ctx context.Context, filter bson.M, args pageArgs) (*bookConnection, error) {
+	first, paged, err := page(filter, args)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.books().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []models.Book
+	if err := r.find(ctx, r.books(), paged, first, &books); err != nil {
+		return nil, err
+	}
+
+	connection := &bookConnection{totalCount: int32(total)}
+	if len(books) > first {
+		books = books[:first]
+		connection.pageInfo.hasNextPage = true
+	}
+	for _, book := range books {
+		connection.edges = append(connection.edges, &bookEdge{
+			cursor: cursor(book.ID),
+			node:   &bookResolver{root: r, book: book},
+		})
+	}
+	if n := len(connection.edges); n > 0 {
+		connection.pageInfo.endCursor = &connection.edges[n-1].cursor
+	}
+	return connection, nil
+}
+
+// find fetches one more document than the page holds, so that the caller
+// can tell whether there is a next page.
+func (r *Resolver) find(ctx context.Context, collection *mongo.Collection, filter bson.M, first int, results interface{}) error {
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(first + 1))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func bookFilter(authorID primitive.ObjectID) bson.M {
+	return bson.M{"authorId": authorID}
+}
+
+// page validates the page arguments and adds the cursor to filter.
+func page(filter bson.M, args pageArgs) (int, bson.M, error) {
+	first := defaultPageSize
+	if args.First != nil {
+		first = int(*args.First)
+		if first < 1 || first > maxPageSize {
+			return 0, nil, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+		}
+	}
+
+	paged := bson.M{}
+	for key, value := range filter {
+		paged[key] = value
+	}
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return 0, nil, err
+		}
+		paged["_id"] = bson.M{"$gt": after}
+	}
+	return first, paged, nil
+}
+
+// Cursors are opaque to clients; they wrap the ObjectID of the edge.
+
+func cursor(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func decodeCursor(cursor string) (primitive.ObjectID, error) {
+	var id primitive.ObjectID
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) != len(id) {
+		return id, errors.New("invalid cursor")
+	}
+	copy(id[:], raw)
+	return id, nil
+}
+
+func objectID(id graphql.ID) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return objID, fmt.Errorf("invalid ID %q", id)
+	}
+	return objID, nil
+}
+
+// Mutations
+
+type bookInput struct {
+	Title        string
+	ISBN         *string
+	AuthorID     *graphql.ID
+	Contributors *[]contributorInput
+	Publication  *publicationInput
+	Prices       *[]priceInput
+}
+
+type contributorInput struct {
+	Name     string
+	Role     *string
+	AuthorID *graphql.ID
+}
+
+type publicationInput struct {
+	Place     *string
+	Publisher *string
+	Date      *string
+}
+
+type priceInput struct {
+	Type     *string
+	Amount   string
+	Currency *string
+}
+
+type authorInput struct {
+	FirstName string
+	LastName  *string
+}
+
+// book converts the input to a book and validates it like the REST handlers.
+func (in bookInput) book() (models.Book, error) {
+	book := models.Book{Title: in.Title, ISBN: value(in.ISBN)}
+	var err error
+	if in.AuthorID != nil {
+		if book.AuthorID, err = objectID(*in.AuthorID); err != nil {
+			return book, err
+		}
+	}
+	if in.Contributors != nil {
+		for _, contributor := range *in.Contributors {
+			c := models.Contributor{Name: contributor.Name, Role: value(contributor.Role)}
+			if contributor.AuthorID != nil {
+				if c.AuthorID, err = objectID(*contributor.AuthorID); err != nil {
+					return book, err
+				}
+			}
+			book.Contributors = append(book.Contributors, c)
+		}
+	}
+	if in.Publication != nil {
+		book.Publication = &models.Publication{
+			Place:     value(in.Publication.Place),
+			Publisher: value(in.Publication.Publisher),
+			Date:      value(in.Publication.Date),
+		}
+	}
+	if in.Prices != nil {
+		for _, price := range *in.Prices {
+			book.Prices = append(book.Prices, models.Price{Type: value(price.Type), Amount: price.Amount, Currency: value(price.Currency)})
+		}
+	}
+	return book, binding.Validator.ValidateStruct(book)
+}
+
+func (in authorInput) author() (models.Author, error) {
+	author := models.Author{FirstName: in.FirstName, LastName: value(in.LastName)}
+	return author, binding.Validator.ValidateStruct(author)
+}
+
+func (r *Resolver) CreateBook(ctx context.Context, args struct{ Input bookInput }) (*bookResolver, error) {
+	book, err := args.Input.book()
+	if err != nil {
+		return nil, err
+	}
+	now := models.Timestamp()
+	book.ID, book.Version, book.CreatedAt, book.UpdatedAt = primitive.NewObjectID(), 1, now, now
+	err = r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		if _, err := r.books().InsertOne(tx, book); err != nil {
+			return err
+		}
+		return tx.Record(events.BookCreated, book.ID, book)
+	})
+	if err != nil {
+		requestlog.FromContext(ctx, r.logger).Error("Failed to create book", zap.Error(err))
+		return nil, errors.New("failed to create book")
+	}
+	return &bookResolver{root: r, book: book}, nil
+}
+
+func (r *Resolver) UpdateBook(ctx context.Context, args struct {
+	ID      graphql.ID
+	Input   bookInput
+	Version *int32
+}) (*bookResolver, error) {
+	id, err := objectID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	book, err := args.Input.book()
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.Book
+	if err := r.books().FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errBookNotFound
+		}
+		return nil, err
+	}
+	if args.Version != nil && int64(*args.Version) != existing.Version {
+		return nil, errModified
+	}
+
+	book.ID = existing.ID
+	book.Version = existing.Version + 1
+	book.CreatedAt = models.CreationTime(existing.ID, existing.CreatedAt)
+	book.UpdatedAt = models.Timestamp()
+	err = r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		result, err := r.books().ReplaceOne(tx, models.Versioned(existing.ID, existing.Version), book)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errModified
+		}
+		return tx.Record(events.BookUpdated, book.ID, book)
+	})
+	if errors.Is(err, errModified) {
+		return nil, err
+	}
+	if err != nil {
+		requestlog.FromContext(ctx, r.logger).Error("Failed to update book", zap.Error(err))
+		return nil, errors.New("failed to update book")
+	}
+	return &bookResolver{root: r, book: book}, nil
+}
+
+func (r *Resolver) DeleteBook(ctx context.Context, args struct {
+	ID      graphql.ID
+	Version *int32
+}) (graphql.ID, error) {
+	return args.ID, r.delete(ctx, r.books(), args.ID, args.Version, errBookNotFound, events.BookDeleted)
+}
+
+func (r *Resolver) CreateAuthor(ctx context.Context, args struct{ Input authorInput }) (*authorResolver, error) {
+	author, err := args.Input.author()
+	if err != nil {
+		return nil, err
+	}
+	now := models.Timestamp()
+	author.ID, author.Version, author.CreatedAt, author.UpdatedAt = primitive.NewObjectID(), 1, now, now
+	err = r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		if _, err := r.authors().InsertOne(tx, author); err != nil {
+			return err
+		}
+		return tx.Record(events.AuthorCreated, author.ID, author)
+	})
+	if err != nil {
+		requestlog.FromContext(ctx, r.logger).Error("Failed to create author", zap.Error(err))
+		return nil, errors.New("failed to create author")
+	}
+	return &authorResolver{root: r, author: author}, nil
+}
+
+func (r *Resolver) UpdateAuthor(ctx context.Context, args struct {
+	ID      graphql.ID
+	Input   authorInput
+	Version *int32
+}) (*authorResolver, error) {
+	id, err := objectID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	author, err := args.Input.author()
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.Author
+	if err := r.authors().FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errAuthorNotFound
+		}
+		return nil, err
+	}
+	if args.Version != nil && int64(*args.Version) != existing.Version {
+		return nil, errModified
+	}
+
+	author.ID = existing.ID
+	author.Version = existing.Version + 1
+	author.CreatedAt = models.CreationTime(existing.ID, existing.CreatedAt)
+	author.UpdatedAt = models.Timestamp()
+	err = r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		result, err := r.authors().ReplaceOne(tx, models.Versioned(existing.ID, existing.Version), author)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errModified
+		}
+		return tx.Record(events.AuthorUpdated, author.ID, author)
+	})
+	if errors.Is(err, errModified) {
+		return nil, err
+	}
+	if err != nil {
+		requestlog.FromContext(ctx, r.logger).Error("Failed to update author", zap.Error(err))
+		return nil, errors.New("failed to update author")
+	}
+	loadersFrom(ctx).authors.Clear(ctx, author.ID)
+	return &authorResolver{root: r, author: author}, nil
+}
+
+func (r *Resolver) DeleteAuthor(ctx context.Context, args struct {
+	ID      graphql.ID
+	Version *int32
+}) (graphql.ID, error) {
+	id := args.ID
+	err := r.delete(ctx, r.authors(), id, args.Version, errAuthorNotFound, events.AuthorDeleted)
+	if err == nil {
+		if objID, err := objectID(id); err == nil {
+			loadersFrom(ctx).authors.Clear(ctx, objID)
+		}
+	}
+	return id, err
+}
+
+// delete removes the document with the given ID, and when version is set,
+// only if it still has that version. deleted is the type of the event
+// recorded.
+func (r *Resolver) delete(ctx context.Context, collection *mongo.Collection, id graphql.ID, version *int32, notFound error, deleted events.Type) error {
+	objID, err := objectID(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID}
+	if version != nil {
+		filter = models.Versioned(objID, int64(*version))
+	}
+
+	var result *mongo.DeleteResult
+	err = r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		var err error
+		if result, err = collection.DeleteOne(tx, filter); err != nil || result.DeletedCount == 0 {
+			return err
+		}
+		return tx.Record(deleted, objID, nil)
+	})
+	if err != nil {
+		requestlog.FromContext(ctx, r.logger).Error("Failed to delete document", zap.Error(err))
+		return errors.New("failed to delete")
+	}
+	if result.DeletedCount > 0 {
+		return nil
+	}
+	if version == nil {
+		return notFound
+	}
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return errModified
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,150 @@
+package graphapi
+
+import (
+	"context"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+type bookResolver struct {
+	root *Resolver
+	book models.Book
+}
+
+func (b *bookResolver) ID() graphql.ID { return graphql.ID(b.book.ID.Hex()) }
+func (b *bookResolver) Title() string  { return b.book.Title }
+func (b *bookResolver) ISBN() *string  { return optional(b.book.ISBN) }
+func (b *bookResolver) Version() int32 { return int32(b.book.Version) }
+func (b *bookResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: models.CreationTime(b.book.ID, b.book.CreatedAt)}
+}
+func (b *bookResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: b.book.LastModified()}
+}
+
+func (b *bookResolver) Author(ctx context.Context) (*authorResolver, error) {
+	return b.root.loadAuthor(ctx, b.book.AuthorID)
+}
+
+func (b *bookResolver) Contributors() []*contributorResolver {
+	contributors := make([]*contributorResolver, len(b.book.Contributors))
+	for i, contributor := range b.book.Contributors {
+		contributors[i] = &contributorResolver{root: b.root, contributor: contributor}
+	}
+	return contributors
+}
+
+func (b *bookResolver) Publication() *publicationResolver {
+	if b.book.Publication == nil {
+		return nil
+	}
+	return &publicationResolver{*b.book.Publication}
+}
+
+func (b *bookResolver) Prices() []*priceResolver {
+	prices := make([]*priceResolver, len(b.book.Prices))
+	for i, price := range b.book.Prices {
+		prices[i] = &priceResolver{price}
+	}
+	return prices
+}
+
+type authorResolver struct {
+	root   *Resolver
+	author models.Author
+}
+
+func (a *authorResolver) ID() graphql.ID    { return graphql.ID(a.author.ID.Hex()) }
+func (a *authorResolver) FirstName() string { return a.author.FirstName }
+func (a *authorResolver) LastName() *string { return optional(a.author.LastName) }
+func (a *authorResolver) Version() int32    { return int32(a.author.Version) }
+func (a *authorResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: models.CreationTime(a.author.ID, a.author.CreatedAt)}
+}
+func (a *authorResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: a.author.LastModified()}
+}
+
+// Books pages through the author's books. Each author needs its own page, so
+// this is one query per author rather than a batched load.
+func (a *authorResolver) Books(ctx context.Context, args pageArgs) (*bookConnection, error) {
+	return a.root.bookConnection(ctx, bookFilter(a.author.ID), args)
+}
+
+type contributorResolver struct {
+	root        *Resolver
+	contributor models.Contributor
+}
+
+func (c *contributorResolver) Name() string  { return c.contributor.Name }
+func (c *contributorResolver) Role() *string { return optional(c.contributor.Role) }
+
+func (c *contributorResolver) Author(ctx context.Context) (*authorResolver, error) {
+	return c.root.loadAuthor(ctx, c.contributor.AuthorID)
+}
+
+type publicationResolver struct{ publication models.Publication }
+
+func (p *publicationResolver) Place() *string     { return optional(p.publication.Place) }
+func (p *publicationResolver) Publisher() *string { return optional(p.publication.Publisher) }
+func (p *publicationResolver) Date() *string      { return optional(p.publication.Date) }
+
+type priceResolver struct{ price models.Price }
+
+func (p *priceResolver) Type() *string     { return optional(p.price.Type) }
+func (p *priceResolver) Amount() string    { return p.price.Amount }
+func (p *priceResolver) Currency() *string { return optional(p.price.Currency) }
+
+type pageInfo struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (p pageInfo) HasNextPage() bool  { return p.hasNextPage }
+func (p pageInfo) EndCursor() *string { return p.endCursor }
+
+type bookEdge struct {
+	cursor string
+	node   *bookResolver
+}
+
+func (e *bookEdge) Cursor() string      { return e.cursor }
+func (e *bookEdge) Node() *bookResolver { return e.node }
+
+type bookConnection struct {
+	edges      []*bookEdge
+	pageInfo   pageInfo
+	totalCount int32
+}
+
+func (c *bookConnection) Edges() []*bookEdge { return c.edges }
+func (c *bookConnection) PageInfo() pageInfo { return c.pageInfo }
+func (c *bookConnection) TotalCount() int32  { return c.totalCount }
+
+type authorEdge struct {
+	cursor string
+	node   *authorResolver
+}
+
+func (e *authorEdge) Cursor() string        { return e.cursor }
+func (e *authorEdge) Node() *authorResolver { return e.node }
+
+type authorConnection struct {
+	edges      []*authorEdge
+	pageInfo   pageInfo
+	totalCount int32
+}
+
+func (c *authorConnection) Edges() []*authorEdge { return c.edges }
+func (c *authorConnection) PageInfo() pageInfo   { return c.pageInfo }
+func (c *authorConnection) TotalCount() int32    { return c.totalCount }
+
+// optional maps empty strings to null.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,101 @@
+package grpcapi
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	catalogv1 "github.com/saifujnu/books-authors/gen/booksauthors/catalog/v1"
+	"github.com/saifujnu/books-authors/models"
+)
+
+func toBook(book models.Book) *catalogv1.Book {
+	pb := &catalogv1.Book{
+		Id:         book.ID.Hex(),
+		Title:      book.Title,
+		Isbn:       book.ISBN,
+		Version:    book.Version,
+		CreateTime: timestamppb.New(models.CreationTime(book.ID, book.CreatedAt)),
+		UpdateTime: timestamppb.New(book.LastModified()),
+	}
+	if !book.AuthorID.IsZero() {
+		pb.AuthorId = book.AuthorID.Hex()
+	}
+	for _, contributor := range book.Contributors {
+		c := &catalogv1.Contributor{Name: contributor.Name, Role: contributor.Role}
+		if !contributor.AuthorID.IsZero() {
+			c.AuthorId = contributor.AuthorID.Hex()
+		}
+		pb.Contributors = append(pb.Contributors, c)
+	}
+	if pub := book.Publication; pub != nil {
+		pb.Publication = &catalogv1.Publication{Place: pub.Place, Publisher: pub.Publisher, Date: pub.Date}
+	}
+	for _, price := range book.Prices {
+		pb.Prices = append(pb.Prices, &catalogv1.Price{Type: price.Type, Amount: price.Amount, Currency: price.Currency})
+	}
+	return pb
+}
+
+// fromBook converts the client supplied fields of a book. The ID, version
+// and timestamps are set by the server.
+func fromBook(pb *catalogv1.Book) (models.Book, error) {
+	if pb == nil {
+		return models.Book{}, status.Error(codes.InvalidArgument, "book is required")
+	}
+	book := models.Book{Title: pb.Title, ISBN: pb.Isbn}
+	var err error
+	if book.AuthorID, err = optionalID(pb.AuthorId, "author_id"); err != nil {
+		return book, err
+	}
+	for _, contributor := range pb.Contributors {
+		c := models.Contributor{Name: contributor.Name, Role: contributor.Role}
+		if c.AuthorID, err = optionalID(contributor.AuthorId, "contributors.author_id"); err != nil {
+			return book, err
+		}
+		book.Contributors = append(book.Contributors, c)
+	}
+	if pub := pb.Publication; pub != nil {
+		book.Publication = &models.Publication{Place: pub.Place, Publisher: pub.Publisher, Date: pub.Date}
+	}
+	for _, price := range pb.Prices {
+		book.Prices = append(book.Prices, models.Price{Type: price.Type, Amount: price.Amount, Currency: price.Currency})
+	}
+	return book, nil
+}
+
+func toAuthor(author models.Author) *catalogv1.Author {
+	return &catalogv1.Author{
+		Id:         author.ID.Hex(),
+		FirstName:  author.FirstName,
+		LastName:   author.LastName,
+		Version:    author.Version,
+		CreateTime: timestamppb.New(models.CreationTime(author.ID, author.CreatedAt)),
+		UpdateTime: timestamppb.New(author.LastModified()),
+	}
+}
+
+func fromAuthor(pb *catalogv1.Author) (models.Author, error) {
+	if pb == nil {
+		return models.Author{}, status.Error(codes.InvalidArgument, "author is required")
+	}
+	return models.Author{FirstName: pb.FirstName, LastName: pb.LastName}, nil
+}
+
+// parseID parses a required hex ObjectID.
+func parseID(id, field string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return objID, status.Errorf(codes.InvalidArgument, "invalid %s %q", field, id)
+	}
+	return objID, nil
+}
+
+// optionalID parses a hex ObjectID that may be empty.
+func optionalID(id, field string) (primitive.ObjectID, error) {
+	if id == "" {
+		return primitive.NilObjectID, nil
+	}
+	return parseID(id, field)
+}
@@ -0,0 +1,449 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"regexp"
+
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+	catalogv1 "github.com/saifujnu/books-authors/gen/booksauthors/catalog/v1"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// exportBatchSize is the cursor batch size of ExportBooks.
+	exportBatchSize = 500
+)
+
+var errModified = status.Error(codes.FailedPrecondition, "document has been modified")
+
+// CatalogServer implements catalogv1.CatalogServiceServer on the catalog
+// collections. Its writes record their events in the outbox.
+type CatalogServer struct {
+	catalogv1.UnimplementedCatalogServiceServer
+
+	db     *mongo.Client
+	logger *zap.Logger
+	outbox *outbox.Outbox
+}
+
+func NewCatalogServer(db *mongo.Client, logger *zap.Logger, outbox *outbox.Outbox) *CatalogServer {
+	return &CatalogServer{db: db, logger: logger, outbox: outbox}
+}
+
+// NewServer returns a gRPC server with the catalog service registered and
+// every call authenticated by JWT.
+func NewServer(db *mongo.Client, logger *zap.Logger, outbox *outbox.Outbox) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAuthInterceptor),
+		grpc.ChainStreamInterceptor(StreamAuthInterceptor),
+	)
+	catalogv1.RegisterCatalogServiceServer(server, NewCatalogServer(db, logger, outbox))
+	return server
+}
+
+func (s *CatalogServer) books() *mongo.Collection {
+	return s.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Books)
+}
+
+func (s *CatalogServer) authors() *mongo.Collection {
+	return s.db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Authors)
+}
+
+// internal logs err and hides it from the client. Errors caused by the
+// end of the call, through its deadline or the client cancelling it, are
+// reported as such.
+func (s *CatalogServer) internal(msg string, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, msg)
+	case mongo.IsTimeout(err):
+		s.logger.Warn(msg, zap.Error(err))
+		return status.Error(codes.DeadlineExceeded, msg)
+	}
+	s.logger.Error(msg, zap.Error(err))
+	return status.Error(codes.Internal, msg)
+}
+
+func (s *CatalogServer) GetBook(ctx context.Context, req *catalogv1.GetBookRequest) (*catalogv1.Book, error) {
+	id, err := parseID(req.Id, "id")
+	if err != nil {
+		return nil, err
+	}
+	var book models.Book
+	if err := s.books().FindOne(ctx, bson.M{"_id": id}).Decode(&book); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, status.Error(codes.NotFound, "book not found")
+		}
+		return nil, s.internal("Failed to fetch book", err)
+	}
+	return toBook(book), nil
+}
+
+func (s *CatalogServer) ListBooks(ctx context.Context, req *catalogv1.ListBooksRequest) (*catalogv1.ListBooksResponse, error) {
+	filter := bson.M{}
+	if req.AuthorId != "" {
+		authorID, err := parseID(req.AuthorId, "author_id")
+		if err != nil {
+			return nil, err
+		}
+		filter["authorId"] = authorID
+	}
+
+	var books []models.Book
+	size, err := s.list(ctx, s.books(), filter, req.PageSize, req.PageToken, &books)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &catalogv1.ListBooksResponse{}
+	if len(books) > size {
+		books = books[:size]
+		resp.NextPageToken = pageToken(books[size-1].ID)
+	}
+	for _, book := range books {
+		resp.Books = append(resp.Books, toBook(book))
+	}
+	return resp, nil
+}
+
+func (s *CatalogServer) CreateBook(ctx context.Context, req *catalogv1.CreateBookRequest) (*catalogv1.Book, error) {
+	book, err := fromBook(req.Book)
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(book); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	now := models.Timestamp()
+	book.ID, book.Version, book.CreatedAt, book.UpdatedAt = primitive.NewObjectID(), 1, now, now
+	err = s.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		if _, err := s.books().InsertOne(tx, book); err != nil {
+			return err
+		}
+		return tx.Record(events.BookCreated, book.ID, book)
+	})
+	if err != nil {
+		return nil, s.internal("Failed to create book", err)
+	}
+	return toBook(book), nil
+}
+
+func (s *CatalogServer) UpdateBook(ctx context.Context, req *catalogv1.UpdateBookRequest) (*catalogv1.Book, error) {
+	book, err := fromBook(req.Book)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseID(req.Book.Id, "book.id")
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(book); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var existing models.Book
+	if err := s.books().FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, status.Error(codes.NotFound, "book not found")
+		}
+		return nil, s.internal("Failed to fetch book", err)
+	}
+	if req.Book.Version != 0 && req.Book.Version != existing.Version {
+		return nil, errModified
+	}
+
+	book.ID = existing.ID
+	book.Version = existing.Version + 1
+	book.CreatedAt = models.CreationTime(existing.ID, existing.CreatedAt)
+	book.UpdatedAt = models.Timestamp()
+	err = s.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		result, err := s.books().ReplaceOne(tx, models.Versioned(existing.ID, existing.Version), book)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errModified
+		}
+		return tx.Record(events.BookUpdated, book.ID, book)
+	})
+	if errors.Is(err, errModified) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, s.internal("Failed to update book", err)
+	}
+	return toBook(book), nil
+}
+
+func (s *CatalogServer) DeleteBook(ctx context.Context, req *catalogv1.DeleteBookRequest) (*emptypb.Empty, error) {
+	if err := s.delete(ctx, s.books(), req.Id, req.Version, "book not found", events.BookDeleted); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *CatalogServer) ExportBooks(req *catalogv1.ExportBooksRequest, stream catalogv1.CatalogService_ExportBooksServer) error {
+	ctx := stream.Context()
+
+	filter := bson.M{}
+	if req.AuthorId != "" {
+		authorID, err := parseID(req.AuthorId, "author_id")
+		if err != nil {
+			return err
+		}
+		filter["authorId"] = authorID
+	}
+	if req.Title != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(req.Title), Options: "i"}
+	}
+	if req.Isbn != "" {
+		filter["isbn"] = req.Isbn
+	}
+
+	pipeline := append([]bson.M{
+		{"$match": filter},
+		{"$sort": bson.M{"_id": 1}},
+	}, models.AuthorLookup(config.Current.Database.Collections.Authors)...)
+	cursor, err := s.books().Aggregate(ctx, pipeline, options.Aggregate().SetBatchSize(exportBatchSize))
+	if err != nil {
+		return s.internal("Failed to export books", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record models.BookWithAuthor
+		if err := cursor.Decode(&record); err != nil {
+			return s.internal("Failed to decode book", err)
+		}
+		resp := &catalogv1.ExportBooksResponse{Book: toBook(record.Book)}
+		if record.Author != nil {
+			resp.Author = toAuthor(*record.Author)
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return s.internal("Failed to export books", err)
+	}
+	return nil
+}
+
+func (s *CatalogServer) GetAuthor(ctx context.Context, req *catalogv1.GetAuthorRequest) (*catalogv1.Author, error) {
+	id, err := parseID(req.Id, "id")
+	if err != nil {
+		return nil, err
+	}
+	var author models.Author
+	if err := s.authors().FindOne(ctx, bson.M{"_id": id}).Decode(&author); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, status.Error(codes.NotFound, "author not found")
+		}
+		return nil, s.internal("Failed to fetch author", err)
+	}
+	return toAuthor(author), nil
+}
+
+func (s *CatalogServer) ListAuthors(ctx context.Context, req *catalogv1.ListAuthorsRequest) (*catalogv1.ListAuthorsResponse, error) {
+	var authors []models.Author
+	size, err := s.list(ctx, s.authors(), bson.M{}, req.PageSize, req.PageToken, &authors)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &catalogv1.ListAuthorsResponse{}
+	if len(authors) > size {
+		authors = authors[:size]
+		resp.NextPageToken = pageToken(authors[size-1].ID)
+	}
+	for _, author := range authors {
+		resp.Authors = append(resp.Authors, toAuthor(author))
+	}
+	return resp, nil
+}
+
+func (s *CatalogServer) CreateAuthor(ctx context.Context, req *catalogv1.CreateAuthorRequest) (*catalogv1.Author, error) {
+	author, err := fromAuthor(req.Author)
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(author); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	now := models.Timestamp()
+	author.ID, author.Version, author.CreatedAt, author.UpdatedAt = primitive.NewObjectID(), 1, now, now
+	err = s.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		if _, err := s.authors().InsertOne(tx, author); err != nil {
+			return err
+		}
+		return tx.Record(events.AuthorCreated, author.ID, author)
+	})
+	if err != nil {
+		return nil, s.internal("Failed to create author", err)
+	}
+	return toAuthor(author), nil
+}
+
+func (s *CatalogServer) UpdateAuthor(ctx context.Context, req *catalogv1.UpdateAuthorRequest) (*catalogv1.Author, error) {
+	author, err := fromAuthor(req.Author)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseID(req.Author.Id, "author.id")
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(author); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var existing models.Author
+	if err := s.authors().FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, status.Error(codes.NotFound, "author not found")
+		}
+		return nil, s.internal("Failed to fetch author", err)
+	}
+	if req.Author.Version != 0 && req.Author.Version != existing.Version {
+		return nil, errModified
+	}
+
+	author.ID = existing.ID
+	author.Version = existing.Version + 1
+	author.CreatedAt = models.CreationTime(existing.ID, existing.CreatedAt)
+	author.UpdatedAt = models.Timestamp()
+	err = s.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		result, err := s.authors().ReplaceOne(tx, models.Versioned(existing.ID, existing.Version), author)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errModified
+		}
+		return tx.Record(events.AuthorUpdated, author.ID, author)
+	})
+	if errors.Is(err, errModified) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, s.internal("Failed to update author", err)
+	}
+	return toAuthor(author), nil
+}
+
+func (s *CatalogServer) DeleteAuthor(ctx context.Context, req *catalogv1.DeleteAuthorRequest) (*emptypb.Empty, error) {
+	if err := s.delete(ctx, s.authors(), req.Id, req.Version, "author not found", events.AuthorDeleted); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// list reads the page of documents matching filter that follows pageToken,
+// in _id order, into results and returns the page size. One document more
+// than the page holds is read; if it is there, another page follows.
+func (s *CatalogServer) list(ctx context.Context, collection *mongo.Collection, filter bson.M, pageSize int32, token string, results interface{}) (int, error) {
+	size := defaultPageSize
+	switch {
+	case pageSize < 0 || pageSize > maxPageSize:
+		return 0, status.Errorf(codes.InvalidArgument, "page_size must be between 0 and %d", maxPageSize)
+	case pageSize > 0:
+		size = int(pageSize)
+	}
+
+	if token != "" {
+		after, err := decodePageToken(token)
+		if err != nil {
+			return 0, err
+		}
+		filter["_id"] = bson.M{"$gt": after}
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(size + 1))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, s.internal("Failed to list documents", err)
+	}
+	if err := cursor.All(ctx, results); err != nil {
+		return 0, s.internal("Failed to decode documents", err)
+	}
+	return size, nil
+}
+
+// delete removes the document with the given ID, and when version is
+// non-zero, only if it still has that version. deleted is the type of the
+// event recorded.
+func (s *CatalogServer) delete(ctx context.Context, collection *mongo.Collection, id string, version int64, notFound string, deleted events.Type) error {
+	objID, err := parseID(id, "id")
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID}
+	if version != 0 {
+		filter = models.Versioned(objID, version)
+	}
+
+	var result *mongo.DeleteResult
+	err = s.outbox.Write(ctx, func(tx *outbox.Tx) error {
+		var err error
+		if result, err = collection.DeleteOne(tx, filter); err != nil || result.DeletedCount == 0 {
+			return err
+		}
+		return tx.Record(deleted, objID, nil)
+	})
+	if err != nil {
+		return s.internal("Failed to delete document", err)
+	}
+	if result.DeletedCount > 0 {
+		return nil
+	}
+	if version != 0 {
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": objID})
+		if err != nil {
+			return s.internal("Failed to delete document", err)
+		}
+		if count > 0 {
+			return errModified
+		}
+	}
+	return status.Error(codes.NotFound, notFound)
+}
+
+// Page tokens are opaque to clients; they wrap the ObjectID of the last
+// document of the previous page.
+
+func pageToken(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func decodePageToken(token string) (primitive.ObjectID, error) {
+	var id primitive.ObjectID
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != len(id) {
+		return id, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	copy(id[:], raw)
+	return id, nil
+}
@@ -0,0 +1,279 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/saifujnu/books-authors/auth"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	catalogv1 "github.com/saifujnu/books-authors/gen/booksauthors/catalog/v1"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/mongotest"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+// testOutbox returns an outbox whose relay does not run, so that the
+// recorded events stay in the outbox collection.
+func testOutbox(client *mongo.Client, transactions bool) *outbox.Outbox {
+	return outbox.New(client, zap.NewNop(), map[string]events.Publisher{"test": events.Discard}, transactions)
+}
+
+// dial serves NewServer on an in-memory listener and returns a client of
+// it.
+func dial(t *testing.T, client *mongo.Client, ob *outbox.Outbox) catalogv1.CatalogServiceClient {
+	t.Helper()
+	server := NewServer(client, zap.NewNop(), ob)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return catalogv1.NewCatalogServiceClient(conn)
+}
+
+// authenticated returns a context carrying a valid token for alice.
+func authenticated(t *testing.T) context.Context {
+	t.Helper()
+	token, err := auth.GenerateToken("alice", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Fatalf("code = %v (%v), want %v", got, err, code)
+	}
+}
+
+func TestAuthInterceptors(t *testing.T) {
+	// The calls below are rejected or fail validation before they reach the
+	// database, which is never connected to.
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	catalog := dial(t, client, testOutbox(client, false))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no metadata", context.Background(), codes.Unauthenticated},
+		{"not a bearer token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic YWxpY2U6c2VjcmV0"), codes.Unauthenticated},
+		{"invalid token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-jwt"), codes.Unauthenticated},
+		{"valid token", authenticated(t), codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/unary", func(t *testing.T) {
+			_, err := catalog.GetBook(tt.ctx, &catalogv1.GetBookRequest{Id: "not-an-id"})
+			wantCode(t, err, tt.want)
+		})
+		t.Run(tt.name+"/stream", func(t *testing.T) {
+			stream, err := catalog.ExportBooks(tt.ctx, &catalogv1.ExportBooksRequest{AuthorId: "not-an-id"})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			wantCode(t, err, tt.want)
+		})
+	}
+}
+
+func TestBookCRUD(t *testing.T) {
+	client := mongotest.Connect(t)
+	catalog := dial(t, client, testOutbox(client, dbmongo.IsReplicated(context.Background(), client)))
+	ctx := authenticated(t)
+
+	author, err := catalog.CreateAuthor(ctx, &catalogv1.CreateAuthorRequest{Author: &catalogv1.Author{FirstName: "Jane", LastName: "Austen"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := catalog.CreateBook(ctx, &catalogv1.CreateBookRequest{Book: &catalogv1.Book{Title: "Emma", AuthorId: author.Id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Version != 1 || created.Id == "" {
+		t.Fatalf("created book = %v, want an ID and version 1", created)
+	}
+	_, err = catalog.CreateBook(ctx, &catalogv1.CreateBookRequest{Book: &catalogv1.Book{}})
+	wantCode(t, err, codes.InvalidArgument)
+
+	got, err := catalog.GetBook(ctx, &catalogv1.GetBookRequest{Id: created.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Emma" || got.AuthorId != author.Id {
+		t.Errorf("GetBook = %v", got)
+	}
+
+	updated, err := catalog.UpdateBook(ctx, &catalogv1.UpdateBookRequest{Book: &catalogv1.Book{Id: created.Id, Version: 1, Title: "Persuasion"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || updated.Title != "Persuasion" {
+		t.Errorf("UpdateBook = %v, want version 2", updated)
+	}
+	_, err = catalog.UpdateBook(ctx, &catalogv1.UpdateBookRequest{Book: &catalogv1.Book{Id: created.Id, Version: 1, Title: "Emma"}})
+	wantCode(t, err, codes.FailedPrecondition)
+
+	_, err = catalog.DeleteBook(ctx, &catalogv1.DeleteBookRequest{Id: created.Id, Version: 1})
+	wantCode(t, err, codes.FailedPrecondition)
+	if _, err := catalog.DeleteBook(ctx, &catalogv1.DeleteBookRequest{Id: created.Id, Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = catalog.GetBook(ctx, &catalogv1.GetBookRequest{Id: created.Id})
+	wantCode(t, err, codes.NotFound)
+	_, err = catalog.DeleteBook(ctx, &catalogv1.DeleteBookRequest{Id: created.Id})
+	wantCode(t, err, codes.NotFound)
+
+	var recorded []string
+	for _, event := range mongotest.RecordedEvents(t, client) {
+		recorded = append(recorded, fmt.Sprintf("%s %s", event.Type, event.Subject))
+	}
+	want := []string{
+		fmt.Sprintf("%s %s", events.AuthorCreated, author.Id),
+		fmt.Sprintf("%s %s", events.BookCreated, created.Id),
+		fmt.Sprintf("%s %s", events.BookUpdated, created.Id),
+		fmt.Sprintf("%s %s", events.BookDeleted, created.Id),
+	}
+	if fmt.Sprint(recorded) != fmt.Sprint(want) {
+		t.Errorf("recorded events %v, want %v", recorded, want)
+	}
+}
+
+func TestListAuthorsPages(t *testing.T) {
+	client := mongotest.Connect(t)
+	catalog := dial(t, client, testOutbox(client, dbmongo.IsReplicated(context.Background(), client)))
+	ctx := authenticated(t)
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		author, err := catalog.CreateAuthor(ctx, &catalogv1.CreateAuthorRequest{Author: &catalogv1.Author{FirstName: fmt.Sprint("Author ", i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, author.FirstName)
+	}
+
+	var (
+		got   []string
+		pages []int
+		token string
+	)
+	for {
+		resp, err := catalog.ListAuthors(ctx, &catalogv1.ListAuthorsRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, len(resp.Authors))
+		for _, author := range resp.Authors {
+			got = append(got, author.FirstName)
+		}
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+	}
+	if fmt.Sprint(pages) != "[2 2 1]" || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages %v of %v, want [2 2 1] of %v", pages, got, want)
+	}
+
+	tests := []struct {
+		name string
+		req  *catalogv1.ListAuthorsRequest
+	}{
+		{"page size too large", &catalogv1.ListAuthorsRequest{PageSize: maxPageSize + 1}},
+		{"negative page size", &catalogv1.ListAuthorsRequest{PageSize: -1}},
+		{"invalid page token", &catalogv1.ListAuthorsRequest{PageToken: "not-a-token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := catalog.ListAuthors(ctx, tt.req)
+			wantCode(t, err, codes.InvalidArgument)
+		})
+	}
+}
+
+func TestExportBooks(t *testing.T) {
+	client := mongotest.Connect(t)
+	catalog := dial(t, client, testOutbox(client, dbmongo.IsReplicated(context.Background(), client)))
+	ctx := authenticated(t)
+
+	author, err := catalog.CreateAuthor(ctx, &catalogv1.CreateAuthorRequest{Author: &catalogv1.Author{FirstName: "Jane", LastName: "Austen"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, book := range []*catalogv1.Book{
+		{Title: "Emma", AuthorId: author.Id},
+		{Title: "Persuasion", AuthorId: author.Id},
+		{Title: "Beowulf"},
+	} {
+		if _, err := catalog.CreateBook(ctx, &catalogv1.CreateBookRequest{Book: book}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		req  *catalogv1.ExportBooksRequest
+		want []string
+	}{
+		{"all books", &catalogv1.ExportBooksRequest{}, []string{"Emma by Jane", "Persuasion by Jane", "Beowulf"}},
+		{"by author", &catalogv1.ExportBooksRequest{AuthorId: author.Id}, []string{"Emma by Jane", "Persuasion by Jane"}},
+		{"by title", &catalogv1.ExportBooksRequest{Title: "persu"}, []string{"Persuasion by Jane"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := catalog.ExportBooks(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				line := resp.Book.Title
+				if resp.Author != nil {
+					line += " by " + resp.Author.FirstName
+				}
+				got = append(got, line)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("exported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
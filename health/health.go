@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status of a dependency or of the whole service.
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusReady    = "ready"
+	StatusNotReady = "not ready"
+	StatusDraining = "draining"
+)
+
+// CheckFunc returns an error when a dependency is unusable. It must return
+// once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Result is the outcome of one dependency check.
+type Result struct {
+	Status string `json:"status"`
+	// Critical dependencies make the service unready when they are down;
+	// others are only reported.
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of the service and of each of its dependencies.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker decides whether the service is ready to take traffic.
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+	ready    atomic.Bool // outcome of the last Check
+}
+
+// NewChecker returns a checker that gives each dependency timeout to answer.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency. Add must not be called once checks have
+// started.
+func (h *Checker) Add(name string, critical bool, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Drain makes the service unready for good, so that load balancers stop
+// sending requests while the in-flight ones finish.
+func (h *Checker) Drain() {
+	h.draining.Store(true)
+}
+
+// Ready reports the outcome of the last Check, and false once draining.
+func (h *Checker) Ready() bool {
+	return h.ready.Load() && !h.draining.Load()
+}
+
+// Check runs every dependency check at once and reports the readiness of
+// the service.
+func (h *Checker) Check(ctx context.Context) Report {
+	report := Report{Checks: make(map[string]Result, len(h.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	ready := true
+	for _, c := range h.checks {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.fn(ctx)
+			result := Result{
+				Status:    StatusUp,
+				Critical:  c.critical,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if err != nil && c.critical {
+				ready = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	h.ready.Store(ready)
+	switch {
+	case h.draining.Load():
+		report.Status = StatusDraining
+	case ready:
+		report.Status = StatusReady
+	default:
+		report.Status = StatusNotReady
+	}
+	return report
+}
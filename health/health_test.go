@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func up(context.Context) error { return nil }
+
+func down(context.Context) error { return errors.New("connection refused") }
+
+// hanging answers only when its context is done.
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCheck(t *testing.T) {
+	type dependency struct {
+		name     string
+		critical bool
+		fn       CheckFunc
+	}
+	tests := []struct {
+		name   string
+		deps   []dependency
+		status string
+		checks map[string]string // status by dependency
+	}{
+		{"no dependencies", nil, StatusReady, map[string]string{}},
+		{"all up", []dependency{{"mongodb", true, up}, {"nats", false, up}}, StatusReady, map[string]string{"mongodb": StatusUp, "nats": StatusUp}},
+		{"optional down", []dependency{{"mongodb", true, up}, {"nats", false, down}}, StatusReady, map[string]string{"mongodb": StatusUp, "nats": StatusDown}},
+		{"critical down", []dependency{{"mongodb", true, down}, {"nats", false, up}}, StatusNotReady, map[string]string{"mongodb": StatusDown, "nats": StatusUp}},
+		{"critical timed out", []dependency{{"mongodb", true, hanging}}, StatusNotReady, map[string]string{"mongodb": StatusDown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(20 * time.Millisecond)
+			for _, dep := range tt.deps {
+				checker.Add(dep.name, dep.critical, dep.fn)
+			}
+
+			start := time.Now()
+			report := checker.Check(context.Background())
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Check took %v despite the timeout", elapsed)
+			}
+			if report.Status != tt.status {
+				t.Errorf("status = %s, want %s", report.Status, tt.status)
+			}
+			if ready := tt.status == StatusReady; checker.Ready() != ready {
+				t.Errorf("Ready() = %v, want %v", checker.Ready(), ready)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("checks %+v, want %v", report.Checks, tt.checks)
+			}
+			for _, dep := range tt.deps {
+				result := report.Checks[dep.name]
+				if result.Status != tt.checks[dep.name] || result.Critical != dep.critical {
+					t.Errorf("%s: %+v, want %s", dep.name, result, tt.checks[dep.name])
+				}
+				if (result.Status == StatusDown) != (result.Error != "") {
+					t.Errorf("%s: error %q with status %s", dep.name, result.Error, result.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestChecksRunConcurrently(t *testing.T) {
+	checker := NewChecker(50 * time.Millisecond)
+	for _, name := range []string{"mongodb", "redis", "nats"} {
+		checker.Add(name, false, hanging)
+	}
+	start := time.Now()
+	checker.Check(context.Background())
+	if elapsed := time.Since(start); elapsed >= 150*time.Millisecond {
+		t.Errorf("three checks of 50ms took %v", elapsed)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("mongodb", true, up)
+	if checker.Ready() {
+		t.Error("ready before the first check")
+	}
+	if report := checker.Check(context.Background()); report.Status != StatusReady || !checker.Ready() {
+		t.Fatalf("status = %s before draining", report.Status)
+	}
+
+	checker.Drain()
+	if checker.Ready() {
+		t.Error("ready while draining")
+	}
+	if report := checker.Check(context.Background()); report.Status != StatusDraining || report.Checks["mongodb"].Status != StatusUp {
+		t.Errorf("report while draining = %+v", report)
+	}
+}
@@ -0,0 +1,402 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionSkip   Action = "skip"
+	ActionDelete Action = "delete"
+)
+
+// Outcome is what happened, or would happen in a dry run, to one row.
+type Outcome struct {
+	Line          int    `json:"line"`
+	Action        Action `json:"action"`
+	BookID        string `json:"bookId,omitempty"`
+	Title         string `json:"title"`
+	ISBN          string `json:"isbn,omitempty"`
+	AuthorID      string `json:"authorId"`
+	AuthorCreated bool   `json:"authorCreated,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// Report summarises an import run.
+type Report struct {
+	DryRun         bool       `json:"dryRun"`
+	Rows           int        `json:"rows"`
+	Created        int        `json:"created"`
+	Updated        int        `json:"updated"`
+	Skipped        int        `json:"skipped"`
+	Deleted        int        `json:"deleted,omitempty"`
+	Failed         int        `json:"failed"`
+	AuthorsCreated int        `json:"authorsCreated"`
+	Outcomes       []Outcome  `json:"outcomes,omitempty"`
+	Errors         []RowError `json:"errors,omitempty"`
+
+	// Truncated is set when only the first outcomes or errors were kept.
+	Truncated bool `json:"truncated,omitempty"`
+	// Unmapped counts source elements the import does not understand.
+	Unmapped map[string]int `json:"unmapped,omitempty"`
+}
+
+// Importer upserts books read from import files. Authors are resolved by
+// name and created when missing; books are deduplicated by ISBN, or by title
+// and author when the row has no ISBN. Every write records its event in the
+// outbox.
+type Importer struct {
+	books   *mongo.Collection
+	authors *mongo.Collection
+	outbox  *outbox.Outbox
+}
+
+func New(books, authors *mongo.Collection, outbox *outbox.Outbox) *Importer {
+	return &Importer{books: books, authors: authors, outbox: outbox}
+}
+
+// errModified fails a write whose version filter matched nothing.
+var errModified = errors.New("book was modified concurrently")
+
+// run holds the state of a single import so that rows later in the file see
+// the authors and books created by earlier rows, also in a dry run.
+type run struct {
+	*Importer
+	dryRun  bool
+	report  *Report
+	authors map[string]primitive.ObjectID // name key -> author ID
+	created map[primitive.ObjectID]bool   // authors created by this run
+	seen    map[string]int                // dedup key -> line of first occurrence
+}
+
+// maxEntries bounds the outcomes and errors kept in a report, which stays in
+// memory with its job; the counters still cover every row.
+const maxEntries = 1000
+
+func (im *Importer) newRun(dryRun bool) *run {
+	return &run{
+		Importer: im,
+		dryRun:   dryRun,
+		report:   &Report{DryRun: dryRun},
+		authors:  make(map[string]primitive.ObjectID),
+		created:  make(map[primitive.ObjectID]bool),
+		seen:     make(map[string]int),
+	}
+}
+
+// Run imports every row of rows. With dryRun set nothing is written and the
+// report lists what would have been done. Row level problems are collected in
+// the report; the returned error is only set when the import had to stop.
+func (im *Importer) Run(ctx context.Context, rows RowReader, dryRun bool) (*Report, error) {
+	r := im.newRun(dryRun)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return r.report, err
+		}
+
+		row, err := rows.Next()
+		if err == io.EOF {
+			return r.report, nil
+		}
+
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			r.report.Rows++
+			r.fail(*rowErr)
+			continue
+		}
+		if err != nil {
+			return r.report, err
+		}
+
+		r.report.Rows++
+		if err := r.importRow(ctx, row); err != nil {
+			return r.report, err
+		}
+	}
+}
+
+func (r *run) fail(rowErr RowError) {
+	r.report.Failed++
+	if len(r.report.Errors) >= maxEntries {
+		r.report.Truncated = true
+		return
+	}
+	r.report.Errors = append(r.report.Errors, rowErr)
+}
+
+func (r *run) failRow(row Row, message string) {
+	r.fail(RowError{Line: row.Line, Title: row.Title, ISBN: row.ISBN, Message: message})
+}
+
+// importRow imports a single row. Problems with the row are recorded in the
+// report; a returned error means the database could not be used.
+func (r *run) importRow(ctx context.Context, row Row) error {
+	row.Title = strings.TrimSpace(row.Title)
+	if row.Title == "" {
+		r.failRow(row, "title is required")
+		return nil
+	}
+	if strings.TrimSpace(row.AuthorFirstName) == "" {
+		r.failRow(row, "author is required")
+		return nil
+	}
+	if row.ISBN != "" {
+		isbn, err := NormalizeISBN(row.ISBN)
+		if err != nil {
+			r.failRow(row, err.Error())
+			return nil
+		}
+		row.ISBN = isbn
+	}
+
+	authorID, authorCreated, err := r.resolveAuthor(ctx, row.AuthorFirstName, row.AuthorLastName)
+	if err != nil {
+		return err
+	}
+
+	contributors, err := r.resolveContributors(ctx, row.Contributors)
+	if err != nil {
+		return err
+	}
+
+	outcome := Outcome{
+		Line:          row.Line,
+		Title:         row.Title,
+		ISBN:          row.ISBN,
+		AuthorID:      authorID.Hex(),
+		AuthorCreated: authorCreated,
+	}
+
+	key := "title:" + strings.ToLower(row.Title) + "|" + authorID.Hex()
+	if row.ISBN != "" {
+		key = "isbn:" + row.ISBN
+	}
+	if line, ok := r.seen[key]; ok {
+		outcome.Action = ActionSkip
+		outcome.Reason = fmt.Sprintf("duplicate of line %d", line)
+		r.record(outcome)
+		return nil
+	}
+	r.seen[key] = row.Line
+
+	existing, found, err := r.findBook(ctx, row, authorID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		now := models.Timestamp()
+		book := models.Book{
+			ID:           primitive.NewObjectID(),
+			Title:        row.Title,
+			ISBN:         row.ISBN,
+			AuthorID:     authorID,
+			Contributors: contributors,
+			Publication:  row.Publication,
+			Version:      1,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if !r.dryRun {
+			err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+				if _, err := r.books.InsertOne(tx, book); err != nil {
+					return err
+				}
+				return tx.Record(events.BookCreated, book.ID, book)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				r.failRow(row, "failed to create book: "+err.Error())
+				return nil
+			}
+		}
+		outcome.Action = ActionCreate
+		outcome.BookID = book.ID.Hex()
+		r.record(outcome)
+		return nil
+	}
+
+	outcome.BookID = existing.ID.Hex()
+	isbn := existing.ISBN
+	if row.ISBN != "" {
+		isbn = row.ISBN
+	}
+	// Fields the file does not carry are left as they are.
+	if contributors == nil {
+		contributors = existing.Contributors
+	}
+	publication := existing.Publication
+	if row.Publication != nil {
+		publication = row.Publication
+	}
+	if existing.Title == row.Title && existing.AuthorID == authorID && existing.ISBN == isbn &&
+		reflect.DeepEqual(existing.Contributors, contributors) && reflect.DeepEqual(existing.Publication, publication) {
+		outcome.Action = ActionSkip
+		outcome.Reason = "unchanged"
+		r.record(outcome)
+		return nil
+	}
+
+	if !r.dryRun {
+		filter := models.Versioned(existing.ID, existing.Version)
+		update := bson.M{"$set": bson.M{
+			"title":        row.Title,
+			"isbn":         isbn,
+			"authorId":     authorID,
+			"contributors": contributors,
+			"publication":  publication,
+			"version":      existing.Version + 1,
+			"updatedAt":    models.Timestamp(),
+		}}
+		err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+			var updated models.Book
+			err := r.books.FindOneAndUpdate(tx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return errModified
+			}
+			if err != nil {
+				return err
+			}
+			return tx.Record(events.BookUpdated, updated.ID, updated)
+		})
+		if errors.Is(err, errModified) {
+			r.failRow(row, err.Error())
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			r.failRow(row, "failed to update book: "+err.Error())
+			return nil
+		}
+	}
+	outcome.Action = ActionUpdate
+	r.record(outcome)
+	return nil
+}
+
+func (r *run) record(outcome Outcome) {
+	switch outcome.Action {
+	case ActionCreate:
+		r.report.Created++
+	case ActionUpdate:
+		r.report.Updated++
+	case ActionSkip:
+		r.report.Skipped++
+	case ActionDelete:
+		r.report.Deleted++
+	}
+	if len(r.report.Outcomes) >= maxEntries {
+		r.report.Truncated = true
+		return
+	}
+	r.report.Outcomes = append(r.report.Outcomes, outcome)
+}
+
+// resolveAuthor returns the ID of the author with the given name, creating
+// the author when none exists yet. created is true when this run created it.
+func (r *run) resolveAuthor(ctx context.Context, firstName, lastName string) (id primitive.ObjectID, created bool, err error) {
+	firstName, lastName = strings.TrimSpace(firstName), strings.TrimSpace(lastName)
+	key := strings.ToLower(firstName) + "|" + strings.ToLower(lastName)
+	if id, ok := r.authors[key]; ok {
+		return id, r.created[id], nil
+	}
+
+	var author models.Author
+	err = r.Importer.authors.FindOne(ctx, bson.M{"firstName": firstName, "lastName": lastName}).Decode(&author)
+	switch {
+	case err == nil:
+		r.authors[key] = author.ID
+		return author.ID, false, nil
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return primitive.NilObjectID, false, err
+	}
+
+	now := models.Timestamp()
+	author = models.Author{
+		ID:        primitive.NewObjectID(),
+		FirstName: firstName,
+		LastName:  lastName,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !r.dryRun {
+		err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+			if _, err := r.Importer.authors.InsertOne(tx, author); err != nil {
+				return err
+			}
+			return tx.Record(events.AuthorCreated, author.ID, author)
+		})
+		if err != nil {
+			return primitive.NilObjectID, false, err
+		}
+	}
+
+	r.authors[key] = author.ID
+	r.created[author.ID] = true
+	r.report.AuthorsCreated++
+	return author.ID, true, nil
+}
+
+// resolveContributors resolves the author of every contributor, creating
+// missing authors like resolveAuthor does.
+func (r *run) resolveContributors(ctx context.Context, names []ContributorName) ([]models.Contributor, error) {
+	var contributors []models.Contributor
+	for _, name := range names {
+		first, last := SplitName(name.Name)
+		if first == "" {
+			continue
+		}
+		id, _, err := r.resolveAuthor(ctx, first, last)
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, models.Contributor{AuthorID: id, Name: name.Name, Role: name.Role})
+	}
+	return contributors, nil
+}
+
+// findBook looks up the stored book a row refers to.
+func (r *run) findBook(ctx context.Context, row Row, authorID primitive.ObjectID) (models.Book, bool, error) {
+	var book models.Book
+
+	filter := bson.M{"title": row.Title, "authorId": authorID}
+	if row.ISBN != "" {
+		filter = bson.M{"isbn": row.ISBN}
+	} else if r.created[authorID] {
+		// A new author has no books yet.
+		return book, false, nil
+	}
+
+	err := r.books.FindOne(ctx, filter).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return book, false, nil
+	}
+	if err != nil {
+		return book, false, err
+	}
+	return book, true, nil
+}
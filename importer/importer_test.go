@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/mongotest"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+// testImporter returns an importer on the test database whose outbox keeps
+// the recorded events.
+func testImporter(t *testing.T) (*Importer, func() []events.Type) {
+	t.Helper()
+	client := mongotest.Connect(t)
+	ob := outbox.New(client, zap.NewNop(), map[string]events.Publisher{"test": events.Discard},
+		dbmongo.IsReplicated(context.Background(), client))
+	im := New(mongotest.Collection(client, config.Current.Database.Collections.Books),
+		mongotest.Collection(client, config.Current.Database.Collections.Authors), ob)
+	return im, func() []events.Type {
+		var types []events.Type
+		for _, event := range mongotest.RecordedEvents(t, client) {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+}
+
+const onixDelete = `<?xml version="1.0"?>
+<ONIXMessage release="3.0">
+  <Product>
+    <RecordReference>r1</RecordReference>
+    <NotificationType>05</NotificationType>
+    <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9780141439518</IDValue></ProductIdentifier>
+  </Product>
+</ONIXMessage>`
+
+func TestImportsRecordEvents(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	im, recorded := testImporter(t)
+	ctx := context.Background()
+
+	for _, csv := range []string{
+		"title,isbn,authorFirstName,authorLastName\nEmma,9780141439518,Jane,Austen\n",
+		// The same book under a new title is an update; the author exists.
+		"title,isbn,authorFirstName,authorLastName\nEmma: A Novel,9780141439518,Jane,Austen\n",
+	} {
+		rows, err := NewRowReader(FormatCSV, strings.NewReader(csv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report, err := im.Run(ctx, rows, false); err != nil || report.Failed > 0 {
+			t.Fatalf("import = %+v, %v", report, err)
+		}
+	}
+	if report, err := im.RunONIX(ctx, strings.NewReader(onixDelete), false); err != nil || report.Deleted != 1 {
+		t.Fatalf("ONIX import = %+v, %v", report, err)
+	}
+
+	want := []events.Type{events.AuthorCreated, events.BookCreated, events.BookUpdated, events.BookDeleted}
+	if got := recorded(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("recorded events %v, want %v", got, want)
+	}
+}
+
+func TestDryRunRecordsNothing(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	im, recorded := testImporter(t)
+
+	rows, err := NewRowReader(FormatCSV, strings.NewReader("title,authorFirstName\nEmma,Jane\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := im.Run(context.Background(), rows, true)
+	if err != nil || report.Created != 1 || report.AuthorsCreated != 1 {
+		t.Fatalf("dry run = %+v, %v", report, err)
+	}
+	if got := recorded(); len(got) != 0 {
+		t.Errorf("dry run recorded %v", got)
+	}
+}
@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/onix"
+	"github.com/saifujnu/books-authors/outbox"
+)
+
+// RunONIX applies an ONIX 3.0 message to the catalog, one product at a time.
+// Products are matched by ISBN. Full notifications replace the book, block
+// updates (type 04) only replace the blocks they carry, and deletes (type 05)
+// remove the book. Line numbers in the report are product numbers.
+func (im *Importer) RunONIX(ctx context.Context, source io.Reader, dryRun bool) (*Report, error) {
+	r := im.newRun(dryRun)
+	reader := onix.NewReader(source)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			r.report.Unmapped = reader.Unmapped()
+			return r.report, err
+		}
+
+		product, err := reader.Next()
+		if err != nil {
+			r.report.Unmapped = reader.Unmapped()
+			if err == io.EOF {
+				return r.report, nil
+			}
+			return r.report, err
+		}
+
+		r.report.Rows++
+		if err := r.applyProduct(ctx, r.report.Rows, onix.ToEntry(product)); err != nil {
+			r.report.Unmapped = reader.Unmapped()
+			return r.report, err
+		}
+	}
+}
+
+// applyProduct applies a single product. Problems with the product are
+// recorded in the report; a returned error means the database could not be
+// used.
+func (r *run) applyProduct(ctx context.Context, line int, entry onix.Entry) error {
+	row := Row{Line: line, Title: entry.Title, ISBN: entry.ISBN}
+	outcome := Outcome{Line: line, Title: entry.Title, ISBN: entry.ISBN}
+
+	if entry.Notification == onix.NotificationIgnore {
+		outcome.Action = ActionSkip
+		outcome.Reason = "test record or unknown notification type"
+		r.record(outcome)
+		return nil
+	}
+	if entry.ISBN == "" {
+		r.failRow(row, "product "+entry.RecordReference+" has no ISBN")
+		return nil
+	}
+	isbn, err := NormalizeISBN(entry.ISBN)
+	if err != nil {
+		r.failRow(row, err.Error())
+		return nil
+	}
+	row.ISBN, outcome.ISBN = isbn, isbn
+
+	var existing models.Book
+	err = r.books.FindOne(ctx, bson.M{"isbn": isbn}).Decode(&existing)
+	found := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	if entry.Notification == onix.NotificationDelete {
+		return r.deleteProduct(ctx, row, outcome, existing, found)
+	}
+
+	// A block update of an unknown product can only create it when it
+	// carries the descriptive detail.
+	replace := entry.Notification == onix.NotificationReplace || !found
+	if replace && (!entry.HasDescriptive || entry.Title == "") {
+		r.failRow(row, "product "+entry.RecordReference+" has no title")
+		return nil
+	}
+
+	book := existing
+	if replace {
+		book = models.Book{ISBN: isbn}
+	}
+	if replace || entry.HasDescriptive {
+		book.Title = entry.Title
+		book.AuthorID = primitive.NilObjectID
+		if entry.Author != nil {
+			id, created, err := r.resolveAuthor(ctx, entry.Author.FirstName, entry.Author.LastName)
+			if err != nil {
+				return err
+			}
+			book.AuthorID = id
+			outcome.AuthorCreated = created
+		}
+
+		names := make([]ContributorName, 0, len(entry.Contributors))
+		for _, contributor := range entry.Contributors {
+			names = append(names, ContributorName{Name: contributor.Name, Role: contributor.Role})
+		}
+		if book.Contributors, err = r.resolveContributors(ctx, names); err != nil {
+			return err
+		}
+	}
+	if replace || entry.HasPublishing {
+		book.Publication = entry.Publication
+	}
+	if replace || entry.HasSupply {
+		book.Prices = entry.Prices
+	}
+	outcome.Title = book.Title
+	if !book.AuthorID.IsZero() {
+		outcome.AuthorID = book.AuthorID.Hex()
+	}
+
+	if !found {
+		book.ID = primitive.NewObjectID()
+		book.Version = 1
+		now := models.Timestamp()
+		book.CreatedAt, book.UpdatedAt = now, now
+		if !r.dryRun {
+			err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+				if _, err := r.books.InsertOne(tx, book); err != nil {
+					return err
+				}
+				return tx.Record(events.BookCreated, book.ID, book)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				r.failRow(row, "failed to create book: "+err.Error())
+				return nil
+			}
+		}
+		outcome.Action = ActionCreate
+		outcome.BookID = book.ID.Hex()
+		r.record(outcome)
+		return nil
+	}
+
+	outcome.BookID = existing.ID.Hex()
+	book.ID, book.Version = existing.ID, existing.Version
+	book.CreatedAt, book.UpdatedAt = existing.CreatedAt, existing.UpdatedAt
+	if reflect.DeepEqual(book, existing) {
+		outcome.Action = ActionSkip
+		outcome.Reason = "unchanged"
+		r.record(outcome)
+		return nil
+	}
+
+	if !r.dryRun {
+		book.Version = existing.Version + 1
+		book.CreatedAt = models.CreationTime(existing.ID, existing.CreatedAt)
+		book.UpdatedAt = models.Timestamp()
+		err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+			result, err := r.books.ReplaceOne(tx, models.Versioned(existing.ID, existing.Version), book)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errModified
+			}
+			return tx.Record(events.BookUpdated, book.ID, book)
+		})
+		if errors.Is(err, errModified) {
+			r.failRow(row, err.Error())
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			r.failRow(row, "failed to update book: "+err.Error())
+			return nil
+		}
+	}
+	outcome.Action = ActionUpdate
+	r.record(outcome)
+	return nil
+}
+
+func (r *run) deleteProduct(ctx context.Context, row Row, outcome Outcome, existing models.Book, found bool) error {
+	if !found {
+		outcome.Action = ActionSkip
+		outcome.Reason = "not in catalog"
+		r.record(outcome)
+		return nil
+	}
+
+	outcome.BookID = existing.ID.Hex()
+	outcome.Title = existing.Title
+	if !r.dryRun {
+		err := r.outbox.Write(ctx, func(tx *outbox.Tx) error {
+			result, err := r.books.DeleteOne(tx, models.Versioned(existing.ID, existing.Version))
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount == 0 {
+				return errModified
+			}
+			return tx.Record(events.BookDeleted, existing.ID, nil)
+		})
+		if errors.Is(err, errModified) {
+			r.failRow(row, err.Error())
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			r.failRow(row, "failed to delete book: "+err.Error())
+			return nil
+		}
+	}
+	outcome.Action = ActionDelete
+	r.record(outcome)
+	return nil
+}
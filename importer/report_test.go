@@ -0,0 +1,24 @@
+package importer
+
+import "testing"
+
+func TestReportKeepsTheFirstEntries(t *testing.T) {
+	r := (&Importer{}).newRun(true)
+	rows := maxEntries + 500
+	for i := 1; i <= rows; i++ {
+		r.record(Outcome{Line: i, Action: ActionCreate})
+		r.fail(RowError{Line: i, Message: "invalid"})
+	}
+
+	report := r.report
+	if report.Created != rows || report.Failed != rows {
+		t.Errorf("counted %d created and %d failed, want %d each", report.Created, report.Failed, rows)
+	}
+	if len(report.Outcomes) != maxEntries || len(report.Errors) != maxEntries || !report.Truncated {
+		t.Errorf("kept %d outcomes and %d errors, truncated %v; want %d each, truncated",
+			len(report.Outcomes), len(report.Errors), report.Truncated, maxEntries)
+	}
+	if last := report.Outcomes[maxEntries-1].Line; last != maxEntries {
+		t.Errorf("last outcome kept is line %d, want %d", last, maxEntries)
+	}
+}
@@ -0,0 +1,288 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saifujnu/books-authors/marc"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// Row is one book of an import file.
+type Row struct {
+	Line            int    `json:"line"`
+	Title           string `json:"title"`
+	ISBN            string `json:"isbn,omitempty"`
+	AuthorFirstName string `json:"authorFirstName"`
+	AuthorLastName  string `json:"authorLastName,omitempty"`
+
+	// Contributors and Publication are only set by formats that carry them.
+	Contributors []ContributorName   `json:"contributors,omitempty"`
+	Publication  *models.Publication `json:"publication,omitempty"`
+}
+
+// ContributorName is a contributor as named in an import file.
+type ContributorName struct {
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+}
+
+// RowError describes a row that could not be imported.
+type RowError struct {
+	Line    int    `json:"line"`
+	Title   string `json:"title,omitempty"`
+	ISBN    string `json:"isbn,omitempty"`
+	Message string `json:"error"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// RowReader yields the rows of an import file. Next returns io.EOF after the
+// last row and a *RowError for a row that is malformed; reading may continue
+// after a *RowError, any other error is fatal.
+type RowReader interface {
+	Next() (Row, error)
+}
+
+// Format names accepted by NewRowReader.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatMARC    = "marc"
+	FormatMARCXML = "marcxml"
+)
+
+// IsFormat reports whether NewRowReader accepts the format name.
+func IsFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatNDJSON, FormatMARC, FormatMARCXML:
+		return true
+	}
+	return false
+}
+
+func NewRowReader(format string, r io.Reader) (RowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	case FormatMARC:
+		return &marcReader{read: marc.NewReader(r).Read}, nil
+	case FormatMARCXML:
+		return &marcReader{read: marc.NewXMLReader(r).Read}, nil
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+// csvReader reads files with a header row. The author is given either as
+// authorFirstName/authorLastName columns or as a single author column.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("CSV header has no title column")
+	}
+
+	return &csvReader{r: reader, columns: columns}, nil
+}
+
+func (cr *csvReader) Next() (Row, error) {
+	record, err := cr.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Row{}, &RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()}
+		}
+		return Row{}, err
+	}
+	line, _ := cr.r.FieldPos(0)
+
+	field := func(name string) string {
+		if i, ok := cr.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := Row{
+		Line:            line,
+		Title:           field("title"),
+		ISBN:            field("isbn"),
+		AuthorFirstName: field("authorfirstname"),
+		AuthorLastName:  field("authorlastname"),
+	}
+	if row.AuthorFirstName == "" {
+		row.AuthorFirstName, row.AuthorLastName = SplitName(field("author"))
+	}
+	return row, nil
+}
+
+// ndjsonReader reads one JSON object per line with the same keys as Row, or
+// an "author" key holding the full name.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (nr *ndjsonReader) Next() (Row, error) {
+	for nr.scanner.Scan() {
+		nr.line++
+		text := strings.TrimSpace(nr.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record struct {
+			Row
+			Author string `json:"author"`
+		}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return Row{}, &RowError{Line: nr.line, Message: err.Error()}
+		}
+
+		row := record.Row
+		row.Line = nr.line
+		if row.AuthorFirstName == "" {
+			row.AuthorFirstName, row.AuthorLastName = SplitName(record.Author)
+		}
+		return row, nil
+	}
+	if err := nr.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// marcReader reads MARC 21 or MARCXML records. Line numbers in the report
+// are record numbers.
+type marcReader struct {
+	read   func() (*marc.Record, error)
+	record int
+}
+
+func (mr *marcReader) Next() (Row, error) {
+	record, err := mr.read()
+	if err != nil {
+		return Row{}, err
+	}
+	mr.record++
+
+	entry := marc.ToEntry(record)
+	row := Row{
+		Line:        mr.record,
+		Title:       entry.Book.Title,
+		ISBN:        entry.Book.ISBN,
+		Publication: entry.Book.Publication,
+	}
+	for _, contributor := range entry.Book.Contributors {
+		row.Contributors = append(row.Contributors, ContributorName{Name: contributor.Name, Role: contributor.Role})
+	}
+
+	switch {
+	case entry.Author != nil:
+		row.AuthorFirstName, row.AuthorLastName = entry.Author.FirstName, entry.Author.LastName
+	case len(row.Contributors) > 0:
+		// Records entered under title have no 100; the first added entry
+		// becomes the author.
+		row.AuthorFirstName, row.AuthorLastName = SplitName(row.Contributors[0].Name)
+		row.Contributors = row.Contributors[1:]
+	}
+	return row, nil
+}
+
+// SplitName splits a full name into first and last name at the last space.
+// "Last, First" is understood as well.
+func SplitName(name string) (first, last string) {
+	name = strings.TrimSpace(name)
+	if i := strings.Index(name, ","); i >= 0 {
+		return strings.TrimSpace(name[i+1:]), strings.TrimSpace(name[:i])
+	}
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+	}
+	return name, ""
+}
+
+// NormalizeISBN strips separators from an ISBN-10 or ISBN-13 and checks its
+// length and check digit.
+func NormalizeISBN(isbn string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(isbn) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	normalized := b.String()
+
+	switch len(normalized) {
+	case 10:
+		sum := 0
+		for i, r := range normalized {
+			var digit int
+			switch {
+			case r >= '0' && r <= '9':
+				digit = int(r - '0')
+			case r == 'X' && i == 9:
+				digit = 10
+			default:
+				return "", fmt.Errorf("invalid ISBN %q", isbn)
+			}
+			sum += digit * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", fmt.Errorf("invalid ISBN check digit %q", isbn)
+		}
+	case 13:
+		sum := 0
+		for i, r := range normalized {
+			if r < '0' || r > '9' {
+				return "", fmt.Errorf("invalid ISBN %q", isbn)
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += int(r-'0') * weight
+		}
+		if sum%10 != 0 {
+			return "", fmt.Errorf("invalid ISBN check digit %q", isbn)
+		}
+	default:
+		return "", fmt.Errorf("invalid ISBN length %q", isbn)
+	}
+	return normalized, nil
+}
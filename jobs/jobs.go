@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Job is a snapshot of a background job. Result holds whatever the job
+// function returned, also when it failed half way.
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Status     Status      `json:"status"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// Func is the body of a job. It must return when ctx is cancelled.
+type Func func(ctx context.Context) (interface{}, error)
+
+// Manager runs jobs in the background and keeps their state in memory
+// until ttl after they finished.
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+	ttl  time.Duration
+	now  func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewManager(ttl time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		jobs:   make(map[string]*Job),
+		ttl:    ttl,
+		now:    func() time.Time { return time.Now().UTC() },
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start registers a job of the given kind and runs fn in its own goroutine.
+func (m *Manager) Start(kind string, fn Func) Job {
+	job := &Job{
+		ID:        primitive.NewObjectID().Hex(),
+		Kind:      kind,
+		Status:    StatusQueued,
+		CreatedAt: m.now(),
+	}
+
+	m.mu.Lock()
+	m.evict()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	// The job is only read under m.mu once it runs.
+	m.wg.Add(1)
+	go m.run(job, fn)
+
+	return snapshot
+}
+
+func (m *Manager) run(job *Job, fn Func) {
+	defer m.wg.Done()
+
+	started := m.now()
+	m.update(job, func(j *Job) {
+		j.Status = StatusRunning
+		j.StartedAt = &started
+	})
+
+	result, err := call(m.ctx, fn)
+
+	finished := m.now()
+	m.update(job, func(j *Job) {
+		j.FinishedAt = &finished
+		j.Result = result
+		switch {
+		case err == nil:
+			j.Status = StatusSucceeded
+		case errors.Is(err, context.Canceled):
+			j.Status = StatusCancelled
+			j.Error = err.Error()
+		default:
+			j.Status = StatusFailed
+			j.Error = err.Error()
+		}
+	})
+}
+
+// call runs fn and turns a panic into an error, so that a job cannot take
+// the server down and is reported as failed instead.
+func call(ctx context.Context, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (m *Manager) update(job *Job, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(job)
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || m.expired(job) {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// evict forgets the jobs that expired, so that results do not pile up in
+// memory. m.mu must be held.
+func (m *Manager) evict() {
+	for id, job := range m.jobs {
+		if m.expired(job) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// expired reports whether job finished more than ttl ago.
+func (m *Manager) expired(job *Job) bool {
+	return job.FinishedAt != nil && m.now().Sub(*job.FinishedAt) > m.ttl
+}
+
+// Shutdown cancels all running jobs and waits for them to return, or for ctx
+// to expire.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wait returns the job once it has finished.
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if job, ok := m.Get(id); ok && job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result interface{}
+		err    error
+		want   Status
+	}{
+		{"success", "report", nil, StatusSucceeded},
+		{"failure", "partial report", errors.New("boom"), StatusFailed},
+		{"cancellation", nil, context.Canceled, StatusCancelled},
+	}
+	m := NewManager(time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := wait(t, m, m.Start("test", func(context.Context) (interface{}, error) { return tt.result, tt.err }).ID)
+			if job.Status != tt.want || job.Result != tt.result {
+				t.Errorf("job = %s with %v, want %s with %v", job.Status, job.Result, tt.want, tt.result)
+			}
+		})
+	}
+}
+
+func TestPanicFailsJob(t *testing.T) {
+	m := NewManager(time.Hour)
+	job := wait(t, m, m.Start("test", func(context.Context) (interface{}, error) { panic("slice bounds out of range") }).ID)
+	if job.Status != StatusFailed || !strings.Contains(job.Error, "slice bounds out of range") {
+		t.Errorf("job = %s with error %q, want failed with the panic", job.Status, job.Error)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+func TestFinishedJobsExpire(t *testing.T) {
+	m := NewManager(time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	release := make(chan struct{})
+	running := m.Start("test", func(ctx context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	finished := wait(t, m, m.Start("test", func(context.Context) (interface{}, error) { return nil, nil }).ID)
+	for job, _ := m.Get(running.ID); job.Status != StatusRunning; job, _ = m.Get(running.ID) {
+		time.Sleep(time.Millisecond)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok := m.Get(finished.ID); ok {
+		t.Error("a job finished two hours ago is still kept")
+	}
+	if _, ok := m.Get(running.ID); !ok {
+		t.Error("a running job expired")
+	}
+
+	// Starting a job drops the expired ones from memory.
+	m.Start("test", func(context.Context) (interface{}, error) { return nil, nil })
+	m.mu.Lock()
+	_, kept := m.jobs[finished.ID]
+	m.mu.Unlock()
+	if kept {
+		t.Error("the expired job is still in memory")
+	}
+	close(release)
+	m.Shutdown(context.Background())
+}
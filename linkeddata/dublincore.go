@@ -0,0 +1,117 @@
+package linkeddata
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+const (
+	RDFXMLContentType   = "application/rdf+xml"
+	RDFNamespace        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	DCElementsNamespace = "http://purl.org/dc/elements/1.1/"
+	DCTermsNamespace    = "http://purl.org/dc/terms/"
+	FOAFNamespace       = "http://xmlns.com/foaf/0.1/"
+)
+
+// RDF is an RDF/XML document holding a single description.
+type RDF struct {
+	XMLName      xml.Name    `xml:"rdf:RDF"`
+	XmlnsRDF     string      `xml:"xmlns:rdf,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsDCTerms string      `xml:"xmlns:dcterms,attr"`
+	XmlnsFOAF    string      `xml:"xmlns:foaf,attr"`
+	Description  Description `xml:"rdf:Description"`
+}
+
+// Description describes one resource. Books use the Dublin Core elements
+// with literal values, which harvesters such as OAI-PMH aggregators expect;
+// authors are described with FOAF as Dublin Core has no person vocabulary.
+type Description struct {
+	About       string    `xml:"rdf:about,attr"`
+	Type        *Resource `xml:"rdf:type,omitempty"`
+	Title       string    `xml:"dc:title,omitempty"`
+	Creator     []string  `xml:"dc:creator,omitempty"`
+	CreatorRef  *Resource `xml:"dcterms:creator,omitempty"`
+	Contributor []string  `xml:"dc:contributor,omitempty"`
+	Publisher   string    `xml:"dc:publisher,omitempty"`
+	Date        string    `xml:"dc:date,omitempty"`
+	DCType      string    `xml:"dc:type,omitempty"`
+	Identifier  []string  `xml:"dc:identifier,omitempty"`
+	Name        string    `xml:"foaf:name,omitempty"`
+	GivenName   string    `xml:"foaf:givenName,omitempty"`
+	FamilyName  string    `xml:"foaf:familyName,omitempty"`
+	Created     string    `xml:"dcterms:created,omitempty"`
+	Modified    string    `xml:"dcterms:modified,omitempty"`
+}
+
+// Resource is a property whose value is another resource.
+type Resource struct {
+	Ref string `xml:"rdf:resource,attr"`
+}
+
+func newRDF(description Description) *RDF {
+	return &RDF{
+		XmlnsRDF:     RDFNamespace,
+		XmlnsDC:      DCElementsNamespace,
+		XmlnsDCTerms: DCTermsNamespace,
+		XmlnsFOAF:    FOAFNamespace,
+		Description:  description,
+	}
+}
+
+// BookRDF builds the Dublin Core description of a book. author may be nil;
+// authorHref is its URL.
+func BookRDF(book models.Book, href string, author *models.Author, authorHref string) *RDF {
+	description := Description{
+		About:    href,
+		Title:    book.Title,
+		DCType:   "Text",
+		Created:  formatDate(models.CreationTime(book.ID, book.CreatedAt)),
+		Modified: formatDate(book.LastModified()),
+	}
+	if author != nil {
+		description.Creator = append(description.Creator, displayName(*author))
+		description.CreatorRef = &Resource{Ref: authorHref}
+	}
+	for _, contributor := range book.Contributors {
+		description.Contributor = append(description.Contributor, contributor.Name)
+	}
+	if book.ISBN != "" {
+		description.Identifier = append(description.Identifier, "urn:isbn:"+book.ISBN)
+	}
+	if pub := book.Publication; pub != nil {
+		description.Publisher = pub.Publisher
+		description.Date = pub.Date
+	}
+	return newRDF(description)
+}
+
+// AuthorRDF builds the FOAF description of an author.
+func AuthorRDF(author models.Author, href string) *RDF {
+	return newRDF(Description{
+		About:      href,
+		Type:       &Resource{Ref: FOAFNamespace + "Person"},
+		Name:       displayName(author),
+		GivenName:  author.FirstName,
+		FamilyName: author.LastName,
+		Created:    formatDate(models.CreationTime(author.ID, author.CreatedAt)),
+		Modified:   formatDate(author.LastModified()),
+	})
+}
+
+// Marshal renders the document with an XML declaration.
+func (r *RDF) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// formatDate formats t as an RFC 3339 timestamp in UTC, which is valid both
+// as a schema.org DateTime and a W3CDTF date.
+func formatDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
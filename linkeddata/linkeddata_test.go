@@ -0,0 +1,150 @@
+package linkeddata
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+func objectID(hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+var (
+	created = time.Date(2023, 7, 19, 10, 0, 0, 0, time.UTC)
+	updated = time.Date(2024, 2, 1, 8, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	herbert = models.Author{ID: objectID("64b7f0c2a1b2c3d4e5f60001"), FirstName: "Frank", LastName: "Herbert", CreatedAt: created, UpdatedAt: updated}
+	dune    = models.Book{
+		ID:    objectID("64b7f0c2a1b2c3d4e5f60718"),
+		Title: "Dune",
+		ISBN:  "9780441013593",
+		Contributors: []models.Contributor{
+			{Name: "Schoenherr, John", Role: "Illustrator"},
+			{Name: "Herbert, Brian", Role: "editor"},
+			{Name: "Zimmerman, Michel", Role: "translator"},
+			{Name: "Anderson, Kevin J."},
+		},
+		Publication: &models.Publication{Place: "New York", Publisher: "Ace", Date: "1990"},
+		Prices:      []models.Price{{Amount: "9.99", Currency: "USD"}},
+		CreatedAt:   created,
+		UpdatedAt:   updated,
+	}
+)
+
+const (
+	bookHref   = "https://example.org/books/64b7f0c2a1b2c3d4e5f60718"
+	authorHref = "https://example.org/authors/64b7f0c2a1b2c3d4e5f60001"
+)
+
+func TestJSONLD(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  interface{}
+		want string
+	}{
+		{
+			name: "book",
+			doc:  BookDocument(dune, bookHref, &herbert, authorHref),
+			want: `{"@context":"https://schema.org","@type":"Book","@id":"` + bookHref + `","name":"Dune","url":"` + bookHref + `",` +
+				`"isbn":"9780441013593",` +
+				`"author":{"@type":"Person","@id":"` + authorHref + `","name":"Frank Herbert","url":"` + authorHref + `","givenName":"Frank","familyName":"Herbert"},` +
+				`"editor":[{"@type":"Person","name":"Herbert, Brian"}],` +
+				`"illustrator":[{"@type":"Person","name":"Schoenherr, John"}],` +
+				`"translator":[{"@type":"Person","name":"Zimmerman, Michel"}],` +
+				`"contributor":[{"@type":"Person","name":"Anderson, Kevin J."}],` +
+				`"publisher":{"@type":"Organization","name":"Ace"},` +
+				`"locationCreated":{"@type":"Place","name":"New York"},` +
+				`"datePublished":"1990",` +
+				`"offers":[{"@type":"Offer","price":"9.99","priceCurrency":"USD"}],` +
+				`"dateModified":"2024-02-01T07:30:00Z"}`,
+		},
+		{
+			name: "book without author",
+			doc:  BookDocument(models.Book{ID: dune.ID, Title: "Dune", UpdatedAt: updated}, bookHref, nil, ""),
+			want: `{"@context":"https://schema.org","@type":"Book","@id":"` + bookHref + `","name":"Dune","url":"` + bookHref + `","dateModified":"2024-02-01T07:30:00Z"}`,
+		},
+		{
+			name: "author",
+			doc:  AuthorDocument(models.Author{ID: herbert.ID, FirstName: "Homer", CreatedAt: created, UpdatedAt: updated}, authorHref),
+			want: `{"@context":"https://schema.org","@type":"Person","@id":"` + authorHref + `","name":"Homer","url":"` + authorHref + `",` +
+				`"givenName":"Homer","dateCreated":"2023-07-19T10:00:00Z","dateModified":"2024-02-01T07:30:00Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRDF(t *testing.T) {
+	tests := []struct {
+		name string
+		rdf  *RDF
+		want []string
+	}{
+		{
+			name: "book",
+			rdf:  BookRDF(dune, bookHref, &herbert, authorHref),
+			want: []string{
+				`<rdf:RDF xmlns:rdf="` + RDFNamespace + `" xmlns:dc="` + DCElementsNamespace + `" xmlns:dcterms="` + DCTermsNamespace + `" xmlns:foaf="` + FOAFNamespace + `">`,
+				`<rdf:Description rdf:about="` + bookHref + `">`,
+				`<dc:title>Dune</dc:title>`,
+				`<dc:creator>Frank Herbert</dc:creator>`,
+				`<dcterms:creator rdf:resource="` + authorHref + `"></dcterms:creator>`,
+				`<dc:contributor>Schoenherr, John</dc:contributor>`,
+				`<dc:contributor>Anderson, Kevin J.</dc:contributor>`,
+				`<dc:publisher>Ace</dc:publisher>`,
+				`<dc:date>1990</dc:date>`,
+				`<dc:type>Text</dc:type>`,
+				`<dc:identifier>urn:isbn:9780441013593</dc:identifier>`,
+				`<dcterms:created>2023-07-19T10:00:00Z</dcterms:created>`,
+				`<dcterms:modified>2024-02-01T07:30:00Z</dcterms:modified>`,
+			},
+		},
+		{
+			name: "author",
+			rdf:  AuthorRDF(herbert, authorHref),
+			want: []string{
+				`<rdf:Description rdf:about="` + authorHref + `">`,
+				`<rdf:type rdf:resource="` + FOAFNamespace + `Person"></rdf:type>`,
+				`<foaf:name>Frank Herbert</foaf:name>`,
+				`<foaf:givenName>Frank</foaf:givenName>`,
+				`<foaf:familyName>Herbert</foaf:familyName>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.rdf.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(body), xml.Header) {
+				t.Error("no XML declaration")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("document lacks %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
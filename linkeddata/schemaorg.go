@@ -0,0 +1,140 @@
+package linkeddata
+
+import (
+	"strings"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+const (
+	JSONLDContentType = "application/ld+json"
+	SchemaOrgContext  = "https://schema.org"
+)
+
+// Thing is the part of a schema.org Thing that books and persons share.
+type Thing struct {
+	Context string `json:"@context,omitempty"`
+	Type    string `json:"@type"`
+	ID      string `json:"@id,omitempty"`
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Person is a schema.org Person.
+type Person struct {
+	Thing
+	GivenName    string `json:"givenName,omitempty"`
+	FamilyName   string `json:"familyName,omitempty"`
+	DateCreated  string `json:"dateCreated,omitempty"`
+	DateModified string `json:"dateModified,omitempty"`
+}
+
+// Book is a schema.org Book. Contributors with a role schema.org has a
+// property for are listed under it; everybody else is a contributor.
+type Book struct {
+	Thing
+	ISBN          string        `json:"isbn,omitempty"`
+	Author        *Person       `json:"author,omitempty"`
+	Editor        []Person      `json:"editor,omitempty"`
+	Illustrator   []Person      `json:"illustrator,omitempty"`
+	Translator    []Person      `json:"translator,omitempty"`
+	Contributor   []Person      `json:"contributor,omitempty"`
+	Publisher     *Organization `json:"publisher,omitempty"`
+	Location      *Place        `json:"locationCreated,omitempty"`
+	DatePublished string        `json:"datePublished,omitempty"`
+	Offers        []Offer       `json:"offers,omitempty"`
+	DateModified  string        `json:"dateModified,omitempty"`
+}
+
+type Organization struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type Place struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type Offer struct {
+	Type          string `json:"@type"`
+	Price         string `json:"price"`
+	PriceCurrency string `json:"priceCurrency,omitempty"`
+}
+
+// AuthorPerson builds the schema.org Person of an author. href is the
+// author's URL and is used as its @id.
+func AuthorPerson(author models.Author, href string) Person {
+	return Person{
+		Thing: Thing{
+			Type: "Person",
+			ID:   href,
+			Name: displayName(author),
+			URL:  href,
+		},
+		GivenName:  author.FirstName,
+		FamilyName: author.LastName,
+	}
+}
+
+// AuthorDocument builds the top level JSON-LD document of an author.
+func AuthorDocument(author models.Author, href string) Person {
+	person := AuthorPerson(author, href)
+	person.Context = SchemaOrgContext
+	person.DateCreated = formatDate(models.CreationTime(author.ID, author.CreatedAt))
+	person.DateModified = formatDate(author.LastModified())
+	return person
+}
+
+// BookDocument builds the top level JSON-LD document of a book. author may be
+// nil when the book has none or it was deleted; authorHref is its URL.
+func BookDocument(book models.Book, href string, author *models.Author, authorHref string) Book {
+	doc := Book{
+		Thing: Thing{
+			Context: SchemaOrgContext,
+			Type:    "Book",
+			ID:      href,
+			Name:    book.Title,
+			URL:     href,
+		},
+		ISBN:         book.ISBN,
+		DateModified: formatDate(book.LastModified()),
+	}
+	if author != nil {
+		person := AuthorPerson(*author, authorHref)
+		doc.Author = &person
+	}
+
+	for _, contributor := range book.Contributors {
+		person := Person{Thing: Thing{Type: "Person", Name: contributor.Name}}
+		switch strings.ToLower(contributor.Role) {
+		case "editor":
+			doc.Editor = append(doc.Editor, person)
+		case "illustrator":
+			doc.Illustrator = append(doc.Illustrator, person)
+		case "translator":
+			doc.Translator = append(doc.Translator, person)
+		default:
+			doc.Contributor = append(doc.Contributor, person)
+		}
+	}
+
+	if pub := book.Publication; pub != nil {
+		if pub.Publisher != "" {
+			doc.Publisher = &Organization{Type: "Organization", Name: pub.Publisher}
+		}
+		if pub.Place != "" {
+			doc.Location = &Place{Type: "Place", Name: pub.Place}
+		}
+		doc.DatePublished = pub.Date
+	}
+	for _, price := range book.Prices {
+		doc.Offers = append(doc.Offers, Offer{Type: "Offer", Price: price.Amount, PriceCurrency: price.Currency})
+	}
+	return doc
+}
+
+// displayName returns "First Last".
+func displayName(author models.Author) string {
+	return strings.TrimSpace(author.FirstName + " " + author.LastName)
+}
@@ -3,34 +3,72 @@ package logger
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var Logger *zap.Logger
+// Config configures the logger built by New.
+type Config struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string
+	// Format is json or console.
+	Format string
+	// Sampling drops repeats of the same message beyond the first 100 per
+	// second, so that a burst of failures cannot flood the output.
+	Sampling bool
 
-func init() {
-	// Configure Zap logger
-	fmt.Println("I am from Logger init")
-	loggerCfg := zap.Config{
-		Encoding:         "json",
-		Level:            zap.NewAtomicLevelAt(zapcore.InfoLevel), // Set log level (Info)
-		OutputPaths:      []string{"stdout"},                      // Log to stdout
-		ErrorOutputPaths: []string{"stderr"},                      // Log errors to stderr
+	// File, when set, receives the log as well as stdout. It is rotated
+	// after FileMaxSizeMB megabytes; FileMaxBackups rotated files are kept
+	// for at most FileMaxAgeDays days, 0 meaning no limit.
+	File           string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	FileMaxAgeDays int
+}
+
+// New builds the application logger. The returned level controls it while
+// the program runs; it serves GET and PUT requests as an http.Handler.
+func New(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zap.ParseAtomicLevel(cfg.Level)
+	if err != nil {
+		return nil, level, err
 	}
 
-	// Override the log level based on the environment (release or debug)
-	if os.Getenv("GIN_MODE") == "debug" {
-		loggerCfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch cfg.Format {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, level, fmt.Errorf("logger: unknown format %q", cfg.Format)
 	}
 
-	var err error
-	Logger, err = loggerCfg.Build()
-	if err != nil {
-		panic("Failed to initialize Zap logger: " + err.Error())
+	output := zapcore.Lock(os.Stdout)
+	if cfg.File != "" {
+		output = zapcore.NewMultiWriteSyncer(output, zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.FileMaxSizeMB,
+			MaxBackups: cfg.FileMaxBackups,
+			MaxAge:     cfg.FileMaxAgeDays,
+		}))
+	}
+
+	core := zapcore.NewCore(encoder, output, level)
+	if cfg.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, 100, 100)
 	}
 
-	// Replace the standard logger with Zap logger
-	zap.ReplaceGlobals(Logger)
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return logger, level, nil
 }
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout points os.Stdout, which New writes to, at a file for the
+// rest of the test and returns its path.
+func captureStdout(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdout.log")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = file
+	t.Cleanup(func() {
+		os.Stdout = saved
+		file.Close()
+	})
+	return path
+}
+
+// readJSONLines returns the messages of the JSON log at path.
+func readJSONLines(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var messages []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, entry.Msg)
+	}
+	return messages
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"unknown level", Config{Level: "verbose", Format: "json"}, `unrecognized level: "verbose"`},
+		{"unknown format", Config{Level: "info", Format: "logfmt"}, `logger: unknown format "logfmt"`},
+	}
+	for _, tt := range tests {
+		if _, _, err := New(tt.cfg); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: New = %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	stdout := captureStdout(t)
+	file := filepath.Join(t.TempDir(), "app.log")
+	logger, level, err := New(Config{Level: "warn", Format: "json", File: file, FileMaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	// The level is changed at run time through its handler.
+	req := httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	level.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT level: %d %s", w.Code, w.Body)
+	}
+	logger.Debug("debugging")
+	logger.Sync()
+
+	want := []string{"shown", "debugging"}
+	for name, path := range map[string]string{"stdout": stdout, "file": file} {
+		if got := readJSONLines(t, path); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s holds %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConsoleFormat(t *testing.T) {
+	stdout := captureStdout(t)
+	logger, _, err := New(Config{Level: "info", Format: "console"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Server started")
+	logger.Sync()
+
+	body, err := os.ReadFile(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := strings.Split(strings.TrimSpace(string(body)), "\t"); len(fields) < 4 || fields[1] != "INFO" || fields[3] != "Server started" {
+		t.Errorf("console line %q", body)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tests := []struct {
+		sampling bool
+		want     int
+	}{
+		{false, 150},
+		{true, 100},
+	}
+	for _, tt := range tests {
+		stdout := captureStdout(t)
+		logger, _, err := New(Config{Level: "info", Format: "json", Sampling: tt.sampling})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 150; i++ {
+			logger.Info("Failed to reach the database")
+		}
+		logger.Sync()
+		if got := len(readJSONLines(t, stdout)); got != tt.want {
+			t.Errorf("sampling %v: %d entries, want %d", tt.sampling, got, tt.want)
+		}
+	}
+}
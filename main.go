@@ -1,35 +1,54 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
 
+	"github.com/gin-contrib/cors"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/saifujnu/books-authors/auth"
 	"github.com/saifujnu/books-authors/config"
 	"github.com/saifujnu/books-authors/controllers"
 	"github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/deadline"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/graphapi"
+	"github.com/saifujnu/books-authors/grpcapi"
+	"github.com/saifujnu/books-authors/health"
+	"github.com/saifujnu/books-authors/jobs"
+	"github.com/saifujnu/books-authors/logger"
+	"github.com/saifujnu/books-authors/metrics"
+	"github.com/saifujnu/books-authors/openapi"
+	"github.com/saifujnu/books-authors/outbox"
+	"github.com/saifujnu/books-authors/ratelimit"
+	"github.com/saifujnu/books-authors/requestlog"
+	"github.com/saifujnu/books-authors/tracing"
+	"github.com/saifujnu/books-authors/webhooks"
 )
 
 var (
 	Logger *zap.Logger
+	// logLevel changes the level of Logger at runtime, through /admin/log-level.
+	logLevel zap.AtomicLevel
 
 	// Define Prometheus metrics
-	apiRequests = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "myapp_api_requests_total",
-			Help: "Total number of API requests.",
-		},
-		[]string{"method"},
-	)
-
 	//--------------------------boook and author--------------------------------
 	successfulBookAuthorsFetch = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -37,22 +56,6 @@ var (
 			Help: "Total number of successful fetching",
 		},
 	)
-	// histogram for request duration
-	requestDurationHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "myapp_request_duration_seconds",
-			Help: "Histogram of request duration",
-		},
-		[]string{"route"},
-	)
-
-	// gauge metric for system status
-	systemStatus = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "myapp_system_status",
-			Help: "Current system status",
-		},
-	)
 
 	//This is working
 	// Define a custom Prometheus metric for successful logins
@@ -62,125 +65,524 @@ var (
 			Help: "Total number of successful logins.",
 		},
 	)
-
-	// Define Prometheus metric for Books API requests
-	booksAPIRequests = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "myapp_books_api_requests_total",
-			Help: "Total number of Books API requests.",
-		},
-		[]string{"method"},
-	)
 )
 
-func InitializeLogger() (*zap.Logger, error) {
-	logger, err := zap.NewDevelopment() // NewDevelopment also works for info debug and log
-	if err != nil {
-		return nil, err
+// setup loads the configuration and creates the logger. It is not an init
+// function so that the tests of this package do not parse their own flags
+// as configuration.
+func setup() {
+	// The .env file is optional; the environment can be set up otherwise.
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		panic(err)
 	}
-	return logger, nil
-}
 
-func init() {
-	err := godotenv.Load()
+	printOnly, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printOnly {
+		config.Current.Print(os.Stdout)
+		os.Exit(0)
 	}
-	config.SetEnvionment()
 
 	var errLogger error
-	Logger, errLogger = InitializeLogger() //error handleing of logger function
+	Logger, logLevel, errLogger = logger.New(logger.Config{
+		Level:          config.Current.Log.Level,
+		Format:         config.Current.Log.Format,
+		Sampling:       config.Current.Log.Sampling,
+		File:           config.Current.Log.File,
+		FileMaxSizeMB:  config.Current.Log.FileMaxSizeMB,
+		FileMaxBackups: config.Current.Log.FileMaxBackups,
+		FileMaxAgeDays: config.Current.Log.FileMaxAgeDays,
+	})
 	if errLogger != nil {
 		panic("Failed to initialize Zap logger: " + errLogger.Error())
 	}
+	zap.ReplaceGlobals(Logger)
+	Logger.Info("Configuration loaded", zap.Any("config", config.Current.Redacted()))
 }
 
 func main() {
-	m, err := mongo.Connect()
+	setup()
+
+	// Tracing comes first, so that the MongoDB client reports to it.
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Current.Tracing.Exporter, config.Current.Tracing.File)
+	if err != nil {
+		Logger.Error("Failed to set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+
+	m, err := mongo.Connect(metrics.CommandMonitor(), tracing.CommandMonitor())
 	if err != nil {
 		Logger.Error("Failed to connect to MongoDB", zap.Error(err))
 		os.Exit(1)
 	}
 
+	setupCtx, cancelSetup := context.WithTimeout(context.Background(), config.Current.Database.ConnectTimeout)
+	if err := mongo.EnsureIndexes(setupCtx, m); err != nil {
+		Logger.Error("Failed to create MongoDB indexes", zap.Error(err))
+		os.Exit(1)
+	}
+	// The admin role follows the configuration.
+	users := m.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Users)
+	if err := auth.SyncAdmins(setupCtx, users, config.Current.AdminUsers); err != nil {
+		Logger.Error("Failed to update admin roles", zap.Error(err))
+		os.Exit(1)
+	}
+	cancelSetup()
+
+	// Readiness depends on MongoDB; optional dependencies are added below and
+	// only reported.
+	healthChecker := health.NewChecker(config.Current.Health.Timeout)
+	healthChecker.Add("mongodb", true, func(ctx context.Context) error {
+		return m.Ping(ctx, nil)
+	})
+	systemStatus := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "myapp_system_status",
+			Help: "1 while the service is ready to take traffic, as of its last readiness check, 0 otherwise.",
+		},
+		func() float64 {
+			if healthChecker.Ready() {
+				return 1
+			}
+			return 0
+		},
+	)
+
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	router.Use(ginzap.Ginzap(Logger, time.RFC3339, true)) //wrapping zan with gin now it will give us logger as json
+	// The span is started and the request ID assigned before the request is
+	// logged, so that the log line carries both.
+	router.Use(tracing.Middleware())
+	router.Use(requestlog.Middleware(Logger))
+	router.Use(ginzap.GinzapWithConfig(Logger, &ginzap.Config{TimeFormat: time.RFC3339, UTC: true, SkipPaths: []string{"/healthz", "/readyz"}, Context: func(c *gin.Context) []zapcore.Field {
+		return append(requestlog.LogFields(c), tracing.LogFields(c)...)
+	}})) //wrapping zan with gin now it will give us logger as json
 	router.Use(ginzap.RecoveryWithZap(Logger, true))
+	router.Use(metrics.Middleware())
+
+	// Requests, and the database calls they make, stop at their deadline.
+	routeTimeouts, err := config.Current.HTTP.Timeouts()
+	if err != nil {
+		Logger.Error("Invalid route timeouts", zap.Error(err))
+		os.Exit(1)
+	}
+	router.Use(deadline.Middleware(config.Current.HTTP.RequestTimeout, routeTimeouts))
+
+	// Anonymous clients are limited by IP, which only the trusted proxies
+	// may give in X-Forwarded-For.
+	if err := router.SetTrustedProxies(config.Current.HTTP.TrustedProxies); err != nil {
+		Logger.Error("Invalid trusted proxies", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// Browsers may only call the API from the configured origins.
+	if cors := config.Current.CORS; len(cors.AllowedOrigins) > 0 {
+		router.Use(corsMiddleware(cors))
+	}
+
+	// Catalog changes are delivered to webhook subscribers in the background.
+	webhookDispatcher := webhooks.NewDispatcher(m, Logger)
+	webhookDispatcher.Start()
+
+	// Change streams and transactions need a replica set, which the default
+	// MONGODB_URI points at.
+	helloCtx, cancelHello := context.WithTimeout(context.Background(), config.Current.Database.ConnectTimeout)
+	replicated := mongo.IsReplicated(helloCtx, m)
+	cancelHello()
+
+	// Write handlers record their events in the outbox, which relays them to
+	// these sinks.
+	sinks := map[string]events.Publisher{"webhooks": webhookDispatcher}
+
+	// Change streams feed /changes with every write to the catalog. A
+	// standalone server has none, so the in-process bus stands in and only
+	// sees the writes made through the REST handlers.
+	var changeSource events.Source
+	if replicated {
+		changeSource = events.NewChangeStream(m)
+	} else {
+		Logger.Warn("MongoDB is not a replica set; /changes falls back to in-process events and writes run without transactions")
+		bus := events.NewBus(1000)
+		changeSource = bus
+		sinks["changes"] = bus
+	}
 
-	authorController := controllers.NewAuthorController(m, Logger)
-	bookController := controllers.NewBookController(m, Logger)
+	// The sinks are closed on shutdown, once the outbox has stopped.
+	var closers []func() error
+	if config.Current.Events.NATSURL != "" {
+		natsSink, err := outbox.NewNATSSink(config.Current.Events.NATSURL, config.Current.Events.NATSSubjectPrefix)
+		if err != nil {
+			Logger.Error("Failed to connect to NATS", zap.Error(err))
+			os.Exit(1)
+		}
+		sinks["nats"] = natsSink
+		closers = append(closers, natsSink.Close)
+		healthChecker.Add("nats", false, natsSink.Ping)
+	}
+	if config.Current.Events.File != "" {
+		fileSink, err := outbox.NewFileSink(config.Current.Events.File)
+		if err != nil {
+			Logger.Error("Failed to open events file", zap.Error(err))
+			os.Exit(1)
+		}
+		sinks["file"] = fileSink
+		closers = append(closers, fileSink.Close)
+	}
+
+	// Clients are rate limited per role; the buckets are shared between the
+	// instances through Redis, or kept by each one.
+	if policies, _ := config.Current.RateLimit.RatePolicies(); len(policies) > 0 {
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if config.Current.RateLimit.Backend == "redis" {
+			redisStore, err := ratelimit.NewRedisStore(config.Current.RateLimit.RedisURL)
+			if err != nil {
+				Logger.Error("Failed to set up Redis", zap.Error(err))
+				os.Exit(1)
+			}
+			store = redisStore
+			closers = append(closers, redisStore.Close)
+			// Requests go through unlimited while Redis is away.
+			healthChecker.Add("redis", false, redisStore.Ping)
+		}
+		apiKeys, _ := config.Current.RateLimit.APIKeyNames()
+		router.Use(ratelimit.New(store, Logger, policies, apiKeys).Middleware())
+	}
+
+	eventOutbox := outbox.New(m, Logger, sinks, replicated)
+	eventOutbox.Start()
+
+	authorController := controllers.NewAuthorController(m, Logger, eventOutbox)
+	bookController := controllers.NewBookController(m, Logger, eventOutbox)
 	authController := controllers.NewAuthController(m, Logger)
 
+	feedController := controllers.NewFeedController(m, Logger)
+	webhookController := controllers.NewWebhookController(Logger, webhookDispatcher)
+	changeController := controllers.NewChangeController(Logger, changeSource)
+	healthController := controllers.NewHealthController(Logger, healthChecker)
+
+	jobManager := jobs.NewManager(config.Current.Jobs.TTL)
+	importController := controllers.NewImportController(m, Logger, jobManager, eventOutbox)
+
+	graphqlHandler, err := graphapi.NewHandler(m, Logger, eventOutbox)
+	if err != nil {
+		Logger.Error("Failed to build GraphQL schema", zap.Error(err))
+		os.Exit(1)
+	}
+	api := routes{
+		books:    bookController,
+		authors:  authorController,
+		auth:     authController,
+		feeds:    feedController,
+		webhooks: webhookController,
+		changes:  changeController,
+		health:   healthController,
+		imports:  importController,
+		graphql:  graphqlHandler,
+	}
+	drift, err := api.register(router)
+	if err != nil {
+		Logger.Error("Failed to generate OpenAPI document", zap.Error(err))
+		os.Exit(1)
+	}
+	for _, route := range drift.Undocumented {
+		Logger.Warn("Route missing from OpenAPI document", zap.String("route", route))
+	}
+	for _, route := range drift.Stale {
+		Logger.Warn("OpenAPI document lists unregistered route", zap.String("route", route))
+	}
+
+	// Register the custom metrics to be exposed
+	metrics.Registry.MustRegister(successfulLogins, successfulBookAuthorsFetch, systemStatus)
+
+	// The gRPC service runs next to the HTTP API on its own port.
+	grpcListener, err := net.Listen("tcp", config.Current.GRPC.Addr)
+	if err != nil {
+		Logger.Error("Failed to listen for gRPC", zap.Error(err))
+		os.Exit(1)
+	}
+	grpcServer := grpcapi.NewServer(m, Logger, eventOutbox)
+	go func() {
+		Logger.Info("gRPC server started on " + config.Current.GRPC.Addr)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			Logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+
+	// Set the status gauge before the first probe.
+	healthChecker.Check(context.Background())
+
+	server := &http.Server{
+		Addr:              config.Current.HTTP.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: config.Current.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       config.Current.HTTP.ReadTimeout,
+		WriteTimeout:      config.Current.HTTP.WriteTimeout,
+		IdleTimeout:       config.Current.HTTP.IdleTimeout,
+	}
+	// Change streams never finish on their own; Shutdown ends them so that
+	// it does not wait for them until its deadline.
+	server.RegisterOnShutdown(changeController.Shutdown)
+
+	stop, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		Logger.Info("Server started on " + config.Current.HTTP.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		Logger.Error("Failed to start server", zap.Error(err))
+		os.Exit(1)
+	case <-stop.Done():
+	}
+	stopSignals() // A second signal kills the process at once.
+
+	Logger.Info("Shutting down", zap.Duration("timeout", config.Current.HTTP.ShutdownTimeout))
+	healthChecker.Drain()
+	if delay := config.Current.HTTP.DrainDelay; delay > 0 {
+		// Keep serving while the load balancers see /readyz fail.
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Current.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := shutdown(ctx, server, grpcServer, jobManager, eventOutbox, webhookDispatcher, closers); err != nil {
+		Logger.Error("Shutdown did not complete", zap.Error(err))
+	}
+
+	// The client is disconnected last, as everything above may still use it.
+	if err := m.Disconnect(ctx); err != nil {
+		Logger.Error("Failed to disconnect from MongoDB", zap.Error(err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		Logger.Error("Failed to flush traces", zap.Error(err))
+	}
+	Logger.Info("Server stopped")
+	Logger.Sync()
+}
+
+// routes holds the handlers of the HTTP API.
+type routes struct {
+	books    *controllers.BookController
+	authors  *controllers.AuthorController
+	auth     *controllers.AuthController
+	feeds    *controllers.FeedController
+	webhooks *controllers.WebhookController
+	changes  *controllers.ChangeController
+	health   *controllers.HealthController
+	imports  *controllers.ImportController
+	graphql  *graphapi.Handler
+}
+
+// register adds the API routes to router and generates the OpenAPI document
+// from them. The drift it returns lists the routes and operations that do
+// not match.
+func (r routes) register(router *gin.Engine) (openapi.Drift, error) {
+	// Probes for the orchestrator; they are not logged.
+	router.GET("/healthz", r.health.Healthz)
+	router.GET("/readyz", r.health.Readyz)
+
 	authRoutes := router.Group("/auth")
 	{
-		authRoutes.POST("/signup", authController.Signup)
+		authRoutes.POST("/signup", r.auth.Signup)
 		authRoutes.POST("/login", func(c *gin.Context) {
 
 			successfulLogins.Inc()
-			authController.Login(c)
+			r.auth.Login(c)
 		})
 	}
 
-	// Middleware to increment API request count
-	router.Use(func(c *gin.Context) {
-		apiRequests.WithLabelValues(c.Request.Method).Inc()
-		c.Next()
-	})
-
-	start := time.Now()
-
 	bookRoutes := router.Group("/books")
 
 	bookRoutes.Use(auth.JWTMiddleware())
 	{
-		bookRoutes.GET("/", bookController.GetBooks)
-		bookRoutes.GET("/:id", bookController.GetBookByID)
-		bookRoutes.POST("/", bookController.CreateBook)
-		bookRoutes.PUT("/:id", bookController.UpdateBook)
-		bookRoutes.DELETE("/:id", bookController.DeleteBook)
+		bookRoutes.GET("/", r.books.GetBooks)
+		bookRoutes.GET("/:id", r.books.GetBookByID)
+		bookRoutes.POST("/", r.books.CreateBook)
+		bookRoutes.POST("/bulk", r.books.BulkBooks)
+		bookRoutes.PUT("/:id", r.books.UpdateBook)
+		bookRoutes.PATCH("/:id", r.books.PatchBook)
+		bookRoutes.DELETE("/:id", r.books.DeleteBook)
 		bookRoutes.GET("/books-and-authors", func(c *gin.Context) {
 			successfulBookAuthorsFetch.Inc()
-			bookController.GetAllBooksAndAuthors(c)
+			r.books.GetAllBooksAndAuthors(c)
 
 		})
-		duration := time.Since(start)
-		requestDurationHistogram.WithLabelValues("books-and-authors").Observe(duration.Seconds())
-		systemStatus.Set(1)
-
-		bookRoutes.GET("/books-by-author/:authorName", bookController.GetBooksByAuthorName)
+		bookRoutes.GET("/books-by-author/:authorName", r.books.GetBooksByAuthorName)
 	}
 
-	// Middleware to increment Books API request count
-	bookRoutes.Use(func(c *gin.Context) {
-		booksAPIRequests.WithLabelValues(c.Request.Method).Inc()
-		c.Next()
-	})
-
 	authorRoutes := router.Group("/authors")
 	authorRoutes.Use(auth.JWTMiddleware())
 	{
-		authorRoutes.GET("/", authorController.GetAuthors)
-		authorRoutes.GET("/:id", authorController.GetAuthorByID)
-		authorRoutes.POST("/", authorController.CreateAuthor)
-		authorRoutes.PUT("/:id", authorController.UpdateAuthor)
-		authorRoutes.DELETE("/:id", authorController.DeleteAuthor)
+		authorRoutes.GET("/", r.authors.GetAuthors)
+		authorRoutes.GET("/:id", r.authors.GetAuthorByID)
+		authorRoutes.POST("/", r.authors.CreateAuthor)
+		authorRoutes.POST("/bulk", r.authors.BulkAuthors)
+		authorRoutes.PUT("/:id", r.authors.UpdateAuthor)
+		authorRoutes.PATCH("/:id", r.authors.PatchAuthor)
+		authorRoutes.DELETE("/:id", r.authors.DeleteAuthor)
 	}
 
-	// Register the custom metrics to be exposed
-	prometheus.MustRegister(successfulLogins)
-	prometheus.MustRegister(booksAPIRequests)
-	//prometheus.MustRegister(successfulBookAuthorsFetch)
-	prometheus.MustRegister(successfulBookAuthorsFetch, requestDurationHistogram, systemStatus)
+	// OPDS catalog feeds are public so that e-reader apps can browse them.
+	opdsRoutes := router.Group("/opds")
+	{
+		opdsRoutes.GET("", r.feeds.OPDSRoot)
+		opdsRoutes.GET("/books", r.feeds.OPDSBooks)
+		opdsRoutes.GET("/authors", r.feeds.OPDSAuthors)
+		opdsRoutes.GET("/authors/:id", r.feeds.OPDSAuthorBooks)
+		opdsRoutes.GET("/search", r.feeds.OPDSSearch)
+		opdsRoutes.GET("/opensearch.xml", r.feeds.OPDSOpenSearch)
+
+		opdsRoutes.GET("/v2", r.feeds.OPDSRoot)
+		opdsRoutes.GET("/v2/books", r.feeds.OPDSBooks)
+		opdsRoutes.GET("/v2/authors", r.feeds.OPDSAuthors)
+		opdsRoutes.GET("/v2/authors/:id", r.feeds.OPDSAuthorBooks)
+		opdsRoutes.GET("/v2/search", r.feeds.OPDSSearch)
+	}
+
+	// Atom and RSS feeds of new and updated books, public like the catalog.
+	feedRoutes := router.Group("/feeds")
+	{
+		feedRoutes.GET("/books.atom", r.feeds.BooksAtom)
+		feedRoutes.GET("/books.rss", r.feeds.BooksRSS)
+		feedRoutes.GET("/authors/:id/books.atom", r.feeds.AuthorBooksAtom)
+		feedRoutes.GET("/authors/:id/books.rss", r.feeds.AuthorBooksRSS)
+	}
+
+	webhookRoutes := router.Group("/webhooks")
+	webhookRoutes.Use(auth.JWTMiddleware(), auth.RequireAdmin())
+	{
+		webhookRoutes.POST("", r.webhooks.CreateWebhook)
+		webhookRoutes.GET("", r.webhooks.GetWebhooks)
+		webhookRoutes.GET("/dead-letters", r.webhooks.GetDeadLetters)
+		webhookRoutes.POST("/deliveries/:id/replay", r.webhooks.ReplayDelivery)
+		webhookRoutes.GET("/:id", r.webhooks.GetWebhookByID)
+		webhookRoutes.DELETE("/:id", r.webhooks.DeleteWebhook)
+		webhookRoutes.GET("/:id/deliveries", r.webhooks.GetWebhookDeliveries)
+	}
+
+	changeRoutes := router.Group("/changes")
+	changeRoutes.Use(auth.JWTMiddleware())
+	{
+		changeRoutes.GET("", r.changes.StreamChanges)
+	}
+
+	graphqlRoutes := router.Group("/graphql")
+	graphqlRoutes.Use(auth.JWTMiddleware())
+	{
+		graphqlRoutes.POST("", r.graphql.Serve)
+	}
+
+	exportRoutes := router.Group("/export")
+	exportRoutes.Use(auth.JWTMiddleware())
+	{
+		exportRoutes.GET("", r.books.Export)
+	}
+
+	importRoutes := router.Group("/import")
+	importRoutes.Use(auth.JWTMiddleware())
+	{
+		importRoutes.POST("", r.imports.Import)
+		importRoutes.POST("/onix", r.imports.ImportONIX)
+		importRoutes.GET("/:id", r.imports.GetImport)
+		importRoutes.GET("/:id/errors", r.imports.GetImportErrors)
+	}
+
+	// The log level can be changed without a restart, by admins only.
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(auth.JWTMiddleware(), auth.RequireAdmin())
+	{
+		adminRoutes.GET("/log-level", gin.WrapH(logLevel))
+		adminRoutes.PUT("/log-level", gin.WrapH(logLevel))
+	}
 
 	// Expose /metrics endpoint for Prometheus
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/metrics", metrics.Handler())
 
-	Logger.Info("Server started on :8080")
+	// The OpenAPI document is generated from the registered routes, so these
+	// must stay the last routes added.
+	apiDocs := &openapi.Docs{}
+	router.GET("/openapi.json", apiDocs.Spec)
+	router.GET("/docs", apiDocs.UI)
+	router.GET("/docs/redoc.standalone.js", apiDocs.Script)
+	return apiDocs.Generate(router.Routes())
+}
 
-	err1 := router.Run(":8080")
-	if err1 != nil {
-		Logger.Error("Failed to start server", zap.Error(err1))
-		os.Exit(1)
+// shutdown stops the servers and then the background workers, in the order
+// that lets each hand its work to the next: the servers finish their
+// requests, the import jobs are cancelled, the outbox relays what it has
+// claimed and the dispatcher its deliveries. It returns the first error but
+// carries on, so that every component gets its chance to stop.
+func shutdown(ctx context.Context, server *http.Server, grpcServer *grpc.Server, jobManager *jobs.Manager, eventOutbox *outbox.Outbox, webhookDispatcher *webhooks.Dispatcher, closers []func() error) error {
+	var first error
+	record := func(what string, err error) {
+		if err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", what, err)
+		}
+	}
+
+	// Both servers stop accepting connections before either drains.
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		grpcServer.GracefulStop()
+	}()
+	record("HTTP server", server.Shutdown(ctx))
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+		<-grpcStopped
+		record("gRPC server", ctx.Err())
+	}
+	if ctx.Err() != nil {
+		// Cut the connections of the requests still running.
+		server.Close()
+	}
+
+	record("import jobs", jobManager.Shutdown(ctx))
+	record("outbox", eventOutbox.Shutdown(ctx))
+	record("webhook dispatcher", webhookDispatcher.Shutdown(ctx))
+	for _, closeSink := range closers {
+		record("event sink", closeSink())
+	}
+	return first
+}
+
+// exposedHeaders are the response headers the API documents.
+var exposedHeaders = []string{
+	"ETag", "Location", requestlog.Header, "Retry-After",
+	ratelimit.HeaderLimit, ratelimit.HeaderRemaining, ratelimit.HeaderReset, ratelimit.HeaderPolicy,
+}
+
+// corsMiddleware answers preflight requests and adds the CORS headers for
+// the allowed origins. Clients may read the headers the API documents.
+func corsMiddleware(c config.CORS) gin.HandlerFunc {
+	allowAll := false
+	for _, origin := range c.AllowedOrigins {
+		allowAll = allowAll || origin == "*"
+	}
+	corsConfig := cors.Config{
+		AllowAllOrigins:  allowAll,
+		AllowMethods:     c.AllowedMethods,
+		AllowHeaders:     c.AllowedHeaders,
+		ExposeHeaders:    exposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+	if !allowAll {
+		corsConfig.AllowOrigins = c.AllowedOrigins
 	}
+	return cors.New(corsConfig)
 }
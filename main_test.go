@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/saifujnu/books-authors/controllers"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/graphapi"
+	"github.com/saifujnu/books-authors/health"
+	"github.com/saifujnu/books-authors/jobs"
+	"github.com/saifujnu/books-authors/openapi"
+	"github.com/saifujnu/books-authors/outbox"
+	"github.com/saifujnu/books-authors/webhooks"
+)
+
+// testRouter registers the API routes on a new router. The MongoDB client
+// never connects: building the routes must not need the database.
+func testRouter(t *testing.T) (*gin.Engine, openapi.Drift) {
+	t.Helper()
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	logger := zap.NewNop()
+	ob := outbox.New(client, logger, map[string]events.Publisher{"test": events.Discard}, false)
+	graphqlHandler, err := graphapi.NewHandler(client, logger, ob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := routes{
+		books:    controllers.NewBookController(client, logger, ob),
+		authors:  controllers.NewAuthorController(client, logger, ob),
+		auth:     controllers.NewAuthController(client, logger),
+		feeds:    controllers.NewFeedController(client, logger),
+		webhooks: controllers.NewWebhookController(logger, webhooks.NewDispatcher(client, logger)),
+		changes:  controllers.NewChangeController(logger, events.NewBus(1)),
+		health:   controllers.NewHealthController(logger, health.NewChecker(time.Second)),
+		imports:  controllers.NewImportController(client, logger, jobs.NewManager(time.Hour), ob),
+		graphql:  graphqlHandler,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	drift, err := api.register(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, drift
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	_, drift := testRouter(t)
+	for _, route := range drift.Undocumented {
+		t.Errorf("%s is missing from the operations table", route)
+	}
+	for _, route := range drift.Stale {
+		t.Errorf("%s is documented but not registered", route)
+	}
+}
+
+func TestDocsServeEmbeddedRedoc(t *testing.T) {
+	router, _ := testRouter(t)
+
+	tests := []struct {
+		target      string
+		contentType string
+		contains    string
+	}{
+		{"/docs", "text/html", `<script src="/docs/redoc.standalone.js">`},
+		{"/docs/redoc.standalone.js", "text/javascript", openapi.RedocVersion},
+		{"/openapi.json", "application/json", `"/docs/redoc.standalone.js"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("body does not contain %q", tt.contains)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	logger := zap.NewNop()
+
+	tests := []struct {
+		name string
+		// request is how long the request in flight takes; 0 for one that
+		// runs until it is cut.
+		request time.Duration
+		timeout time.Duration
+		err     string
+		served  bool
+	}{
+		{"in-flight requests finish", 100 * time.Millisecond, 5 * time.Second, "", true},
+		{"deadline cuts requests", 0, 100 * time.Millisecond, "HTTP server: context deadline exceeded", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			changes := controllers.NewChangeController(logger, events.NewBus(1))
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/slow", func(c *gin.Context) {
+				close(started)
+				if tt.request == 0 {
+					<-c.Request.Context().Done()
+					return
+				}
+				time.Sleep(tt.request)
+				c.Status(http.StatusOK)
+			})
+			router.GET("/changes", changes.StreamChanges)
+
+			httpServer := httptest.NewUnstartedServer(router)
+			httpServer.Config.RegisterOnShutdown(changes.Shutdown)
+			httpServer.Start()
+			defer httpServer.Close()
+
+			// An open change stream must not hold up the shutdown.
+			stream, err := http.Get(httpServer.URL + "/changes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Body.Close()
+
+			served := make(chan bool, 1)
+			go func() {
+				resp, err := http.Get(httpServer.URL + "/slow")
+				if err == nil {
+					resp.Body.Close()
+				}
+				served <- err == nil && resp.StatusCode == http.StatusOK
+			}()
+			<-started
+
+			jobManager := jobs.NewManager(time.Hour)
+			job := jobManager.Start("import", func(ctx context.Context) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			var closed int
+			closers := []func() error{func() error { closed++; return nil }}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			err = shutdown(ctx, httpServer.Config, grpc.NewServer(), jobManager,
+				outbox.New(client, logger, nil, false), webhooks.NewDispatcher(client, logger), closers)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("shutdown = %v, want no error", err)
+			case tt.err != "" && fmt.Sprint(err) != tt.err:
+				t.Errorf("shutdown = %v, want %s", err, tt.err)
+			}
+
+			if got := <-served; got != tt.served {
+				t.Errorf("request in flight served = %v, want %v", got, tt.served)
+			}
+			if _, err := io.ReadAll(stream.Body); err != nil {
+				t.Errorf("change stream ended with %v", err)
+			}
+			if job, _ := jobManager.Get(job.ID); job.Status != jobs.StatusCancelled {
+				t.Errorf("import job %s, want cancelled", job.Status)
+			}
+			if closed != 1 {
+				t.Errorf("event sinks closed %d times, want once", closed)
+			}
+		})
+	}
+}
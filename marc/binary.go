@@ -0,0 +1,201 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ISO 2709 structural characters.
+const (
+	fieldTerminator    = 0x1e
+	recordTerminator   = 0x1d
+	subfieldDelimiter  = 0x1f
+	leaderLength       = 24
+	directoryEntrySize = 12
+	maxRecordLength    = 99999
+)
+
+// Reader reads MARC 21 records in ISO 2709 ("binary") transmission format.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more records.
+func (mr *Reader) Read() (*Record, error) {
+	// Skip whitespace some tools put between records.
+	for {
+		b, err := mr.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' && b[0] != '\r' && b[0] != ' ' {
+			break
+		}
+		mr.r.ReadByte()
+	}
+
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(mr.r, prefix); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("marc: truncated record length")
+		}
+		return nil, err
+	}
+	length, err := decimal(prefix)
+	if err != nil || length < leaderLength+1 {
+		return nil, fmt.Errorf("marc: invalid record length %q", prefix)
+	}
+
+	data := make([]byte, length)
+	copy(data, prefix)
+	if _, err := io.ReadFull(mr.r, data[5:]); err != nil {
+		return nil, fmt.Errorf("marc: truncated record: %w", err)
+	}
+	return Decode(data)
+}
+
+// Decode parses a single ISO 2709 record.
+func Decode(data []byte) (*Record, error) {
+	if len(data) < leaderLength+1 || data[len(data)-1] != recordTerminator {
+		return nil, errors.New("marc: missing record terminator")
+	}
+
+	leader := string(data[:leaderLength])
+	base, err := decimal(data[12:17])
+	if err != nil || base <= leaderLength || base > len(data) {
+		return nil, fmt.Errorf("marc: invalid base address %q", leader[12:17])
+	}
+
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryEntrySize != 0 {
+		return nil, errors.New("marc: malformed directory")
+	}
+
+	record := &Record{Leader: leader}
+	for i := 0; i < len(directory); i += directoryEntrySize {
+		entry := directory[i : i+directoryEntrySize]
+		tag := string(entry[:3])
+		length, err1 := decimal(entry[3:7])
+		start, err2 := decimal(entry[7:12])
+		if err1 != nil || err2 != nil || length < 1 || base+start+length > len(data) {
+			return nil, fmt.Errorf("marc: invalid directory entry for tag %s", tag)
+		}
+
+		body := data[base+start : base+start+length]
+		body = bytes.TrimSuffix(body, []byte{fieldTerminator})
+		record.Fields = append(record.Fields, decodeField(tag, body))
+	}
+	return record, nil
+}
+
+// decimal parses one of the fixed-width numbers of the leader and the
+// directory. Unlike strconv.Atoi it refuses a sign, which would let an entry
+// point before its record.
+func decimal(digits []byte) (int, error) {
+	n, err := strconv.ParseUint(string(digits), 10, 32)
+	return int(n), err
+}
+
+func decodeField(tag string, body []byte) Field {
+	if IsControl(tag) {
+		return Field{Tag: tag, Value: string(body)}
+	}
+
+	field := Field{Tag: tag, Ind1: ' ', Ind2: ' '}
+	if len(body) >= 2 {
+		field.Ind1, field.Ind2 = body[0], body[1]
+		body = body[2:]
+	}
+	for _, chunk := range bytes.Split(body, []byte{subfieldDelimiter}) {
+		if len(chunk) == 0 {
+			continue
+		}
+		field.Subfields = append(field.Subfields, Subfield{Code: chunk[0], Value: string(chunk[1:])})
+	}
+	return field
+}
+
+// Writer writes MARC 21 records in ISO 2709 transmission format.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (mw *Writer) Write(record *Record) error {
+	data, err := Encode(record)
+	if err != nil {
+		return err
+	}
+	_, err = mw.w.Write(data)
+	return err
+}
+
+// Encode serialises a record in ISO 2709 format. The record length and base
+// address of the leader are computed; the rest of the leader is kept.
+func Encode(record *Record) ([]byte, error) {
+	var directory, fields bytes.Buffer
+	for _, field := range record.Fields {
+		if len(field.Tag) != 3 {
+			return nil, fmt.Errorf("marc: invalid tag %q", field.Tag)
+		}
+
+		start := fields.Len()
+		if IsControl(field.Tag) {
+			fields.WriteString(field.Value)
+		} else {
+			fields.WriteByte(indicator(field.Ind1))
+			fields.WriteByte(indicator(field.Ind2))
+			for _, subfield := range field.Subfields {
+				fields.WriteByte(subfieldDelimiter)
+				fields.WriteByte(subfield.Code)
+				fields.WriteString(subfield.Value)
+			}
+		}
+		fields.WriteByte(fieldTerminator)
+
+		length := fields.Len() - start
+		if length > 9999 {
+			return nil, fmt.Errorf("marc: field %s is too long", field.Tag)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", field.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	base := leaderLength + directory.Len()
+	total := base + fields.Len() + 1
+	if total > maxRecordLength {
+		return nil, errors.New("marc: record is too long")
+	}
+
+	leader := []byte(record.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(defaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, total)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, fields.Bytes()...)
+	out = append(out, recordTerminator)
+	return out, nil
+}
+
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
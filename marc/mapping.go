@@ -0,0 +1,184 @@
+package marc
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// Entry is a MARC record mapped onto the catalog models. Author is the main
+// entry (100); added entries (700) become the book's contributors.
+type Entry struct {
+	Book   models.Book
+	Author *models.Author
+}
+
+// ToEntry maps the fields the catalog knows about:
+//
+//	020 $a    ISBN
+//	100 $a $e main author and role
+//	245 $a $b title and remainder of title
+//	260/264   publication place ($a), publisher ($b) and date ($c)
+//	700 $a $e contributors
+func ToEntry(record *Record) Entry {
+	var entry Entry
+
+	if field, ok := record.Field("020"); ok {
+		entry.Book.ISBN = cleanISBN(field.Subfield('a'))
+	}
+
+	if field, ok := record.Field("245"); ok {
+		title := trimPunctuation(field.Subfield('a'))
+		if remainder := trimPunctuation(field.Subfield('b')); remainder != "" {
+			title += ": " + remainder
+		}
+		entry.Book.Title = title
+	}
+
+	if field, ok := record.Field("100"); ok {
+		if author := nameToAuthor(field); author.FirstName != "" || author.LastName != "" {
+			entry.Author = &author
+		}
+	}
+
+	for _, field := range record.FieldsByTag("700") {
+		name := trimPunctuation(field.Subfield('a'))
+		if name == "" {
+			continue
+		}
+		entry.Book.Contributors = append(entry.Book.Contributors, models.Contributor{
+			Name: name,
+			Role: trimPunctuation(field.Subfield('e')),
+		})
+	}
+
+	entry.Book.Publication = publication(record)
+	return entry
+}
+
+// publication prefers the 264 publication statement (second indicator 1) and
+// falls back to the older 260 imprint.
+func publication(record *Record) *models.Publication {
+	var imprint *Field
+	for _, field := range record.FieldsByTag("264") {
+		if field.Ind2 == '1' {
+			imprint = &field
+			break
+		}
+	}
+	if imprint == nil {
+		if field, ok := record.Field("260"); ok {
+			imprint = &field
+		}
+	}
+	if imprint == nil {
+		return nil
+	}
+
+	pub := &models.Publication{
+		Place:     strings.Trim(trimPunctuation(imprint.Subfield('a')), "[]"),
+		Publisher: trimPunctuation(imprint.Subfield('b')),
+		Date:      trimPunctuation(imprint.Subfield('c')),
+	}
+	if *pub == (models.Publication{}) {
+		return nil
+	}
+	return pub
+}
+
+// nameToAuthor reads a personal name field. First indicator 1 means the name
+// is inverted ("Surname, Forename"); 0 means forename only.
+func nameToAuthor(field Field) models.Author {
+	name := trimPunctuation(field.Subfield('a'))
+	if i := strings.Index(name, ","); i >= 0 && field.Ind1 != '0' {
+		return models.Author{
+			FirstName: strings.TrimSpace(name[i+1:]),
+			LastName:  strings.TrimSpace(name[:i]),
+		}
+	}
+	return models.Author{FirstName: name}
+}
+
+// FromEntry builds a MARC record from a book and its main author. The book ID
+// is written to the 001 control number.
+func FromEntry(book models.Book, author *models.Author) *Record {
+	record := NewRecord()
+	if !book.ID.IsZero() {
+		record.AddControl("001", book.ID.Hex())
+	}
+
+	record.AddData("020", ' ', ' ', Subfield{'a', book.ISBN})
+
+	titleInd1 := byte('0')
+	if author != nil {
+		record.AddData("100", '1', ' ', Subfield{'a', invertedName(*author)})
+		titleInd1 = '1'
+	}
+	record.AddData("245", titleInd1, '0', Subfield{'a', book.Title})
+
+	if pub := book.Publication; pub != nil {
+		record.AddData("264", ' ', '1',
+			Subfield{'a', pub.Place},
+			Subfield{'b', pub.Publisher},
+			Subfield{'c', pub.Date},
+		)
+	}
+
+	for _, contributor := range book.Contributors {
+		ind1 := byte('0')
+		if strings.Contains(contributor.Name, ",") {
+			ind1 = '1'
+		}
+		record.AddData("700", ind1, ' ',
+			Subfield{'a', contributor.Name},
+			Subfield{'e', contributor.Role},
+		)
+	}
+	return record
+}
+
+func invertedName(author models.Author) string {
+	switch {
+	case author.LastName == "":
+		return author.FirstName
+	case author.FirstName == "":
+		return author.LastName
+	}
+	return author.LastName + ", " + author.FirstName
+}
+
+// cleanISBN drops qualifiers such as "(pbk.)" that follow the number in 020 $a.
+func cleanISBN(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// trimPunctuation removes the ISBD punctuation cataloguers put at the end of
+// subfields (" /", " :", " ;", ","). A final period is removed too, unless it
+// ends an initial such as in "Tolkien, J. R. R.".
+func trimPunctuation(s string) string {
+	s = strings.TrimSpace(s)
+	for {
+		trimmed := strings.TrimRight(s, " /:;,=")
+		if strings.HasSuffix(trimmed, ".") && !endsWithInitial(trimmed) {
+			trimmed = strings.TrimSuffix(trimmed, ".")
+		}
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
+
+func endsWithInitial(s string) bool {
+	runes := []rune(strings.TrimSuffix(s, "."))
+	n := len(runes)
+	if n == 0 || !unicode.IsUpper(runes[n-1]) {
+		return false
+	}
+	return n == 1 || runes[n-2] == ' ' || runes[n-2] == '.'
+}
@@ -0,0 +1,183 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// The fixtures hold the same two records: a current RDA record (264, 700s,
+// a UTF-8 name) and an older AACR2 one (260, forename-only main entry).
+var fixtureEntries = []Entry{
+	{
+		Book: models.Book{
+			ISBN:  "9780000000011",
+			Title: "The book: a novel",
+			Contributors: []models.Contributor{
+				{Name: "Translator, Tom", Role: "translator"},
+				{Name: "Müller, Jörg", Role: "illustrator"},
+			},
+			Publication: &models.Publication{Place: "London", Publisher: "Press", Date: "2020"},
+		},
+		Author: &models.Author{FirstName: "Ann", LastName: "Writer"},
+	},
+	{
+		Book: models.Book{
+			ISBN:        "0000000022",
+			Title:       "The Odyssey",
+			Publication: &models.Publication{Place: "New York", Publisher: "Penguin", Date: "1996"},
+		},
+		Author: &models.Author{FirstName: "Homer"},
+	},
+}
+
+type format struct {
+	name    string
+	fixture string
+	read    func(io.Reader) func() (*Record, error)
+	write   func(w io.Writer, records []*Record) error
+}
+
+var formats = []format{
+	{
+		name:    "ISO 2709",
+		fixture: "testdata/records.mrc",
+		read:    func(r io.Reader) func() (*Record, error) { return NewReader(r).Read },
+		write: func(w io.Writer, records []*Record) error {
+			mw := NewWriter(w)
+			for _, record := range records {
+				if err := mw.Write(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:    "MARCXML",
+		fixture: "testdata/records.xml",
+		read:    func(r io.Reader) func() (*Record, error) { return NewXMLReader(r).Read },
+		write: func(w io.Writer, records []*Record) error {
+			xw := NewXMLWriter(w)
+			for _, record := range records {
+				if err := xw.Write(record); err != nil {
+					return err
+				}
+			}
+			return xw.Close()
+		},
+	},
+}
+
+func readAll(t *testing.T, read func() (*Record, error)) []*Record {
+	t.Helper()
+	var records []*Record
+	for {
+		record, err := read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func readFixture(t *testing.T, f format) []*Record {
+	t.Helper()
+	file, err := os.Open(f.fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	return readAll(t, f.read(file))
+}
+
+func TestFixturesMapToEntries(t *testing.T) {
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			records := readFixture(t, f)
+			if len(records) != len(fixtureEntries) {
+				t.Fatalf("read %d records, want %d", len(records), len(fixtureEntries))
+			}
+			for i, record := range records {
+				if got := ToEntry(record); !reflect.DeepEqual(got, fixtureEntries[i]) {
+					t.Errorf("record %d: entry = %+v, want %+v", i, got, fixtureEntries[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFixturesAgree(t *testing.T) {
+	binary, xml := readFixture(t, formats[0]), readFixture(t, formats[1])
+	if !reflect.DeepEqual(binary, xml) {
+		t.Errorf("ISO 2709 records = %+v\nMARCXML records = %+v", binary, xml)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			records := readFixture(t, f)
+
+			// Records read back unchanged.
+			var buf bytes.Buffer
+			if err := f.write(&buf, records); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if got := readAll(t, f.read(&buf)); !reflect.DeepEqual(got, records) {
+				t.Errorf("records after writing = %+v, want %+v", got, records)
+			}
+
+			// Entries written as records map back to the same entries.
+			var written []*Record
+			for _, record := range records {
+				entry := ToEntry(record)
+				written = append(written, FromEntry(entry.Book, entry.Author))
+			}
+			buf.Reset()
+			if err := f.write(&buf, written); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			for i, record := range readAll(t, f.read(&buf)) {
+				if got := ToEntry(record); !reflect.DeepEqual(got, fixtureEntries[i]) {
+					t.Errorf("record %d: entry after writing = %+v, want %+v", i, got, fixtureEntries[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsMalformedRecords(t *testing.T) {
+	valid, err := os.ReadFile("testdata/records.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := valid[:361]
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no record terminator", first[:len(first)-1]},
+		{"bad base address", append([]byte(string(first[:12])+"x"), first[13:]...)},
+		{"short directory", append(append([]byte(string(first[:24])), first[25:]...), 0)},
+		{"negative field length", append([]byte(string(first[:27])+"-001"), first[31:]...)},
+		{"signed field start", append([]byte(string(first[:31])+"+0000"), first[36:]...)},
+		{"empty field", append([]byte(string(first[:27])+"0000"), first[31:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil {
+				t.Error("Decode succeeded, want an error")
+			}
+		})
+	}
+}
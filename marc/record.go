@@ -0,0 +1,90 @@
+package marc
+
+import "strings"
+
+// Record is a MARC 21 bibliographic record.
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+// Field is either a control field (tags 001-009), which only has a Value, or
+// a data field with indicators and subfields.
+type Field struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// IsControl reports whether tag names a control field.
+func IsControl(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
+
+// FieldsByTag returns all fields with the given tag, in record order.
+func (r *Record) FieldsByTag(tag string) []Field {
+	var fields []Field
+	for _, field := range r.Fields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Field returns the first field with the given tag.
+func (r *Record) Field(tag string) (Field, bool) {
+	for _, field := range r.Fields {
+		if field.Tag == tag {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Subfield returns the first subfield with the given code.
+func (f Field) Subfield(code byte) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+	return ""
+}
+
+// defaultLeader is used for records we write: a new ("n") language material
+// ("a") monograph ("m") with UTF-8 ("a") character coding. The record length
+// and base address are filled in when the record is encoded.
+const defaultLeader = "00000nam a2200000 i 4500"
+
+// NewRecord returns an empty record with the default leader.
+func NewRecord() *Record {
+	return &Record{Leader: defaultLeader}
+}
+
+// AddControl appends a control field.
+func (r *Record) AddControl(tag, value string) {
+	r.Fields = append(r.Fields, Field{Tag: tag, Value: value})
+}
+
+// AddData appends a data field. Subfields with an empty value are dropped,
+// and so is the whole field when none are left.
+func (r *Record) AddData(tag string, ind1, ind2 byte, subfields ...Subfield) {
+	var kept []Subfield
+	for _, subfield := range subfields {
+		if subfield.Value != "" {
+			kept = append(kept, subfield)
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+	r.Fields = append(r.Fields, Field{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: kept})
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric of the service.
+const namespace = "myapp"
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+// Registry holds the metrics served on /metrics: those of this package, the
+// Go runtime and process collectors, and whatever else main registers.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests.",
+		},
+		[]string{"route", "method", "status"},
+	)
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+	httpResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 7),
+		},
+		[]string{"route", "method", "status"},
+	)
+	httpInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		},
+		[]string{"route", "method"},
+	)
+	httpTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_timeouts_total",
+			Help:      "Total number of HTTP requests that ran past their deadline.",
+		},
+		[]string{"route", "method"},
+	)
+	httpRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_rate_limited_total",
+			Help:      "Total number of HTTP requests refused by the rate limiter.",
+		},
+		[]string{"route", "method", "role"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler)),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		httpResponseSize,
+		httpInFlight,
+		httpTimeouts,
+		httpRateLimited,
+		mongoCommands,
+		mongoDuration,
+	)
+}
+
+// Handler serves the metrics of Registry.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// Middleware records the count, latency and response size of every
+// request, labelled with the route template rather than the path, and the
+// number of requests in flight.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+
+		inFlight := httpInFlight.WithLabelValues(route, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		status := statusClass(c.Writer.Status())
+		httpRequests.WithLabelValues(route, method, status).Inc()
+		httpDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		httpResponseSize.WithLabelValues(route, method, status).Observe(float64(size))
+	}
+}
+
+// Timeout counts a request to route that ran past its deadline. Unmatched
+// requests have an empty route.
+func Timeout(route, method string) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	httpTimeouts.WithLabelValues(route, method).Inc()
+}
+
+// RateLimited counts a request to route refused because a client of role
+// made too many. Unmatched requests have an empty route.
+func RateLimited(route, method, role string) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	httpRateLimited.WithLabelValues(route, method, role).Inc()
+}
+
+// statusClass returns "2xx" for 200 and so on.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
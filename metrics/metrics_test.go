@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestStatusClass(t *testing.T) {
+	tests := map[int]string{200: "2xx", 204: "2xx", 304: "3xx", 404: "4xx", 429: "4xx", 503: "5xx"}
+	for status, want := range tests {
+		if got := statusClass(status); got != want {
+			t.Errorf("statusClass(%d) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/books/:id", func(c *gin.Context) {
+		if got := testutil.ToFloat64(httpInFlight.WithLabelValues("/books/:id", http.MethodGet)); got != 1 {
+			t.Errorf("%v requests in flight while serving, want 1", got)
+		}
+		if c.Param("id") == "missing" {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		c.String(http.StatusOK, "%s", strings.Repeat("x", 150))
+	})
+
+	tests := []struct {
+		target string
+		route  string
+		status string
+	}{
+		{"/books/1", "/books/:id", "2xx"},
+		{"/books/2", "/books/:id", "2xx"},
+		{"/books/missing", "/books/:id", "4xx"},
+		{"/wp-login.php", unmatchedRoute, "4xx"},
+	}
+	before := map[[3]string]float64{}
+	for _, tt := range tests {
+		key := [3]string{tt.route, http.MethodGet, tt.status}
+		before[key] = testutil.ToFloat64(httpRequests.WithLabelValues(key[:]...))
+	}
+	for _, tt := range tests {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+	}
+
+	want := map[[3]string]float64{}
+	for _, tt := range tests {
+		want[[3]string{tt.route, http.MethodGet, tt.status}]++
+	}
+	for key, n := range want {
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(key[:]...)) - before[key]; got != n {
+			t.Errorf("requests%v = %v, want %v", key, got, n)
+		}
+	}
+	if got := testutil.ToFloat64(httpInFlight.WithLabelValues("/books/:id", http.MethodGet)); got != 0 {
+		t.Errorf("%v requests in flight after serving, want 0", got)
+	}
+	if got := testutil.CollectAndCount(httpResponseSize); got < len(want) {
+		t.Errorf("%d response size series, want at least %d", got, len(want))
+	}
+}
+
+func TestCounters(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   func()
+		counter prometheus.Counter
+	}{
+		{"timeout", func() { Timeout("/books", http.MethodGet) }, httpTimeouts.WithLabelValues("/books", http.MethodGet)},
+		{"unmatched timeout", func() { Timeout("", http.MethodPost) }, httpTimeouts.WithLabelValues(unmatchedRoute, http.MethodPost)},
+		{"rate limited", func() { RateLimited("/books", http.MethodGet, "anonymous") }, httpRateLimited.WithLabelValues("/books", http.MethodGet, "anonymous")},
+		{"mongo command", func() {
+			CommandMonitor().Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", Duration: time.Millisecond}})
+		}, mongoCommands.WithLabelValues("find", "failure")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(tt.counter)
+			tt.count()
+			if got := testutil.ToFloat64(tt.counter) - before; got != 1 {
+				t.Errorf("counted %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	Timeout("/books", http.MethodGet)
+	router := gin.New()
+	router.GET("/metrics", Handler())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{"myapp_http_request_timeouts_total{", "go_goroutines ", "process_open_fds "} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if problems, err := testutil.GatherAndLint(Registry); err != nil || len(problems) > 0 {
+		t.Errorf("lint: %v %+v", err, problems)
+	}
+}
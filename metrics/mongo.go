@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoCommands = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongodb_commands_total",
+			Help:      "Total number of MongoDB commands.",
+		},
+		[]string{"command", "outcome"},
+	)
+	mongoDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "mongodb_command_duration_seconds",
+			Help:      "Time taken by MongoDB commands.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"command", "outcome"},
+	)
+)
+
+// CommandMonitor records the count and duration of every MongoDB command,
+// by command name and whether it succeeded.
+func CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observeCommand(e.CommandName, "success", e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observeCommand(e.CommandName, "failure", e.Duration.Seconds())
+		},
+	}
+}
+
+func observeCommand(command, outcome string, seconds float64) {
+	mongoCommands.WithLabelValues(command, outcome).Inc()
+	mongoDuration.WithLabelValues(command, outcome).Observe(seconds)
+}
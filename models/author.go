@@ -1,9 +1,24 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Author struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	FirstName string             `json:"firstName" bson:"firstName"`
+	FirstName string             `json:"firstName" bson:"firstName" binding:"required"`
 	LastName  string             `json:"lastName" bson:"lastName"`
+	Version   int64              `json:"version" bson:"version"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// LastModified returns when the author was last changed.
+func (a Author) LastModified() time.Time {
+	if a.UpdatedAt.IsZero() {
+		return CreationTime(a.ID, a.CreatedAt)
+	}
+	return a.UpdatedAt
 }
@@ -1,9 +1,95 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Book struct {
-	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title    string             `json:"title" bson:"title"`
-	AuthorID primitive.ObjectID `json:"authorId" bson:"authorId"`
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Title        string             `json:"title" bson:"title" binding:"required"`
+	ISBN         string             `json:"isbn,omitempty" bson:"isbn,omitempty"`
+	AuthorID     primitive.ObjectID `json:"authorId" bson:"authorId"`
+	Contributors []Contributor      `json:"contributors,omitempty" bson:"contributors,omitempty"`
+	Publication  *Publication       `json:"publication,omitempty" bson:"publication,omitempty"`
+	Prices       []Price            `json:"prices,omitempty" bson:"prices,omitempty"`
+	Version      int64              `json:"version" bson:"version"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Contributor is a person other than the main author who worked on a book,
+// such as a co-author, editor or translator.
+type Contributor struct {
+	AuthorID primitive.ObjectID `json:"authorId,omitempty" bson:"authorId,omitempty"`
+	Name     string             `json:"name" bson:"name"`
+	Role     string             `json:"role,omitempty" bson:"role,omitempty"`
+}
+
+// Publication holds the imprint of a book.
+type Publication struct {
+	Place     string `json:"place,omitempty" bson:"place,omitempty"`
+	Publisher string `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	Date      string `json:"date,omitempty" bson:"date,omitempty"`
+}
+
+// Price is a price of a book as sent by its publisher. Amount is kept as the
+// decimal string it was received as.
+type Price struct {
+	Type     string `json:"type,omitempty" bson:"type,omitempty"`
+	Amount   string `json:"amount" bson:"amount"`
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty"`
+}
+
+// Timestamp returns the current time in UTC at the millisecond precision
+// MongoDB stores, so a document reads back exactly as it was written.
+func Timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// CreationTime returns when a document was created. Documents stored before
+// timestamps were introduced fall back to the time in their ObjectID.
+func CreationTime(id primitive.ObjectID, createdAt time.Time) time.Time {
+	if createdAt.IsZero() {
+		return id.Timestamp().UTC()
+	}
+	return createdAt
+}
+
+// LastModified returns when the book was last changed.
+func (b Book) LastModified() time.Time {
+	if b.UpdatedAt.IsZero() {
+		return CreationTime(b.ID, b.CreatedAt)
+	}
+	return b.UpdatedAt
+}
+
+// BookWithAuthor is a book joined with its author by AuthorLookup.
+type BookWithAuthor struct {
+	Book   `bson:",inline"`
+	Author *Author `json:"author,omitempty" bson:"authorInfo,omitempty"`
+}
+
+// AuthorLookup returns the aggregation stages that join every book with its
+// author from the authors collection, as "authorInfo". Books without an
+// author are kept.
+func AuthorLookup(authors string) []bson.M {
+	return []bson.M{
+		{
+			"$lookup": bson.M{
+				"from":         authors,
+				"localField":   "authorId",
+				"foreignField": "_id",
+				"as":           "authorInfo",
+			},
+		},
+		{
+			"$unwind": bson.M{
+				"path":                       "$authorInfo",
+				"preserveNullAndEmptyArrays": true,
+			},
+		},
+	}
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuthorLookup(t *testing.T) {
+	for _, authors := range []string{"author", "writers"} {
+		lookup, _ := AuthorLookup(authors)[0]["$lookup"].(bson.M)
+		if got := lookup["from"]; got != authors {
+			t.Errorf("with authors collection %q, $lookup reads from %v", authors, got)
+		}
+	}
+}
@@ -1,7 +1,16 @@
 package models
 
+// Roles of a user. Admins may use the /admin endpoints.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID       string `json:"id,omitempty" bson:"_id,omitempty"`
 	Username string `json:"username" bson:"username"`
 	Password string `json:"password" bson:"password"`
+	// Role is set by the server from config.Current.AdminUsers; users
+	// stored before roles were introduced have none and count as users.
+	Role string `json:"-" bson:"role,omitempty"`
 }
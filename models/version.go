@@ -0,0 +1,17 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Versioned matches the document with the given ID only while it still has
+// the given version, so that a write based on what was read fails when
+// somebody else wrote in between. Documents stored before versioning was
+// introduced have no version field and count as version 0.
+func Versioned(id primitive.ObjectID, version int64) bson.M {
+	if version == 0 {
+		return bson.M{"_id": id, "version": bson.M{"$in": bson.A{0, nil}}}
+	}
+	return bson.M{"_id": id, "version": version}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestVersioned(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name    string
+		version int64
+		want    bson.M
+	}{
+		{"unversioned document", 0, bson.M{"_id": id, "version": bson.M{"$in": bson.A{0, nil}}}},
+		{"first version", 1, bson.M{"_id": id, "version": int64(1)}},
+		{"later version", 42, bson.M{"_id": id, "version": int64(42)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Versioned(id, tt.version); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Versioned(%d) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package mongotest connects tests to the MongoDB server named by the
+// MONGODB_TEST_URI environment variable. Tests that need one are skipped
+// when it is not set.
+package mongotest
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+)
+
+// Connect returns a client of the test server and points
+// config.Current.Database.Name at a fresh database, which is dropped when
+// the test ends. Tests using it must not run in parallel.
+func Connect(t testing.TB) *mongo.Client {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", uri, err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("pinging %s: %v", uri, err)
+	}
+
+	saved := config.Current.Database.Name
+	config.Current.Database.Name = "test_" + primitive.NewObjectID().Hex()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client.Database(config.Current.Database.Name).Drop(ctx)
+		client.Disconnect(ctx)
+		config.Current.Database.Name = saved
+	})
+	return client
+}
+
+// Collection returns the collection of the test database with the given
+// name.
+func Collection(client *mongo.Client, name string) *mongo.Collection {
+	return client.Database(config.Current.Database.Name).Collection(name)
+}
+
+// RecordedEvents returns the events waiting in the outbox of the test
+// database, oldest first. Tests leave the relay stopped so that they stay
+// there.
+func RecordedEvents(t testing.TB, client *mongo.Client) []events.Event {
+	t.Helper()
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := Collection(client, config.Current.Database.Collections.Outbox).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []struct {
+		Event events.Event `bson:"event"`
+	}
+	if err := cursor.All(ctx, &entries); err != nil {
+		t.Fatal(err)
+	}
+	recorded := make([]events.Event, len(entries))
+	for i, entry := range entries {
+		recorded[i] = entry.Event
+	}
+	return recorded
+}
@@ -0,0 +1,243 @@
+package onix
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+// Notification says how a product record is applied to the catalog.
+type Notification int
+
+const (
+	// NotificationReplace replaces everything the catalog knows about the
+	// product (notification types 01, 02, 03, 08 and 09).
+	NotificationReplace Notification = iota
+	// NotificationBlockUpdate only replaces the blocks present in the record
+	// (notification type 04).
+	NotificationBlockUpdate
+	// NotificationDelete removes the product (notification type 05).
+	NotificationDelete
+	// NotificationIgnore marks test records (88, 89) and unknown types.
+	NotificationIgnore
+)
+
+func notification(code string) Notification {
+	switch trim(code) {
+	case "01", "02", "03", "08", "09":
+		return NotificationReplace
+	case "04":
+		return NotificationBlockUpdate
+	case "05":
+		return NotificationDelete
+	}
+	return NotificationIgnore
+}
+
+// Entry is a product mapped onto the catalog models. The Has* flags tell
+// which blocks the record carried; a block update must leave the others
+// untouched.
+type Entry struct {
+	RecordReference string
+	Notification    Notification
+	ISBN            string
+
+	HasDescriptive bool
+	Title          string
+	Author         *models.Author
+	Contributors   []models.Contributor
+
+	HasPublishing bool
+	Publication   *models.Publication
+
+	HasSupply bool
+	Prices    []models.Price
+}
+
+// ToEntry maps a product record.
+func ToEntry(p *Product) Entry {
+	entry := Entry{
+		RecordReference: trim(p.RecordReference),
+		Notification:    notification(p.NotificationType),
+		ISBN:            isbn(p.ProductIdentifiers),
+	}
+
+	if dd := p.DescriptiveDetail; dd != nil {
+		entry.HasDescriptive = true
+		entry.Title = title(dd.TitleDetails)
+		entry.Author, entry.Contributors = contributors(dd.Contributors)
+	}
+
+	if pd := p.PublishingDetail; pd != nil {
+		entry.HasPublishing = true
+		entry.Publication = publication(pd)
+	}
+
+	if len(p.ProductSupply) > 0 {
+		entry.HasSupply = true
+		for _, ps := range p.ProductSupply {
+			for _, sd := range ps.SupplyDetails {
+				for _, price := range sd.Prices {
+					if amount := trim(price.PriceAmount); amount != "" {
+						entry.Prices = append(entry.Prices, models.Price{
+							Type:     trim(price.PriceType),
+							Amount:   amount,
+							Currency: trim(price.CurrencyCode),
+						})
+					}
+				}
+			}
+		}
+	}
+	return entry
+}
+
+// isbn prefers the ISBN-13 (type 15), then a GTIN-13 in the ISBN ranges (03),
+// then an ISBN-10 (02).
+func isbn(ids []ProductIdentifier) string {
+	found := map[string]string{}
+	for _, id := range ids {
+		value := strings.ReplaceAll(trim(id.IDValue), "-", "")
+		found[trim(id.ProductIDType)] = value
+	}
+	if value := found["15"]; value != "" {
+		return value
+	}
+	if value := found["03"]; strings.HasPrefix(value, "978") || strings.HasPrefix(value, "979") {
+		return value
+	}
+	return found["02"]
+}
+
+// title returns the distinctive title (title type 01) at product level.
+func title(details []TitleDetail) string {
+	for _, detail := range details {
+		if trim(detail.TitleType) != "01" {
+			continue
+		}
+		for _, te := range detail.TitleElements {
+			if level := trim(te.TitleElementLevel); level != "" && level != "01" {
+				continue
+			}
+			text := trim(te.TitleText)
+			if text == "" {
+				text = strings.TrimSpace(trim(te.TitlePrefix) + " " + trim(te.TitleWithoutPrefix))
+			}
+			if subtitle := trim(te.Subtitle); subtitle != "" {
+				text += ": " + subtitle
+			}
+			return text
+		}
+	}
+	return ""
+}
+
+// contributorRoles names the ONIX list 17 codes the catalog shows. Other
+// codes are kept as they are.
+var contributorRoles = map[string]string{
+	"A01": "author",
+	"A12": "illustrator",
+	"A13": "photographer",
+	"A15": "preface by",
+	"A19": "afterword by",
+	"A23": "introduction by",
+	"B01": "editor",
+	"B06": "translator",
+	"E07": "reader",
+}
+
+// contributors returns the first author (role A01) as the main author and
+// everybody else as contributors, in sequence order.
+func contributors(list []Contributor) (*models.Author, []models.Contributor) {
+	sorted := append([]Contributor(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SequenceNumber < sorted[j].SequenceNumber
+	})
+
+	var (
+		author *models.Author
+		others []models.Contributor
+	)
+	for _, c := range sorted {
+		first, last := personName(c)
+		if first == "" && last == "" {
+			continue
+		}
+
+		role := ""
+		if len(c.ContributorRoles) > 0 {
+			role = trim(c.ContributorRoles[0])
+		}
+		if author == nil && role == "A01" {
+			author = &models.Author{FirstName: first, LastName: last}
+			continue
+		}
+
+		name := first
+		if last != "" {
+			name = last + ", " + first
+		}
+		if label, ok := contributorRoles[role]; ok {
+			role = label
+		}
+		others = append(others, models.Contributor{Name: strings.TrimSuffix(name, ", "), Role: role})
+	}
+	return author, others
+}
+
+// personName returns the forename(s) and key name(s) of a contributor. A
+// corporate contributor is returned as a single name.
+func personName(c Contributor) (first, last string) {
+	switch {
+	case trim(c.KeyNames) != "":
+		return trim(c.NamesBeforeKey), trim(c.KeyNames)
+	case trim(c.PersonNameInverted) != "":
+		name := trim(c.PersonNameInverted)
+		if i := strings.Index(name, ","); i >= 0 {
+			return strings.TrimSpace(name[i+1:]), strings.TrimSpace(name[:i])
+		}
+		return name, ""
+	case trim(c.PersonName) != "":
+		name := trim(c.PersonName)
+		if i := strings.LastIndex(name, " "); i >= 0 {
+			return name[:i], name[i+1:]
+		}
+		return name, ""
+	}
+	return trim(c.CorporateName), ""
+}
+
+// publication reads the publisher (role 01), city and publication date
+// (role 01) of a product.
+func publication(pd *PublishingDetail) *models.Publication {
+	pub := &models.Publication{}
+	for _, publisher := range pd.Publishers {
+		if role := trim(publisher.PublishingRole); role == "01" || role == "" {
+			pub.Publisher = trim(publisher.PublisherName)
+			break
+		}
+	}
+	if len(pd.CityOfPublication) > 0 {
+		pub.Place = trim(pd.CityOfPublication[0])
+	}
+	for _, date := range pd.PublishingDates {
+		if trim(date.PublishingDateRole) == "01" {
+			pub.Date = formatDate(trim(date.Date))
+			break
+		}
+	}
+	if *pub == (models.Publication{}) {
+		return nil
+	}
+	return pub
+}
+
+// formatDate turns the default ONIX YYYYMMDD format into YYYY-MM-DD. Other
+// formats are returned unchanged.
+func formatDate(date string) string {
+	if len(date) == 8 && strings.Trim(date, "0123456789") == "" {
+		return date[:4] + "-" + date[4:6] + "-" + date[6:]
+	}
+	return date
+}
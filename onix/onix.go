@@ -0,0 +1,192 @@
+package onix
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Product is the part of an ONIX 3.0 <Product> record the catalog maps.
+// Every level keeps its unknown children in Other so that they can be
+// reported as unmapped.
+type Product struct {
+	RecordReference    string              `xml:"RecordReference"`
+	NotificationType   string              `xml:"NotificationType"`
+	ProductIdentifiers []ProductIdentifier `xml:"ProductIdentifier"`
+	DescriptiveDetail  *DescriptiveDetail  `xml:"DescriptiveDetail"`
+	PublishingDetail   *PublishingDetail   `xml:"PublishingDetail"`
+	ProductSupply      []ProductSupply     `xml:"ProductSupply"`
+	Other              []element           `xml:",any"`
+}
+
+type ProductIdentifier struct {
+	ProductIDType string    `xml:"ProductIDType"`
+	IDValue       string    `xml:"IDValue"`
+	Other         []element `xml:",any"`
+}
+
+type DescriptiveDetail struct {
+	TitleDetails []TitleDetail `xml:"TitleDetail"`
+	Contributors []Contributor `xml:"Contributor"`
+	Other        []element     `xml:",any"`
+}
+
+type TitleDetail struct {
+	TitleType     string         `xml:"TitleType"`
+	TitleElements []TitleElement `xml:"TitleElement"`
+	Other         []element      `xml:",any"`
+}
+
+type TitleElement struct {
+	TitleElementLevel  string    `xml:"TitleElementLevel"`
+	TitleText          string    `xml:"TitleText"`
+	TitlePrefix        string    `xml:"TitlePrefix"`
+	TitleWithoutPrefix string    `xml:"TitleWithoutPrefix"`
+	Subtitle           string    `xml:"Subtitle"`
+	Other              []element `xml:",any"`
+}
+
+type Contributor struct {
+	SequenceNumber     int       `xml:"SequenceNumber"`
+	ContributorRoles   []string  `xml:"ContributorRole"`
+	PersonName         string    `xml:"PersonName"`
+	PersonNameInverted string    `xml:"PersonNameInverted"`
+	NamesBeforeKey     string    `xml:"NamesBeforeKey"`
+	KeyNames           string    `xml:"KeyNames"`
+	CorporateName      string    `xml:"CorporateName"`
+	Other              []element `xml:",any"`
+}
+
+type PublishingDetail struct {
+	Publishers        []Publisher      `xml:"Publisher"`
+	CityOfPublication []string         `xml:"CityOfPublication"`
+	PublishingDates   []PublishingDate `xml:"PublishingDate"`
+	Other             []element        `xml:",any"`
+}
+
+type Publisher struct {
+	PublishingRole string    `xml:"PublishingRole"`
+	PublisherName  string    `xml:"PublisherName"`
+	Other          []element `xml:",any"`
+}
+
+type PublishingDate struct {
+	PublishingDateRole string    `xml:"PublishingDateRole"`
+	Date               string    `xml:"Date"`
+	Other              []element `xml:",any"`
+}
+
+type ProductSupply struct {
+	SupplyDetails []SupplyDetail `xml:"SupplyDetail"`
+	Other         []element      `xml:",any"`
+}
+
+type SupplyDetail struct {
+	Prices []Price   `xml:"Price"`
+	Other  []element `xml:",any"`
+}
+
+type Price struct {
+	PriceType    string    `xml:"PriceType"`
+	PriceAmount  string    `xml:"PriceAmount"`
+	CurrencyCode string    `xml:"CurrencyCode"`
+	Other        []element `xml:",any"`
+}
+
+// element captures an unmapped child. Only its name is kept; its content is
+// skipped by the decoder.
+type element struct {
+	XMLName xml.Name
+}
+
+// Reader reads the products of an ONIX 3.0 message one at a time, so memory
+// use does not depend on the size of the message. Messages may use either
+// the reference names or the short tags.
+type Reader struct {
+	d        *xml.Decoder
+	unmapped map[string]int
+}
+
+func NewReader(r io.Reader) *Reader {
+	d := xml.NewDecoder(r)
+	// ONIX feeds are UTF-8 in practice; other declared charsets are read as is.
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return &Reader{d: xml.NewTokenDecoder(shortTags{d}), unmapped: make(map[string]int)}
+}
+
+// Next returns the next product, or io.EOF at the end of the message.
+func (r *Reader) Next() (*Product, error) {
+	for {
+		token, err := r.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Product" {
+			continue
+		}
+
+		var product Product
+		if err := r.d.DecodeElement(&product, &start); err != nil {
+			return nil, err
+		}
+		r.collectUnmapped(&product)
+		return &product, nil
+	}
+}
+
+// Unmapped returns how often each unmapped element was seen, keyed by its
+// path below Product.
+func (r *Reader) Unmapped() map[string]int {
+	return r.unmapped
+}
+
+func (r *Reader) note(parent string, others []element) {
+	for _, other := range others {
+		r.unmapped[parent+"/"+other.XMLName.Local]++
+	}
+}
+
+func (r *Reader) collectUnmapped(p *Product) {
+	r.note("Product", p.Other)
+	for _, id := range p.ProductIdentifiers {
+		r.note("Product/ProductIdentifier", id.Other)
+	}
+	if dd := p.DescriptiveDetail; dd != nil {
+		r.note("Product/DescriptiveDetail", dd.Other)
+		for _, td := range dd.TitleDetails {
+			r.note("Product/DescriptiveDetail/TitleDetail", td.Other)
+			for _, te := range td.TitleElements {
+				r.note("Product/DescriptiveDetail/TitleDetail/TitleElement", te.Other)
+			}
+		}
+		for _, c := range dd.Contributors {
+			r.note("Product/DescriptiveDetail/Contributor", c.Other)
+		}
+	}
+	if pd := p.PublishingDetail; pd != nil {
+		r.note("Product/PublishingDetail", pd.Other)
+		for _, pub := range pd.Publishers {
+			r.note("Product/PublishingDetail/Publisher", pub.Other)
+		}
+		for _, date := range pd.PublishingDates {
+			r.note("Product/PublishingDetail/PublishingDate", date.Other)
+		}
+	}
+	for _, ps := range p.ProductSupply {
+		r.note("Product/ProductSupply", ps.Other)
+		for _, sd := range ps.SupplyDetails {
+			r.note("Product/ProductSupply/SupplyDetail", sd.Other)
+			for _, price := range sd.Prices {
+				r.note("Product/ProductSupply/SupplyDetail/Price", price.Other)
+			}
+		}
+	}
+}
+
+// trim collapses the whitespace of an XML text value.
+func trim(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
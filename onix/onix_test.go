@@ -0,0 +1,153 @@
+package onix
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saifujnu/books-authors/models"
+)
+
+const referenceMessage = `<?xml version="1.0" encoding="UTF-8"?>
+<ONIXMessage release="3.0" xmlns="http://ns.editeur.org/onix/3.0/reference">
+  <Header><Sender><SenderName>Example</SenderName></Sender></Header>
+  <Product>
+    <RecordReference>com.example.1</RecordReference>
+    <NotificationType>03</NotificationType>
+    <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>978-0-00-000001-1</IDValue></ProductIdentifier>
+    <DescriptiveDetail>
+      <ProductComposition>00</ProductComposition>
+      <TitleDetail>
+        <TitleType>01</TitleType>
+        <TitleElement>
+          <TitleElementLevel>01</TitleElementLevel>
+          <TitlePrefix>The</TitlePrefix><TitleWithoutPrefix>Book</TitleWithoutPrefix>
+          <Subtitle>A Novel</Subtitle>
+        </TitleElement>
+      </TitleDetail>
+      <Contributor>
+        <SequenceNumber>2</SequenceNumber><ContributorRole>B06</ContributorRole>
+        <NamesBeforeKey>Tom</NamesBeforeKey><KeyNames>Translator</KeyNames>
+      </Contributor>
+      <Contributor>
+        <SequenceNumber>1</SequenceNumber><ContributorRole>A01</ContributorRole>
+        <PersonNameInverted>Writer, Ann</PersonNameInverted>
+      </Contributor>
+    </DescriptiveDetail>
+    <PublishingDetail>
+      <Publisher><PublishingRole>01</PublishingRole><PublisherName>Press</PublisherName></Publisher>
+      <CityOfPublication>London</CityOfPublication>
+      <PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>20200131</Date></PublishingDate>
+    </PublishingDetail>
+    <ProductSupply>
+      <SupplyDetail>
+        <Price><PriceType>02</PriceType><PriceAmount>9.99</PriceAmount><CurrencyCode>GBP</CurrencyCode></Price>
+      </SupplyDetail>
+    </ProductSupply>
+  </Product>
+  <Product>
+    <RecordReference>com.example.2</RecordReference>
+    <NotificationType>05</NotificationType>
+    <ProductIdentifier><ProductIDType>02</ProductIDType><IDValue>0000000022</IDValue></ProductIdentifier>
+  </Product>
+</ONIXMessage>`
+
+const shortMessage = `<?xml version="1.0" encoding="UTF-8"?>
+<ONIXmessage release="3.0" xmlns="http://ns.editeur.org/onix/3.0/short">
+  <header><sender><x298>Example</x298></sender></header>
+  <product>
+    <a001>com.example.1</a001>
+    <a002>03</a002>
+    <productidentifier><b221>15</b221><b244>978-0-00-000001-1</b244></productidentifier>
+    <descriptivedetail>
+      <x314>00</x314>
+      <titledetail>
+        <b202>01</b202>
+        <titleelement>
+          <x409>01</x409>
+          <b030>The</b030><b031>Book</b031>
+          <b029>A Novel</b029>
+        </titleelement>
+      </titledetail>
+      <contributor>
+        <b034>2</b034><b035>B06</b035>
+        <b039>Tom</b039><b040>Translator</b040>
+      </contributor>
+      <contributor>
+        <b034>1</b034><b035>A01</b035>
+        <b037>Writer, Ann</b037>
+      </contributor>
+    </descriptivedetail>
+    <publishingdetail>
+      <publisher><b291>01</b291><b081>Press</b081></publisher>
+      <b209>London</b209>
+      <publishingdate><x448>01</x448><b306>20200131</b306></publishingdate>
+    </publishingdetail>
+    <productsupply>
+      <supplydetail>
+        <price><x462>02</x462><j151>9.99</j151><j152>GBP</j152></price>
+      </supplydetail>
+    </productsupply>
+  </product>
+  <product>
+    <a001>com.example.2</a001>
+    <a002>05</a002>
+    <productidentifier><b221>02</b221><b244>0000000022</b244></productidentifier>
+  </product>
+</ONIXmessage>`
+
+func TestReader(t *testing.T) {
+	want := []Entry{
+		{
+			RecordReference: "com.example.1",
+			Notification:    NotificationReplace,
+			ISBN:            "9780000000011",
+			HasDescriptive:  true,
+			Title:           "The Book: A Novel",
+			Author:          &models.Author{FirstName: "Ann", LastName: "Writer"},
+			Contributors:    []models.Contributor{{Name: "Translator, Tom", Role: "translator"}},
+			HasPublishing:   true,
+			Publication:     &models.Publication{Publisher: "Press", Place: "London", Date: "2020-01-31"},
+			HasSupply:       true,
+			Prices:          []models.Price{{Type: "02", Amount: "9.99", Currency: "GBP"}},
+		},
+		{
+			RecordReference: "com.example.2",
+			Notification:    NotificationDelete,
+			ISBN:            "0000000022",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		message  string
+		unmapped map[string]int
+	}{
+		{"reference names", referenceMessage, map[string]int{"Product/DescriptiveDetail/ProductComposition": 1}},
+		{"short tags", shortMessage, map[string]int{"Product/DescriptiveDetail/x314": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(tt.message))
+			var got []Entry
+			for {
+				product, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next: %v", err)
+				}
+				got = append(got, ToEntry(product))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("entries = %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(reader.Unmapped(), tt.unmapped) {
+				t.Errorf("Unmapped = %v, want %v", reader.Unmapped(), tt.unmapped)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package onix
+
+import "encoding/xml"
+
+// referenceNames maps the ONIX 3.0 short tags of the elements the catalog
+// maps to their reference names. Short-tag messages (<ONIXmessage>,
+// <product>, <a001>...) are read as if they used the reference names;
+// unmapped elements keep their short tags.
+var referenceNames = map[string]string{
+	"ONIXmessage":       "ONIXMessage",
+	"product":           "Product",
+	"a001":              "RecordReference",
+	"a002":              "NotificationType",
+	"productidentifier": "ProductIdentifier",
+	"b221":              "ProductIDType",
+	"b244":              "IDValue",
+	"descriptivedetail": "DescriptiveDetail",
+	"titledetail":       "TitleDetail",
+	"b202":              "TitleType",
+	"titleelement":      "TitleElement",
+	"x409":              "TitleElementLevel",
+	"b203":              "TitleText",
+	"b030":              "TitlePrefix",
+	"b031":              "TitleWithoutPrefix",
+	"b029":              "Subtitle",
+	"contributor":       "Contributor",
+	"b034":              "SequenceNumber",
+	"b035":              "ContributorRole",
+	"b036":              "PersonName",
+	"b037":              "PersonNameInverted",
+	"b039":              "NamesBeforeKey",
+	"b040":              "KeyNames",
+	"b047":              "CorporateName",
+	"publishingdetail":  "PublishingDetail",
+	"publisher":         "Publisher",
+	"b291":              "PublishingRole",
+	"b081":              "PublisherName",
+	"b209":              "CityOfPublication",
+	"publishingdate":    "PublishingDate",
+	"x448":              "PublishingDateRole",
+	"b306":              "Date",
+	"productsupply":     "ProductSupply",
+	"supplydetail":      "SupplyDetail",
+	"price":             "Price",
+	"x462":              "PriceType",
+	"j151":              "PriceAmount",
+	"j152":              "CurrencyCode",
+}
+
+// shortTags renames the short-tag elements of the tokens it reads. Reference
+// names are CamelCase and short tags are not, so reference-name messages go
+// through unchanged.
+type shortTags struct {
+	r xml.TokenReader
+}
+
+func (s shortTags) Token() (xml.Token, error) {
+	token, err := s.r.Token()
+	switch t := token.(type) {
+	case xml.StartElement:
+		if name, ok := referenceNames[t.Name.Local]; ok {
+			t.Name.Local = name
+			token = t
+		}
+	case xml.EndElement:
+		if name, ok := referenceNames[t.Name.Local]; ok {
+			t.Name.Local = name
+			token = t
+		}
+	}
+	return token, err
+}
@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedocVersion is the version of the embedded Redoc script, taken from
+// github.com/mvrilo/go-redoc v0.1.4. The page loads it from the server, so
+// the reference works offline and cannot change under it.
+const RedocVersion = "2.0.0-rc.59"
+
+var (
+	//go:embed redoc.html
+	redocPage []byte
+	//go:embed redoc.standalone.js
+	redocScript []byte
+)
+
+// Drift lists the differences between the registered routes and the
+// operations table, as "METHOD /path".
+type Drift struct {
+	// Undocumented routes are in the document with a stub operation only.
+	Undocumented []string
+	// Stale operations are documented but not registered, and left out.
+	Stale []string
+}
+
+// Empty reports whether routes and documentation agree.
+func (d Drift) Empty() bool {
+	return len(d.Undocumented) == 0 && len(d.Stale) == 0
+}
+
+// Docs serves the OpenAPI document of the routes it was generated from and
+// a Redoc page rendering it.
+type Docs struct {
+	doc  *Document
+	body []byte
+}
+
+// Generate builds the document from the routes registered on the router.
+// It must be called after all routes are registered and before the server
+// starts.
+func (d *Docs) Generate(routes gin.RoutesInfo) (Drift, error) {
+	documented := make(map[string]Operation)
+	for _, entry := range operations() {
+		documented[entry.method+" "+entry.path] = entry.op
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "Books and Authors API",
+			Version:     "1.0.0",
+			Description: "Catalog of books and their authors.",
+		},
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Schemas: modelSchemas(),
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	var drift Drift
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		op, ok := documented[key]
+		if ok {
+			delete(documented, key)
+		} else {
+			drift.Undocumented = append(drift.Undocumented, key)
+			op = Operation{
+				Summary:   "Undocumented",
+				Security:  public,
+				Responses: map[string]Response{"default": {Description: "Undocumented"}},
+			}
+			for _, name := range pathParams(route.Path) {
+				op.Parameters = append(op.Parameters, pathParam(name, ""))
+			}
+		}
+		op.OperationID = operationID(route.Method, route.Path)
+
+		path := specPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		operation := op
+		(*item)[strings.ToLower(route.Method)] = &operation
+	}
+	for key := range documented {
+		drift.Stale = append(drift.Stale, key)
+	}
+	sort.Strings(drift.Undocumented)
+	sort.Strings(drift.Stale)
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return drift, err
+	}
+	d.doc, d.body = doc, body
+	return drift, nil
+}
+
+// Spec serves the OpenAPI document.
+func (d *Docs) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", d.body)
+}
+
+// UI serves the Redoc page.
+func (d *Docs) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", redocPage)
+}
+
+// Script serves the Redoc script the page loads.
+func (d *Docs) Script(c *gin.Context) {
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", redocScript)
+}
+
+// specPath converts a gin path to an OpenAPI path template.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams returns the names of the parameters of a gin path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+// operationID derives a stable operation ID such as "getBooksId" from the
+// method and path.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
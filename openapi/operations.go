@@ -0,0 +1,610 @@
+package openapi
+
+import (
+	"github.com/saifujnu/books-authors/importer"
+	"github.com/saifujnu/books-authors/jobs"
+	"github.com/saifujnu/books-authors/models"
+	"github.com/saifujnu/books-authors/webhooks"
+)
+
+// Media types used by the API.
+const (
+	jsonType       = "application/json"
+	jsonLDType     = "application/ld+json"
+	rdfXMLType     = "application/rdf+xml"
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+	atomType       = "application/atom+xml"
+	opdsAtomType   = "application/atom+xml;profile=opds-catalog"
+	opds2Type      = "application/opds+json"
+	rssType        = "application/rss+xml"
+	csvType        = "text/csv"
+	htmlType       = "text/html"
+	textType       = "text/plain"
+	javascriptType = "text/javascript"
+)
+
+// operation documents one route, keyed by method and gin path in the
+// operations table.
+type operation struct {
+	method, path string
+	op           Operation
+}
+
+// bearer marks an operation as requiring a JWT from /auth/login.
+var bearer = []map[string][]string{{"bearerAuth": {}}}
+
+// public marks an operation as not requiring authentication.
+var public = []map[string][]string{}
+
+// modelSchemas returns the component schemas: those derived from the Go
+// types and those of request and response shapes that have no exported type.
+func modelSchemas() map[string]*Schema {
+	schemas := map[string]*Schema{}
+	for _, v := range []interface{}{
+		models.Book{},
+		models.Author{},
+		models.BookWithAuthor{},
+		models.User{},
+		jobs.Job{},
+		importer.Report{},
+		webhooks.Subscription{},
+		webhooks.Delivery{},
+	} {
+		schemaOf(v, schemas)
+	}
+
+	one, max := 1, 1000
+	schemas["Error"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":      {Type: "string"},
+			"request_id": {Type: "string", Description: "ID of the request, as in the X-Request-ID response header."},
+		},
+		Required: []string{"error", "request_id"},
+	}
+	schemas["BulkOperation"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"op":      {Type: "string", Enum: []string{"create", "update", "delete"}},
+			"id":      ObjectID(),
+			"version": {Type: "integer", Description: "Version the client has seen; the operation fails with 412 if it changed."},
+			"data":    {Type: "object", Description: "The full document for create and update."},
+		},
+		Required: []string{"op"},
+	}
+	schemas["BulkRequest"] = &Schema{Type: "array", Items: Ref("BulkOperation"), MinItems: &one, MaxItems: &max}
+	schemas["BulkResponse"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"succeeded": {Type: "integer"},
+			"failed":    {Type: "integer"},
+			"results": ArrayOf(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"index":  {Type: "integer"},
+					"op":     {Type: "string"},
+					"id":     ObjectID(),
+					"status": {Type: "integer", Description: "HTTP status of the single operation."},
+					"error":  {Type: "string"},
+				},
+			}),
+		},
+	}
+	schemas["JSONPatch"] = ArrayOf(&Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+			"path":  {Type: "string"},
+			"from":  {Type: "string"},
+			"value": {},
+		},
+		Required: []string{"op", "path"},
+	})
+	return schemas
+}
+
+// Helpers to keep the operations table readable.
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{jsonType: {Schema: schema}}
+}
+
+func textContent(types ...string) map[string]MediaType {
+	content := make(map[string]MediaType, len(types))
+	for _, t := range types {
+		content[t] = MediaType{Schema: &Schema{Type: "string"}}
+	}
+	return content
+}
+
+func ok(description string, schema *Schema) Response {
+	return Response{Description: description, Content: jsonContent(schema)}
+}
+
+func failure(description string) Response {
+	return Response{Description: description, Content: jsonContent(Ref("Error"))}
+}
+
+func pathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+func idParam(kind string) Parameter {
+	return Parameter{Name: "id", In: "path", Description: kind + " ID", Required: true, Schema: ObjectID()}
+}
+
+func queryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+var (
+	ifMatch     = Parameter{Name: "If-Match", In: "header", Description: "ETag of the version the client has seen.", Schema: &Schema{Type: "string"}}
+	ifNoneMatch = Parameter{Name: "If-None-Match", In: "header", Description: "ETag of a cached representation.", Schema: &Schema{Type: "string"}}
+	pageParam   = queryParam("page", "Page number, starting at 1.", &Schema{Type: "integer"})
+	etagHeader  = map[string]Header{"ETag": {Schema: &Schema{Type: "string"}}}
+)
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+// crud returns the operations of the /books and /authors groups.
+func crud(prefix, tag, kind string) []operation {
+	entity := Ref(kind)
+	return []operation{
+		{"GET", prefix + "/", Operation{
+			Summary: "List " + tag, Tags: []string{tag}, Security: bearer,
+			Responses: map[string]Response{
+				"200": ok("All "+tag, ArrayOf(entity)),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		{"GET", prefix + "/:id", Operation{
+			Summary:     "Get a " + kind,
+			Description: "Returns schema.org JSON-LD or Dublin Core RDF/XML when asked for in the Accept header.",
+			Tags:        []string{tag}, Security: bearer,
+			Parameters: []Parameter{idParam(kind), ifNoneMatch},
+			Responses: map[string]Response{
+				"200": {Description: "The " + kind, Headers: etagHeader, Content: map[string]MediaType{
+					jsonType:   {Schema: entity},
+					jsonLDType: {Schema: &Schema{Type: "object"}},
+					rdfXMLType: {Schema: &Schema{Type: "string"}},
+				}},
+				"304": {Description: "Not modified"},
+				"400": failure("Invalid ID"),
+				"404": failure(kind + " not found"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		{"POST", prefix + "/", Operation{
+			Summary: "Create a " + kind, Tags: []string{tag}, Security: bearer,
+			RequestBody: jsonBody(entity),
+			Responses: map[string]Response{
+				"201": {Description: kind + " created", Headers: etagHeader, Content: jsonContent(&Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"message": {Type: "string"}, lowerFirst(kind): entity},
+				})},
+				"400": failure("Invalid body"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		{"POST", prefix + "/bulk", Operation{
+			Summary:     "Create, update and delete " + tag + " in one request",
+			Description: "Operations run independently unless atomic=true, in which case they succeed or fail together.",
+			Tags:        []string{tag}, Security: bearer,
+			Parameters:  []Parameter{queryParam("atomic", "Run all operations in one transaction.", &Schema{Type: "boolean"})},
+			RequestBody: jsonBody(Ref("BulkRequest")),
+			Responses: map[string]Response{
+				"200": ok("Per operation results", Ref("BulkResponse")),
+				"400": failure("Malformed request"),
+				"409": ok("Atomic batch rolled back", Ref("BulkResponse")),
+				"422": ok("Atomic batch rejected", Ref("BulkResponse")),
+				"501": failure("atomic=true against a server without transactions"),
+			},
+		}},
+		{"PUT", prefix + "/:id", Operation{
+			Summary: "Replace a " + kind, Tags: []string{tag}, Security: bearer,
+			Parameters:  []Parameter{idParam(kind), ifMatch},
+			RequestBody: jsonBody(entity),
+			Responses: map[string]Response{
+				"200": {Description: "The updated " + kind, Headers: etagHeader, Content: jsonContent(entity)},
+				"400": failure("Invalid ID or body"),
+				"404": failure(kind + " not found"),
+				"412": failure(kind + " has been modified"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		{"PATCH", prefix + "/:id", Operation{
+			Summary: "Patch a " + kind, Tags: []string{tag}, Security: bearer,
+			Parameters: []Parameter{idParam(kind), ifMatch},
+			RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{
+				mergePatchType: {Schema: &Schema{Type: "object"}},
+				jsonPatchType:  {Schema: Ref("JSONPatch")},
+			}},
+			Responses: map[string]Response{
+				"200": {Description: "The patched " + kind, Headers: etagHeader, Content: jsonContent(entity)},
+				"400": failure("Invalid ID or malformed patch"),
+				"404": failure(kind + " not found"),
+				"409": failure("Patch cannot be applied"),
+				"412": failure(kind + " has been modified"),
+				"415": failure("Unsupported patch format"),
+				"422": failure("Patched document is invalid"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		{"DELETE", prefix + "/:id", Operation{
+			Summary: "Delete a " + kind, Tags: []string{tag}, Security: bearer,
+			Parameters: []Parameter{idParam(kind), ifMatch},
+			Responses: map[string]Response{
+				"204": {Description: kind + " deleted"},
+				"400": failure("Invalid ID"),
+				"404": failure(kind + " not found"),
+				"412": failure(kind + " has been modified"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+	}
+}
+
+// opds returns the operations of one OPDS version below prefix.
+func opds(prefix, mediaType string) []operation {
+	feed := func(summary string, params ...Parameter) Operation {
+		return Operation{
+			Summary: summary, Tags: []string{"catalog"}, Security: public, Parameters: params,
+			Responses: map[string]Response{
+				"200": {Description: "Catalog feed", Content: textContent(mediaType)},
+				"400": failure("Invalid parameter"),
+			},
+		}
+	}
+	return []operation{
+		{"GET", prefix, feed("Catalog root")},
+		{"GET", prefix + "/books", feed("All books", pageParam)},
+		{"GET", prefix + "/authors", feed("All authors", pageParam)},
+		{"GET", prefix + "/authors/:id", feed("Books of an author", idParam("Author"), pageParam)},
+		{"GET", prefix + "/search", feed("Search books by title", queryParam("q", "Search terms.", &Schema{Type: "string"}), pageParam)},
+	}
+}
+
+func syndication(path, summary, mediaType string, params ...Parameter) operation {
+	return operation{"GET", path, Operation{
+		Summary: summary, Tags: []string{"feeds"}, Security: public,
+		Parameters: append(params, ifNoneMatch, Parameter{Name: "If-Modified-Since", In: "header", Schema: &Schema{Type: "string"}}),
+		Responses: map[string]Response{
+			"200": {Description: "Feed", Content: textContent(mediaType)},
+			"304": {Description: "Not modified"},
+		},
+	}}
+}
+
+// operations documents every route the server registers.
+func operations() []operation {
+	var table []operation
+	table = append(table,
+		operation{"POST", "/auth/signup", Operation{
+			Summary: "Register a user", Tags: []string{"auth"}, Security: public,
+			RequestBody: jsonBody(Ref("User")),
+			Responses: map[string]Response{
+				"201": {Description: "User created"},
+				"400": failure("Invalid body"),
+				"429": failure("Too many signups from this address"),
+			},
+		}},
+		operation{"POST", "/auth/login", Operation{
+			Summary: "Log in and get a JWT", Tags: []string{"auth"}, Security: public,
+			RequestBody: jsonBody(Ref("User")),
+			Responses: map[string]Response{
+				"200": ok("Token for the Authorization header", &Schema{
+					Type: "object", Properties: map[string]*Schema{"token": {Type: "string"}},
+				}),
+				"400": failure("Invalid body"),
+				"401": failure("Invalid credentials"),
+				"429": failure("Too many attempts from this address"),
+			},
+		}},
+	)
+
+	table = append(table, crud("/books", "books", "Book")...)
+	table = append(table,
+		operation{"GET", "/books/books-and-authors", Operation{
+			Summary: "List book titles with their authors", Tags: []string{"books"}, Security: bearer,
+			Responses: map[string]Response{
+				"200": ok("Books with authors", ArrayOf(&Schema{Type: "object"})),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		operation{"GET", "/books/books-by-author/:authorName", Operation{
+			Summary: "List the books of an author by first name", Tags: []string{"books"}, Security: bearer,
+			Parameters: []Parameter{pathParam("authorName", "First name of the author.")},
+			Responses: map[string]Response{
+				"200": ok("Books of the author", ArrayOf(Ref("Book"))),
+				"404": failure("Author not found"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+	)
+	table = append(table, crud("/authors", "authors", "Author")...)
+
+	table = append(table, opds("/opds", opdsAtomType)...)
+	table = append(table, opds("/opds/v2", opds2Type)...)
+	table = append(table, operation{"GET", "/opds/opensearch.xml", Operation{
+		Summary: "OpenSearch description of the catalog search", Tags: []string{"catalog"}, Security: public,
+		Responses: map[string]Response{
+			"200": {Description: "OpenSearch description", Content: textContent("application/opensearchdescription+xml")},
+		},
+	}})
+
+	table = append(table,
+		syndication("/feeds/books.atom", "New and updated books", atomType),
+		syndication("/feeds/books.rss", "New and updated books", rssType),
+		syndication("/feeds/authors/:id/books.atom", "New and updated books of an author", atomType, idParam("Author")),
+		syndication("/feeds/authors/:id/books.rss", "New and updated books of an author", rssType, idParam("Author")),
+	)
+
+	importParams := []Parameter{
+		queryParam("format", "csv, ndjson, marc or marcxml; guessed from the file name or content type when missing.", &Schema{Type: "string"}),
+		queryParam("dry_run", "Validate and report without writing.", &Schema{Type: "boolean"}),
+	}
+	upload := &RequestBody{Required: true, Content: map[string]MediaType{
+		"multipart/form-data":      {Schema: &Schema{Type: "object", Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}}}},
+		"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}},
+	}}
+	table = append(table,
+		operation{"GET", "/export", Operation{
+			Summary: "Export books", Tags: []string{"import-export"}, Security: bearer,
+			Parameters: []Parameter{
+				queryParam("format", "ndjson (default), csv, bibtex, ris, marc or marcxml.", &Schema{Type: "string"}),
+				queryParam("authorId", "Only books of this author.", ObjectID()),
+				queryParam("title", "Only books whose title contains this.", &Schema{Type: "string"}),
+				queryParam("isbn", "Only the book with this ISBN.", &Schema{Type: "string"}),
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Streamed export", Content: textContent("application/x-ndjson", csvType, "application/x-bibtex", "application/x-research-info-systems", "application/marc", "application/marcxml+xml")},
+				"400": failure("Unknown format or invalid filter"),
+			},
+		}},
+		operation{"POST", "/import", Operation{
+			Summary:     "Import books",
+			Description: "Dry runs are answered directly; other imports run as a background job.",
+			Tags:        []string{"import-export"}, Security: bearer,
+			Parameters: importParams, RequestBody: upload,
+			Responses: map[string]Response{
+				"200": ok("Dry run report", Ref("Report")),
+				"202": {Description: "Import job started", Headers: map[string]Header{"Location": {Schema: &Schema{Type: "string"}}}, Content: jsonContent(Ref("Job"))},
+				"400": failure("Invalid upload"),
+			},
+		}},
+		operation{"POST", "/import/onix", Operation{
+			Summary: "Import an ONIX for Books 3.0 message", Tags: []string{"import-export"}, Security: bearer,
+			Description: "The message may use either the reference names or the short tags.",
+			Parameters:  importParams[1:],
+			RequestBody: upload,
+			Responses: map[string]Response{
+				"202": {Description: "Import job started", Headers: map[string]Header{"Location": {Schema: &Schema{Type: "string"}}}, Content: jsonContent(Ref("Job"))},
+				"400": failure("Invalid upload"),
+			},
+		}},
+		operation{"GET", "/import/:id", Operation{
+			Summary: "Get an import job", Tags: []string{"import-export"}, Security: bearer,
+			Parameters: []Parameter{pathParam("id", "Job ID.")},
+			Responses: map[string]Response{
+				"200": ok("The job; its result is the import report", Ref("Job")),
+				"404": failure("Import job not found, or finished longer ago than jobs.ttl"),
+			},
+		}},
+		operation{"GET", "/import/:id/errors", Operation{
+			Summary: "Download the rows an import rejected", Tags: []string{"import-export"}, Security: bearer,
+			Parameters: []Parameter{pathParam("id", "Job ID.")},
+			Responses: map[string]Response{
+				"200": {Description: "Rejected rows", Content: textContent(csvType)},
+				"404": failure("Import job not found, or finished longer ago than jobs.ttl"),
+				"409": failure("Import job has no report yet"),
+			},
+		}},
+	)
+
+	webhookID := idParam("Webhook")
+	table = append(table,
+		operation{"POST", "/webhooks", Operation{
+			Summary: "Subscribe to catalog events",
+			Description: "Events are POSTed to the URL with an X-Webhook-Signature header: sha256= followed by the hex HMAC-SHA256 " +
+				"of X-Webhook-Timestamp, a dot and the body, keyed with the secret. The secret is generated when not given " +
+				"and only returned here. Use \"*\" to subscribe to every event type. Only admins manage webhooks, and URLs on " +
+				"loopback, private or link-local addresses are refused.",
+			Tags: []string{"webhooks"}, Security: bearer,
+			RequestBody: jsonBody(Ref("Subscription")),
+			Responses: map[string]Response{
+				"201": {Description: "Subscription created", Headers: map[string]Header{"Location": {Schema: &Schema{Type: "string"}}}, Content: jsonContent(Ref("Subscription"))},
+				"400": failure("Invalid body, unknown event type or internal URL"),
+				"403": failure("Not an admin"),
+			},
+		}},
+		operation{"GET", "/webhooks", Operation{
+			Summary: "List webhook subscriptions", Tags: []string{"webhooks"}, Security: bearer,
+			Responses: map[string]Response{
+				"200": ok("All subscriptions, without secrets", ArrayOf(Ref("Subscription"))),
+				"403": failure("Not an admin"),
+				"500": failure("Database error"),
+				"504": failure("Request timed out"),
+			},
+		}},
+		operation{"GET", "/webhooks/:id", Operation{
+			Summary: "Get a webhook subscription", Tags: []string{"webhooks"}, Security: bearer,
+			Parameters: []Parameter{webhookID},
+			Responses: map[string]Response{
+				"200": ok("The subscription, without its secret", Ref("Subscription")),
+				"403": failure("Not an admin"),
+				"404": failure("Webhook not found"),
+			},
+		}},
+		operation{"DELETE", "/webhooks/:id", Operation{
+			Summary: "Delete a webhook subscription and its deliveries", Tags: []string{"webhooks"}, Security: bearer,
+			Parameters: []Parameter{webhookID},
+			Responses: map[string]Response{
+				"204": {Description: "Subscription deleted"},
+				"403": failure("Not an admin"),
+				"404": failure("Webhook not found"),
+			},
+		}},
+		operation{"GET", "/webhooks/:id/deliveries", Operation{
+			Summary: "List the latest deliveries of a subscription", Tags: []string{"webhooks"}, Security: bearer,
+			Parameters: []Parameter{webhookID, queryParam("status", "Only deliveries with this status.", &Schema{Type: "string", Enum: []string{"pending", "succeeded", "dead"}})},
+			Responses: map[string]Response{
+				"200": ok("Deliveries, newest first", ArrayOf(Ref("Delivery"))),
+				"400": failure("Invalid ID or status"),
+				"403": failure("Not an admin"),
+				"404": failure("Webhook not found"),
+			},
+		}},
+		operation{"GET", "/webhooks/dead-letters", Operation{
+			Summary: "List deliveries that ran out of attempts", Tags: []string{"webhooks"}, Security: bearer,
+			Responses: map[string]Response{
+				"200": ok("Dead deliveries, newest first", ArrayOf(Ref("Delivery"))),
+				"403": failure("Not an admin"),
+			},
+		}},
+		operation{"POST", "/webhooks/deliveries/:id/replay", Operation{
+			Summary: "Queue a dead delivery again", Tags: []string{"webhooks"}, Security: bearer,
+			Parameters: []Parameter{idParam("Delivery")},
+			Responses: map[string]Response{
+				"202": ok("The queued delivery", Ref("Delivery")),
+				"400": failure("Invalid ID"),
+				"403": failure("Not an admin"),
+				"404": failure("Dead delivery not found"),
+			},
+		}},
+	)
+
+	table = append(table, operation{"GET", "/changes", Operation{
+		Summary: "Stream book and author changes",
+		Description: "Server-Sent Events named after the event type, with the event as JSON data and the resume token as id. " +
+			"Reconnect with Last-Event-ID to continue without gaps; 410 means the changes since then are gone and the client must start afresh.",
+		Tags: []string{"events"}, Security: bearer,
+		Parameters: []Parameter{
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event.", Schema: &Schema{Type: "string"}},
+			queryParam("resume_after", "Resume after this event, for clients that cannot set headers.", &Schema{Type: "string"}),
+			queryParam("types", "Comma separated event types to stream; all when missing.", &Schema{Type: "string"}),
+		},
+		Responses: map[string]Response{
+			"200": {Description: "Event stream", Content: textContent("text/event-stream")},
+			"400": failure("Invalid resume token or event type"),
+			"410": failure("Resume token expired"),
+		},
+	}})
+
+	table = append(table, operation{"POST", "/graphql", Operation{
+		Summary:     "Run a GraphQL query or mutation",
+		Description: "Errors of the query itself are reported in the errors member with status 200.",
+		Tags:        []string{"graphql"}, Security: bearer,
+		RequestBody: jsonBody(&Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"query":         {Type: "string"},
+				"operationName": {Type: "string"},
+				"variables":     {Type: "object"},
+			},
+			Required: []string{"query"},
+		}),
+		Responses: map[string]Response{
+			"200": ok("GraphQL response", &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"data":   {Type: "object"},
+					"errors": ArrayOf(&Schema{Type: "object"}),
+				},
+			}),
+			"400": failure("Unreadable request"),
+		},
+	}})
+
+	readiness := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status": {Type: "string", Enum: []string{"ready", "not ready", "draining"}},
+			"checks": {Type: "object", AdditionalProperties: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"status":    {Type: "string", Enum: []string{"up", "down"}},
+					"critical":  {Type: "boolean", Description: "Whether the service is unready while this dependency is down."},
+					"latencyMs": {Type: "number"},
+					"error":     {Type: "string"},
+				},
+			}},
+		},
+	}
+	table = append(table,
+		operation{"GET", "/healthz", Operation{
+			Summary: "Liveness probe", Description: "Succeeds while the process serves requests; checks no dependency.",
+			Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{
+				"200": ok("The process is up", &Schema{Type: "object", Properties: map[string]*Schema{"status": {Type: "string"}}}),
+			},
+		}},
+		operation{"GET", "/readyz", Operation{
+			Summary: "Readiness probe", Description: "Checks each dependency and reports its status and latency. Fails once shutdown has begun.",
+			Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{
+				"200": ok("Ready to take traffic", readiness),
+				"503": ok("A critical dependency is down, or the service is draining", readiness),
+			},
+		}},
+	)
+
+	logLevel := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"level": {Type: "string", Enum: []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}}},
+		Required:   []string{"level"},
+	}
+	table = append(table,
+		operation{"GET", "/admin/log-level", Operation{
+			Summary: "Get the log level", Tags: []string{"operations"}, Security: bearer,
+			Responses: map[string]Response{
+				"200": ok("The current level", logLevel),
+				"403": failure("Not an admin"),
+			},
+		}},
+		operation{"PUT", "/admin/log-level", Operation{
+			Summary: "Change the log level", Description: "Takes effect at once and lasts until the next restart.",
+			Tags: []string{"operations"}, Security: bearer,
+			RequestBody: jsonBody(logLevel),
+			Responses: map[string]Response{
+				"200": ok("The new level", logLevel),
+				"400": failure("Unknown level"),
+				"403": failure("Not an admin"),
+			},
+		}},
+		operation{"GET", "/metrics", Operation{
+			Summary: "Prometheus metrics", Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{"200": {Description: "Metrics", Content: textContent(textType)}},
+		}},
+		operation{"GET", "/openapi.json", Operation{
+			Summary: "This document", Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{"200": {Description: "OpenAPI document", Content: jsonContent(&Schema{Type: "object"})}},
+		}},
+		operation{"GET", "/docs", Operation{
+			Summary: "API reference", Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{"200": {Description: "HTML page", Content: textContent(htmlType)}},
+		}},
+		operation{"GET", "/docs/redoc.standalone.js", Operation{
+			Summary: "Redoc script of the API reference", Tags: []string{"operations"}, Security: public,
+			Responses: map[string]Response{"200": {Description: "Redoc " + RedocVersion, Content: textContent(javascriptType)}},
+		}},
+	)
+	return table
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]|0x20) + s[1:]
+}
@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// readOnlyFields are set by the server and ignored in request bodies.
+var readOnlyFields = map[string]bool{
+	"id":        true,
+	"version":   true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// ObjectID is the schema of a hex encoded MongoDB ObjectID.
+func ObjectID() *Schema {
+	return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$"}
+}
+
+// Ref returns a reference to the component schema name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ArrayOf returns an array schema.
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// schemaOf derives the schema of v's type from its json and binding tags.
+// Named struct types are added to schemas and referenced.
+func schemaOf(v interface{}, schemas map[string]*Schema) *Schema {
+	return typeSchema(reflect.TypeOf(v), schemas)
+}
+
+func typeSchema(t reflect.Type, schemas map[string]*Schema) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return ObjectID()
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(typeSchema(t.Elem(), schemas))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			// Register before descending so recursive types terminate.
+			schemas[t.Name()] = &Schema{}
+			*schemas[t.Name()] = *structSchema(t, schemas)
+		}
+		return Ref(t.Name())
+	}
+	// Interfaces and anything else accept any value.
+	return &Schema{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]*Schema) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	addFields(schema, t, schemas)
+	return schema
+}
+
+// addFields adds the fields of t to schema. Embedded structs without a json
+// name are flattened, as encoding/json does.
+func addFields(schema *Schema, t reflect.Type, schemas map[string]*Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(schema, embedded, schemas)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := typeSchema(field.Type, schemas)
+		if readOnlyFields[name] {
+			property = &Schema{Type: property.Type, Format: property.Format, Pattern: property.Pattern, ReadOnly: true}
+		}
+		schema.Properties[name] = property
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/saifujnu/books-authors/events"
+)
+
+// natsTimeout bounds the wait for JetStream to acknowledge an event.
+const natsTimeout = 5 * time.Second
+
+// NATSSink publishes every event to JetStream on the subject
+// "<prefix>.<event type>", for example "catalog.book.created". A stream must
+// cover those subjects. The event ID is sent as Nats-Msg-Id, so JetStream
+// drops the copies of a retried event within its duplicate window.
+type NATSSink struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+func NewNATSSink(url, prefix string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("books-authors"))
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATSSink{conn: conn, js: js, prefix: prefix}, nil
+}
+
+// Publish returns once JetStream has stored the event.
+func (s *NATSSink) Publish(ctx context.Context, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.prefix + "." + string(event.Type))
+	msg.Data = data
+	msg.Header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(ctx, natsTimeout)
+	defer cancel()
+	_, err = s.js.PublishMsg(msg, nats.MsgId(event.ID), nats.Context(ctx))
+	return err
+}
+
+// Ping returns once the server has answered a round trip.
+func (s *NATSSink) Ping(ctx context.Context) error {
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,283 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+)
+
+const (
+	// pollInterval is how often the relay looks for entries written by
+	// other processes or due for a retry.
+	pollInterval = 2 * time.Second
+	// lease hides a claimed entry from other relays. An entry whose relay
+	// died becomes due again when the lease runs out.
+	lease = time.Minute
+	// baseBackoff is the wait after the first failed attempt; it doubles with
+	// every further failure up to maxBackoff.
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// entry is an event waiting in the outbox. Pending names the sinks that
+// have not accepted it yet; the entry is removed once it is empty.
+type entry struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Event         events.Event       `bson:"event"`
+	Pending       []string           `bson:"pending"`
+	Attempts      int                `bson:"attempts"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt"`
+	LastError     string             `bson:"lastError,omitempty"`
+}
+
+// Outbox records events in the same transaction as the write that caused
+// them, and relays them to its sinks in the background. A crash after the
+// write can therefore not lose an event, but it can make a sink receive one
+// twice; sinks pass the event ID on so that receivers can drop duplicates.
+// Entries are relayed oldest first, but one that keeps failing does not hold
+// back those behind it.
+type Outbox struct {
+	client       *mongo.Client
+	entries      *mongo.Collection
+	sinks        map[string]events.Publisher
+	names        []string
+	transactions bool
+	logger       *zap.Logger
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns an outbox relaying to the named sinks. Without transactions,
+// which standalone servers lack, events are recorded right after the write
+// instead, and an event is lost if the process dies in between.
+func New(client *mongo.Client, logger *zap.Logger, sinks map[string]events.Publisher, transactions bool) *Outbox {
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Outbox{
+		client:       client,
+		entries:      client.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Outbox),
+		sinks:        sinks,
+		names:        names,
+		transactions: transactions,
+		logger:       logger,
+		wake:         make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Transactional reports whether writes run in transactions.
+func (o *Outbox) Transactional() bool {
+	return o.transactions
+}
+
+// Tx is the context of a write run by Outbox.Write. Database calls made with
+// it belong to the transaction.
+type Tx struct {
+	mongo.SessionContext
+	events []events.Event
+}
+
+// Record adds an event about the document with the given ID to the
+// transaction. data is the document after the change, nil for deletions.
+func (tx *Tx) Record(t events.Type, id primitive.ObjectID, data interface{}) error {
+	event, err := events.New(t, id, data)
+	if err != nil {
+		return err
+	}
+	tx.events = append(tx.events, event)
+	return nil
+}
+
+// Write runs fn in a transaction and stores the events it records in the
+// outbox as part of it. fn may run more than once when the transaction is
+// retried. Its error is returned as is.
+func (o *Outbox) Write(ctx context.Context, fn func(tx *Tx) error) error {
+	session, err := o.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	run := func(sc mongo.SessionContext) (interface{}, error) {
+		tx := &Tx{SessionContext: sc}
+		if err := fn(tx); err != nil {
+			return nil, err
+		}
+		return nil, o.store(sc, tx.events)
+	}
+
+	if o.transactions {
+		_, err = session.WithTransaction(ctx, run)
+	} else {
+		err = mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+			_, err := run(sc)
+			return err
+		})
+	}
+	if err == nil {
+		o.notify()
+	}
+	return err
+}
+
+func (o *Outbox) store(ctx context.Context, recorded []events.Event) error {
+	if len(recorded) == 0 || len(o.names) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	entries := make([]interface{}, len(recorded))
+	for i, event := range recorded {
+		entries[i] = entry{
+			ID:            primitive.NewObjectID(),
+			Event:         event,
+			Pending:       o.names,
+			NextAttemptAt: now,
+		}
+	}
+	_, err := o.entries.InsertMany(ctx, entries)
+	return err
+}
+
+// notify wakes up the relay.
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the relay until Shutdown is called.
+func (o *Outbox) Start() {
+	o.wg.Add(1)
+	go o.relay()
+}
+
+// Shutdown stops the relay and waits for it to return, or for ctx to
+// expire. Entries not yet relayed stay in the outbox for the next start.
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	o.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *Outbox) relay() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for o.relayNext() {
+		}
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// relayNext claims the oldest due entry and hands it to its pending sinks.
+// It returns false when nothing is due.
+func (o *Outbox) relayNext() bool {
+	now := time.Now().UTC()
+	filter := bson.M{"nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{"nextAttemptAt": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var e entry
+	err := o.entries.FindOneAndUpdate(o.ctx, filter, update, opts).Decode(&e)
+	if errors.Is(err, mongo.ErrNoDocuments) || o.ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		o.logger.Error("Failed to claim outbox entry", zap.Error(err))
+		return false
+	}
+
+	var (
+		pending []string
+		lastErr error
+	)
+	for _, name := range e.Pending {
+		sink, ok := o.sinks[name]
+		if !ok {
+			// The sink has been removed from the configuration since.
+			continue
+		}
+		if err := sink.Publish(o.ctx, e.Event); err != nil {
+			o.logger.Info("Outbox sink failed", zap.String("Sink", name), zap.String("EventID", e.Event.ID), zap.Int("Attempt", e.Attempts), zap.Error(err))
+			pending = append(pending, name)
+			lastErr = err
+		}
+	}
+	if o.ctx.Err() != nil {
+		// Shutting down; the lease brings the entry back later.
+		return false
+	}
+
+	if len(pending) == 0 {
+		_, err = o.entries.DeleteOne(o.ctx, bson.M{"_id": e.ID})
+	} else {
+		_, err = o.entries.UpdateOne(o.ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{
+			"pending":       pending,
+			"nextAttemptAt": time.Now().UTC().Add(backoff(e.Attempts)),
+			"lastError":     lastErr.Error(),
+		}})
+	}
+	if err != nil {
+		o.logger.Error("Failed to update outbox entry", zap.String("EventID", e.Event.ID), zap.Error(err))
+	}
+	return true
+}
+
+// backoff returns the wait before the attempt after the given one.
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 20 {
+		return maxBackoff
+	}
+	if wait := baseBackoff << (attempts - 1); wait < maxBackoff {
+		return wait
+	}
+	return maxBackoff
+}
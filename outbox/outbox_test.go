@@ -0,0 +1,275 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, 256 * time.Second},
+		{10, maxBackoff},
+		{64, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	var published []events.Event
+	// The second sink appends to what the first one wrote.
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, err := events.New(events.BookCreated, primitive.NewObjectID(), map[string]string{"title": "Dune"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		published = append(published, event)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []events.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event events.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %d: %v", len(got)+1, err)
+		}
+		got = append(got, event)
+	}
+	if !reflect.DeepEqual(got, published) {
+		t.Errorf("file holds %+v, want %+v", got, published)
+	}
+}
+
+// sink records the events it accepts and fails while failing is set.
+type sink struct {
+	mu        sync.Mutex
+	failing   bool
+	published []string
+	delivered chan struct{}
+}
+
+func (s *sink) Publish(_ context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, event.ID)
+	if s.delivered != nil {
+		s.delivered <- struct{}{}
+	}
+	return nil
+}
+
+func (s *sink) events() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.published...)
+}
+
+func testOutbox(t *testing.T, sinks map[string]events.Publisher) (*Outbox, *mongo.Collection) {
+	t.Helper()
+	client := mongotest.Connect(t)
+	ob := New(client, zap.NewNop(), sinks, dbmongo.IsReplicated(context.Background(), client))
+	t.Cleanup(func() { ob.Shutdown(context.Background()) })
+	return ob, mongotest.Collection(client, config.Current.Database.Collections.Outbox)
+}
+
+func loadEntries(t *testing.T, entries *mongo.Collection) []entry {
+	t.Helper()
+	cursor, err := entries.Find(context.Background(), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []entry
+	if err := cursor.All(context.Background(), &all); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestWrite(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	ob, entries := testOutbox(t, map[string]events.Publisher{"b": &sink{}, "a": &sink{}})
+	id := primitive.NewObjectID()
+
+	failed := errors.New("validation failed")
+	tests := []struct {
+		name    string
+		fn      func(tx *Tx) error
+		err     error
+		entries int
+	}{
+		{"no events", func(tx *Tx) error { return nil }, nil, 0},
+		{"failed write", func(tx *Tx) error {
+			tx.Record(events.BookCreated, id, nil)
+			return failed
+		}, failed, 0},
+		{"two events", func(tx *Tx) error {
+			if err := tx.Record(events.BookCreated, id, nil); err != nil {
+				return err
+			}
+			return tx.Record(events.AuthorUpdated, id, nil)
+		}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ob.Write(context.Background(), tt.fn); err != tt.err {
+				t.Fatalf("Write = %v, want %v", err, tt.err)
+			}
+			stored := loadEntries(t, entries)
+			if len(stored) != tt.entries {
+				t.Fatalf("%d entries, want %d", len(stored), tt.entries)
+			}
+			for _, e := range stored {
+				if !reflect.DeepEqual(e.Pending, []string{"a", "b"}) || e.Attempts != 0 {
+					t.Errorf("entry %+v, want pending for both sinks", e)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayRetriesFailedSinks(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	stable, flaky := &sink{}, &sink{failing: true}
+	ob, entries := testOutbox(t, map[string]events.Publisher{"stable": stable, "flaky": flaky})
+
+	var recorded string
+	err := ob.Write(context.Background(), func(tx *Tx) error {
+		if err := tx.Record(events.BookCreated, primitive.NewObjectID(), nil); err != nil {
+			return err
+		}
+		recorded = tx.events[0].ID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ob.relayNext() {
+		t.Fatal("nothing relayed")
+	}
+	stored := loadEntries(t, entries)
+	if len(stored) != 1 {
+		t.Fatalf("%d entries after a failed delivery, want 1", len(stored))
+	}
+	e := stored[0]
+	if !reflect.DeepEqual(e.Pending, []string{"flaky"}) || e.Attempts != 1 || e.LastError != "sink unavailable" {
+		t.Errorf("entry after a failed delivery = %+v", e)
+	}
+	if wait := time.Until(e.NextAttemptAt); wait <= 0 || wait > backoff(1) {
+		t.Errorf("next attempt in %v, want within %v", wait, backoff(1))
+	}
+	if ob.relayNext() {
+		t.Error("entry relayed again before its next attempt")
+	}
+
+	// Once due, only the sink that failed gets the event again.
+	flaky.failing = false
+	if _, err := entries.UpdateOne(context.Background(), bson.M{"_id": e.ID}, bson.M{"$set": bson.M{"nextAttemptAt": time.Now().UTC()}}); err != nil {
+		t.Fatal(err)
+	}
+	if !ob.relayNext() {
+		t.Fatal("due entry not relayed")
+	}
+	if stored := loadEntries(t, entries); len(stored) != 0 {
+		t.Errorf("entries left after delivery: %+v", stored)
+	}
+	if got := stable.events(); !reflect.DeepEqual(got, []string{recorded}) {
+		t.Errorf("stable sink got %v, want the event once", got)
+	}
+	if got := flaky.events(); !reflect.DeepEqual(got, []string{recorded}) {
+		t.Errorf("flaky sink got %v, want the event once", got)
+	}
+}
+
+func TestRelayDropsRemovedSinks(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	ob, entries := testOutbox(t, map[string]events.Publisher{"kept": &sink{}})
+
+	event, err := events.New(events.BookDeleted, primitive.NewObjectID(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := entry{ID: primitive.NewObjectID(), Event: event, Pending: []string{"removed"}, NextAttemptAt: time.Now().UTC()}
+	if _, err := entries.InsertOne(context.Background(), stale); err != nil {
+		t.Fatal(err)
+	}
+	if !ob.relayNext() {
+		t.Fatal("nothing relayed")
+	}
+	if stored := loadEntries(t, entries); len(stored) != 0 {
+		t.Errorf("entry for a removed sink kept: %+v", stored)
+	}
+}
+
+func TestRelayRunsUntilShutdown(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	delivered := &sink{delivered: make(chan struct{}, 1)}
+	ob, _ := testOutbox(t, map[string]events.Publisher{"test": delivered})
+	ob.Start()
+
+	err := ob.Write(context.Background(), func(tx *Tx) error {
+		return tx.Record(events.AuthorCreated, primitive.NewObjectID(), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The write wakes the relay rather than waiting for the next poll.
+	select {
+	case <-delivered.delivered:
+	case <-time.After(pollInterval / 2):
+		t.Fatal("event not relayed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ob.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/auth"
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/metrics"
+	"github.com/saifujnu/books-authors/requestlog"
+)
+
+// Response headers, after the IETF draft on RateLimit header fields.
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// APIKeyHeader carries the API key of clients that have one.
+const APIKeyHeader = "X-API-Key"
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take removes a token from the bucket key when it has one. The bucket
+	// holds up to burst tokens, which it starts with, and gains rate of
+	// them per second. Take returns whether it removed a token and the
+	// tokens left.
+	Take(ctx context.Context, key string, burst int, rate float64) (bool, float64, error)
+}
+
+// Limiter limits the requests of each client according to its role: admin
+// and user for holders of a JWT, apikey for clients sending one of the
+// configured API keys and anonymous, by IP address, for the others.
+type Limiter struct {
+	store    Store
+	logger   *zap.Logger
+	policies map[string]config.RatePolicy // by role and route
+	apiKeys  map[string]string            // names by key
+}
+
+// New returns a limiter keeping its buckets in store. apiKeys maps the API
+// keys to the names of their clients.
+func New(store Store, logger *zap.Logger, policies []config.RatePolicy, apiKeys map[string]string) *Limiter {
+	l := &Limiter{
+		store:    store,
+		logger:   logger,
+		policies: make(map[string]config.RatePolicy, len(policies)),
+		apiKeys:  apiKeys,
+	}
+	for _, policy := range policies {
+		l.policies[policy.Role+" "+policy.Route] = policy
+	}
+	return l
+}
+
+// policy returns the most specific policy for role on route: the role's
+// own for the route, that of every role for the route, the role's own for
+// all routes or that of every role for all routes.
+func (l *Limiter) policy(role, route string) (config.RatePolicy, bool) {
+	for _, key := range []string{role + " " + route, "* " + route, role + " ", "* "} {
+		if policy, ok := l.policies[key]; ok {
+			return policy, true
+		}
+	}
+	return config.RatePolicy{}, false
+}
+
+// identify returns the role of the client and who it is within that role.
+// An unknown API key is ignored, as if the client had sent none.
+func (l *Limiter) identify(c *gin.Context) (role, id string) {
+	if name, ok := l.apiKeys[c.GetHeader(APIKeyHeader)]; ok {
+		return "apikey", name
+	}
+	// JWTMiddleware runs later, on the routes that need it, and reuses the
+	// claims verified here; an invalid token is limited as anonymous and
+	// rejected there.
+	if c.GetHeader("Authorization") != "" {
+		if claims, err := auth.Claims(c); err == nil {
+			if username, _ := claims["username"].(string); username != "" {
+				if auth.IsAdmin(claims) {
+					return "admin", username
+				}
+				return "user", username
+			}
+		}
+	}
+	return "anonymous", c.ClientIP()
+}
+
+// Middleware takes a token from the client's bucket for every request and
+// refuses the request with a 429 Too Many Requests when there is none
+// left. Routes with a policy of their own have a bucket of their own.
+// Every limited response carries the RateLimit headers, and a refusal
+// Retry-After. Requests go through while the store is unreachable.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, id := l.identify(c)
+		policy, ok := l.policy(role, c.Request.Method+" "+c.FullPath())
+		if !ok || policy.Requests == 0 {
+			c.Next()
+			return
+		}
+
+		key := "ratelimit:" + role + ":" + id
+		if policy.Route != "" {
+			key += ":" + policy.Route
+		}
+		rate := float64(policy.Requests) / policy.Period.Seconds()
+		allowed, tokens, err := l.store.Take(c.Request.Context(), key, policy.Requests, rate)
+		if err != nil {
+			requestlog.FromContext(c.Request.Context(), l.logger).Warn("Rate limiter unavailable", zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header(HeaderLimit, strconv.Itoa(policy.Requests))
+		c.Header(HeaderRemaining, strconv.Itoa(int(tokens)))
+		c.Header(HeaderReset, seconds((float64(policy.Requests)-tokens)/rate))
+		c.Header(HeaderPolicy, strconv.Itoa(policy.Requests)+";w="+seconds(policy.Period.Seconds()))
+		if !allowed {
+			metrics.RateLimited(c.FullPath(), c.Request.Method, role)
+			c.Header("Retry-After", seconds((1-tokens)/rate))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds s up to whole seconds, as the headers want them.
+func seconds(s float64) string {
+	return strconv.Itoa(int(math.Max(0, math.Ceil(s))))
+}
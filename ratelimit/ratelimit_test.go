@@ -0,0 +1,254 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/auth"
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/models"
+)
+
+// clockStore is a store whose clock the test moves.
+type clockStore struct {
+	Store
+	advance func(time.Duration)
+}
+
+func memoryStore(t *testing.T) clockStore {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	return clockStore{store, func(d time.Duration) { now = now.Add(d) }}
+}
+
+func redisStore(t *testing.T) clockStore {
+	server := miniredis.RunT(t)
+	now := time.Now()
+	server.SetTime(now)
+	store, err := NewRedisStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return clockStore{store, func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+	}}
+}
+
+func TestStores(t *testing.T) {
+	type take struct {
+		after   time.Duration
+		key     string
+		allowed bool
+		tokens  float64
+	}
+	// Buckets of 2 tokens gaining one a second.
+	takes := []take{
+		{0, "a", true, 1},
+		{0, "a", true, 0},
+		{0, "a", false, 0},
+		{0, "b", true, 1},
+		{500 * time.Millisecond, "a", false, 0.5},
+		{500 * time.Millisecond, "a", true, 0},
+		{10 * time.Second, "a", true, 1},
+	}
+
+	for name, newStore := range map[string]func(*testing.T) clockStore{"memory": memoryStore, "redis": redisStore} {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			for i, tt := range takes {
+				store.advance(tt.after)
+				allowed, tokens, err := store.Take(context.Background(), tt.key, 2, 1)
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				if allowed != tt.allowed || tokens != tt.tokens {
+					t.Errorf("take %d from %s = %v, %v tokens; want %v, %v tokens", i, tt.key, allowed, tokens, tt.allowed, tt.tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisBucketsExpireWhenFull(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// One token short of 10, at 2 a second: full in 500ms, kept 1s longer.
+	if _, _, err := store.Take(context.Background(), "a", 10, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.TTL("a"); got != 1500*time.Millisecond {
+		t.Errorf("TTL = %v, want 1.5s", got)
+	}
+	server.FastForward(1500 * time.Millisecond)
+	if server.Exists("a") {
+		t.Error("full bucket still stored")
+	}
+}
+
+// failingStore is an unreachable store.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, int, float64) (bool, float64, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+// recordingStore records the buckets it is asked for.
+type recordingStore struct {
+	Store
+	keys []string
+}
+
+func (s *recordingStore) Take(ctx context.Context, key string, burst int, rate float64) (bool, float64, error) {
+	s.keys = append(s.keys, key)
+	return s.Store.Take(ctx, key, burst, rate)
+}
+
+func token(t *testing.T, username, role string) string {
+	t.Helper()
+	token, err := auth.GenerateToken(username, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+func TestIdentities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policies := []config.RatePolicy{
+		{Role: "admin", Requests: 100, Period: time.Minute},
+		{Role: "user", Requests: 50, Period: time.Minute},
+		{Role: "apikey", Requests: 20, Period: time.Minute},
+		{Role: "anonymous", Requests: 10, Period: time.Minute},
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		key    string
+		limit  string
+	}{
+		{"anonymous", map[string]string{}, "ratelimit:anonymous:192.0.2.1", "10"},
+		{"API key", map[string]string{APIKeyHeader: "partner-key"}, "ratelimit:apikey:partner", "20"},
+		{"unknown API key", map[string]string{APIKeyHeader: "stolen-key"}, "ratelimit:anonymous:192.0.2.1", "10"},
+		{"unknown API key and token", map[string]string{APIKeyHeader: "stolen-key", "Authorization": token(t, "alice", models.RoleUser)}, "ratelimit:user:alice", "50"},
+		{"user", map[string]string{"Authorization": token(t, "alice", models.RoleUser)}, "ratelimit:user:alice", "50"},
+		{"admin", map[string]string{"Authorization": token(t, "root", models.RoleAdmin)}, "ratelimit:admin:root", "100"},
+		{"invalid token", map[string]string{"Authorization": "Bearer not-a-jwt"}, "ratelimit:anonymous:192.0.2.1", "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &recordingStore{Store: NewMemoryStore()}
+			limiter := New(store, zap.NewNop(), policies, map[string]string{"partner-key": "partner"})
+
+			var verified bool
+			router := gin.New()
+			router.GET("/books", limiter.Middleware(), func(c *gin.Context) {
+				// JWTMiddleware finds the claims the limiter verified.
+				_, verified = c.Get("Claims")
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/books", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if len(store.keys) != 1 || store.keys[0] != tt.key {
+				t.Errorf("buckets = %v, want [%s]", store.keys, tt.key)
+			}
+			if got := w.Header().Get(HeaderLimit); got != tt.limit {
+				t.Errorf("%s = %q, want %s", HeaderLimit, got, tt.limit)
+			}
+			if wantClaims := tt.limit == "50" || tt.limit == "100"; verified != wantClaims {
+				t.Errorf("claims in context = %v, want %v", verified, wantClaims)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memoryStore(t)
+	policies := []config.RatePolicy{
+		{Role: "*", Requests: 3, Period: time.Minute},
+		{Role: "*", Route: "POST /books", Requests: 1, Period: 10 * time.Second},
+		{Role: "*", Route: "GET /healthz"},
+	}
+	limiter := New(store, zap.NewNop(), policies, nil)
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	for _, route := range []string{"/books", "/authors", "/healthz"} {
+		router.GET(route, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	router.POST("/books", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	tests := []struct {
+		method, target string
+		after          time.Duration
+		status         int
+		headers        map[string]string
+	}{
+		// Tokens come back at one every 20s.
+		{"GET", "/books", 0, 200, map[string]string{HeaderLimit: "3", HeaderRemaining: "2", HeaderReset: "20", HeaderPolicy: "3;w=60", "Retry-After": ""}},
+		{"GET", "/authors", 0, 200, map[string]string{HeaderRemaining: "1", HeaderReset: "40"}},
+		{"GET", "/books", 0, 200, map[string]string{HeaderRemaining: "0", HeaderReset: "60"}},
+		{"GET", "/books", 0, 429, map[string]string{HeaderRemaining: "0", HeaderReset: "60", "Retry-After": "20"}},
+		{"GET", "/books", 5 * time.Second, 429, map[string]string{HeaderRemaining: "0", HeaderReset: "55", "Retry-After": "15"}},
+		{"GET", "/books", 15 * time.Second, 200, map[string]string{HeaderRemaining: "0", HeaderReset: "60"}},
+		// The route's own bucket.
+		{"POST", "/books", 0, 201, map[string]string{HeaderLimit: "1", HeaderRemaining: "0", HeaderReset: "10", HeaderPolicy: "1;w=10"}},
+		{"POST", "/books", 0, 429, map[string]string{"Retry-After": "10"}},
+		// Unlimited.
+		{"GET", "/healthz", 0, 200, map[string]string{HeaderLimit: ""}},
+	}
+	for i, tt := range tests {
+		store.advance(tt.after)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, tt.status)
+		}
+		for name, want := range tt.headers {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i, name, got, want)
+			}
+		}
+	}
+}
+
+func TestUnavailableStoreLetsRequestsThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := New(failingStore{}, zap.NewNop(), []config.RatePolicy{{Role: "*", Requests: 1, Period: time.Minute}}, nil)
+	router := gin.New()
+	router.GET("/books", limiter.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books", nil))
+		if w.Code != http.StatusOK || w.Header().Get(HeaderLimit) != "" {
+			t.Errorf("request %d: status %d with %s %q, want 200 without", i, w.Code, HeaderLimit, w.Header().Get(HeaderLimit))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package requestlog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/saifujnu/books-authors/tracing"
+)
+
+// Header carries the request ID, both ways.
+const Header = "X-Request-ID"
+
+// maxIDLength bounds the request IDs accepted from clients.
+const maxIDLength = 128
+
+// idKey is the gin context key of the request ID.
+const idKey = "RequestID"
+
+type loggerKey struct{}
+
+// Middleware gives every request an ID, taken from the X-Request-ID header
+// when the client sent a usable one and generated otherwise. The ID is sent
+// back in the same header and in the body of every JSON error response.
+// Handlers log with FromContext, whose entries carry the ID and the route,
+// so that the entries of concurrent requests can be told apart.
+func Middleware(base *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !validID(id) {
+			id = primitive.NewObjectID().Hex()
+		}
+		c.Set(idKey, id)
+		c.Header(Header, id)
+		c.Writer = &errorWriter{ResponseWriter: c.Writer, id: id}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := append([]zapcore.Field{zap.String("request_id", id), zap.String("route", route)}, tracing.LogFields(c)...)
+		setLogger(c, base.With(fields...))
+
+		c.Next()
+	}
+}
+
+// validID reports whether id is safe to log and to send back as is.
+func validID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ID returns the ID of the request, or "" outside of Middleware.
+func ID(c *gin.Context) string {
+	return c.GetString(idKey)
+}
+
+// With adds fields to the logger of the request, for the handlers after the
+// caller. It does nothing outside of Middleware.
+func With(c *gin.Context, fields ...zapcore.Field) {
+	if logger, ok := c.Request.Context().Value(loggerKey{}).(*zap.Logger); ok {
+		setLogger(c, logger.With(fields...))
+	}
+}
+
+func setLogger(c *gin.Context, logger *zap.Logger) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), loggerKey{}, logger))
+}
+
+// FromContext returns the logger of the request ctx belongs to, or
+// fallback outside of Middleware.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// LogFields returns the request ID, for the request log.
+func LogFields(c *gin.Context) []zapcore.Field {
+	if id := ID(c); id != "" {
+		return []zapcore.Field{zap.String("request_id", id)}
+	}
+	return nil
+}
+
+// errorWriter adds the request ID to JSON error bodies, all of which are
+// objects written in one piece, so that clients can quote it when they
+// report a failure.
+type errorWriter struct {
+	gin.ResponseWriter
+	id      string
+	written bool
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.written || w.Status() < 400 || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") || !bytes.HasPrefix(data, []byte("{")) {
+		w.written = true
+		return w.ResponseWriter.Write(data)
+	}
+	w.written = true
+
+	field := `"request_id":"` + w.id + `"`
+	if !bytes.HasPrefix(bytes.TrimSpace(data[1:]), []byte("}")) {
+		field += ","
+	}
+	body := make([]byte, 0, len(data)+len(field))
+	body = append(body, '{')
+	body = append(body, field...)
+	body = append(body, data[1:]...)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
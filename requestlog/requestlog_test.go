@@ -0,0 +1,109 @@
+package requestlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"64b7f0c2a1b2c3d4e5f60718", true},
+		{"req-1_a.b:c", true},
+		{strings.Repeat("a", maxIDLength), true},
+		{strings.Repeat("a", maxIDLength+1), false},
+		{"two words", false},
+		{"line\nbreak", false},
+		{`quote"`, false},
+		{"ünïcode", false},
+	}
+	for _, tt := range tests {
+		if got := validID(tt.id); got != tt.want {
+			t.Errorf("validID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	router := gin.New()
+	router.Use(Middleware(zap.New(core)))
+	router.GET("/books/:id", func(c *gin.Context) {
+		With(c, zap.String("username", "alice"))
+		FromContext(c.Request.Context(), zap.NewNop()).Info("Fetching book")
+		switch c.Param("id") {
+		case "missing":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		case "empty":
+			c.JSON(http.StatusBadRequest, gin.H{})
+		case "text":
+			c.String(http.StatusInternalServerError, "{not json}")
+		default:
+			c.JSON(http.StatusOK, gin.H{"title": "Dune"})
+		}
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		id     string // empty for a generated one
+		body   string // with ID standing for the request ID
+	}{
+		{"client ID", "/books/1", "abc-123", "abc-123", `{"title":"Dune"}`},
+		{"generated ID", "/books/1", "", "", `{"title":"Dune"}`},
+		{"unusable client ID", "/books/1", "<script>", "", `{"title":"Dune"}`},
+		{"error", "/books/missing", "abc-123", "abc-123", `{"request_id":"ID","error":"Book not found"}`},
+		{"empty error", "/books/empty", "abc-123", "abc-123", `{"request_id":"ID"}`},
+		{"text error", "/books/text", "abc-123", "abc-123", `{not json}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(Header)
+			if tt.id != "" && id != tt.id || tt.id == "" && (!validID(id) || id == tt.header) {
+				t.Errorf("%s = %q, want %q", Header, id, tt.id)
+			}
+			if want := strings.Replace(tt.body, "ID", id, 1); w.Body.String() != want {
+				t.Errorf("body = %s, want %s", w.Body, want)
+			}
+
+			entries := logs.TakeAll()
+			if len(entries) != 1 {
+				t.Fatalf("%d log entries, want 1", len(entries))
+			}
+			fields := entries[0].ContextMap()
+			if fields["request_id"] != id || fields["route"] != "/books/:id" || fields["username"] != "alice" {
+				t.Errorf("log fields %v", fields)
+			}
+		})
+	}
+}
+
+func TestOutsideMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/books", nil)
+	With(c, zap.String("username", "alice"))
+
+	fallback := zap.NewNop()
+	if ID(c) != "" || LogFields(c) != nil || FromContext(c.Request.Context(), fallback) != fallback {
+		t.Error("request outside the middleware has an ID or logger")
+	}
+}
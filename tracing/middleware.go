@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Middleware starts a span for every request, continuing the trace of an
+// incoming traceparent header. Handlers find the span in the request
+// context, and database calls made with that context become its children.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName)
+}
+
+// LogFields returns the trace and span IDs of the request, for the request
+// log. The request must have passed through Middleware.
+func LogFields(c *gin.Context) []zapcore.Field {
+	spanContext := trace.SpanContextFromContext(c.Request.Context())
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []zapcore.Field{
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	}
+}
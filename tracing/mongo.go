@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CommandMonitor records a span for every MongoDB command run within a
+// trace. Commands of the background workers, which poll every few seconds,
+// would otherwise start a trace of their own each time, so commands without
+// a span in their context are left out. Command bodies are not recorded, as
+// they may hold user data.
+func CommandMonitor() *event.CommandMonitor {
+	monitor := otelmongo.NewMonitor()
+	started := monitor.Started
+	monitor.Started = func(ctx context.Context, e *event.CommandStartedEvent) {
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			started(ctx, e)
+		}
+	}
+	return monitor
+}
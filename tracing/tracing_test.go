@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID    = "00f067aa0ba902b7"
+	traceparent = "00-" + traceID + "-" + parentID + "-01"
+)
+
+// restoreGlobals puts back the tracer provider and propagator that Setup
+// replaces.
+func restoreGlobals(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func TestSetupExporters(t *testing.T) {
+	restoreGlobals(t)
+	tests := []struct {
+		exporter string
+		path     string
+		err      string
+	}{
+		{"", "", ""},
+		{ExporterNone, "", ""},
+		{ExporterStdout, "", ""},
+		{ExporterFile, filepath.Join(t.TempDir(), "spans.json"), ""},
+		{ExporterFile, "", "tracing: the file exporter needs a path"},
+		{"jaeger", "", `tracing: unknown exporter "jaeger"`},
+	}
+	for _, tt := range tests {
+		shutdown, err := Setup(context.Background(), tt.exporter, tt.path)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Setup(%q, %q) = %v, want %s", tt.exporter, tt.path, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Setup(%q, %q) = %v", tt.exporter, tt.path, err)
+			continue
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutting down %q: %v", tt.exporter, err)
+		}
+	}
+}
+
+// fieldMap returns the string values of fields by key.
+func fieldMap(fields []zapcore.Field) map[string]string {
+	values := map[string]string{}
+	for _, field := range fields {
+		values[field.Key] = field.String
+	}
+	return values
+}
+
+func TestMiddlewareContinuesTraces(t *testing.T) {
+	restoreGlobals(t)
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), ExporterFile, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]string
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/books/:id", func(c *gin.Context) {
+		fields = fieldMap(LogFields(c))
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		traceparent string
+		traceID     string // empty for a new trace
+	}{
+		{"incoming trace", traceparent, traceID},
+		{"new trace", "", ""},
+		{"malformed traceparent", "00-xyz-01", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		if tt.traceparent != "" {
+			req.Header.Set("traceparent", tt.traceparent)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if len(fields["trace_id"]) != 32 || len(fields["span_id"]) != 16 {
+			t.Errorf("%s: log fields %v", tt.name, fields)
+			continue
+		}
+		if tt.traceID != "" && fields["trace_id"] != tt.traceID {
+			t.Errorf("%s: trace_id = %s, want %s", tt.name, fields["trace_id"], tt.traceID)
+		}
+		if tt.traceID == "" && fields["trace_id"] == traceID {
+			t.Errorf("%s: continued the earlier trace", tt.name)
+		}
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(spans)), "\n")
+	if len(lines) != len(tests) {
+		t.Fatalf("%d spans exported, want %d:\n%s", len(lines), len(tests), spans)
+	}
+	var span struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Parent      struct{ SpanID string }
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &span); err != nil {
+		t.Fatal(err)
+	}
+	if span.Name != "/books/:id" || span.SpanContext.TraceID != traceID || span.Parent.SpanID != parentID {
+		t.Errorf("exported span %+v, want /books/:id in trace %s under %s", span, traceID, parentID)
+	}
+}
+
+func TestLogFieldsWithoutSpan(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/books", nil)
+	if fields := LogFields(c); fields != nil {
+		t.Errorf("LogFields = %v, want none", fields)
+	}
+}
@@ -0,0 +1,362 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	"github.com/saifujnu/books-authors/events"
+)
+
+const (
+	// workers is the number of deliveries sent in parallel.
+	workers = 4
+	// pollInterval is how often workers look for retries that became due.
+	pollInterval = 5 * time.Second
+	// attemptTimeout bounds a single POST to a subscriber.
+	attemptTimeout = 10 * time.Second
+	// lease hides a claimed delivery from other workers. A delivery whose
+	// worker died becomes due again when the lease runs out.
+	lease = time.Minute
+	// maxAttempts is the number of attempts before a delivery is dead.
+	maxAttempts = 8
+	// baseBackoff is the wait after the first failed attempt; it doubles with
+	// every further failure up to maxBackoff.
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Headers of a delivery request.
+const (
+	HeaderEventID   = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Dispatcher is an events.Publisher that queues every event for the
+// subscriptions interested in it and delivers the queue in the background.
+// The queue lives in MongoDB, so deliveries survive restarts.
+type Dispatcher struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+	client        *http.Client
+	logger        *zap.Logger
+	// allowInternal lets subscribers be on internal addresses.
+	allowInternal bool
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ events.Publisher = (*Dispatcher)(nil)
+
+func NewDispatcher(db *mongo.Client, logger *zap.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	allowInternal := config.Current.Webhooks.AllowInternalTargets
+	return &Dispatcher{
+		subscriptions: db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.Webhooks),
+		deliveries:    db.Database(config.Current.Database.Name).Collection(config.Current.Database.Collections.WebhookDeliveries),
+		client:        newClient(allowInternal),
+		logger:        logger,
+		allowInternal: allowInternal,
+		wake:          make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// newClient returns the client posting deliveries. Unless allowInternal
+// is set, it refuses to connect to internal addresses.
+func newClient(allowInternal bool) *http.Client {
+	dialer := &net.Dialer{Timeout: attemptTimeout}
+	if !allowInternal {
+		dialer.Control = refuseInternal
+	}
+	// No proxy: the dialer has to see the subscriber's address.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: attemptTimeout, Transport: transport}
+}
+
+// Publish queues a delivery of event for every matching subscription. An
+// event published again does not queue a second delivery.
+func (d *Dispatcher) Publish(ctx context.Context, event events.Event) error {
+	filter := bson.M{"events": bson.M{"$in": bson.A{event.Type, AllEvents}}}
+	cursor, err := d.subscriptions.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var subs []Subscription
+	if err := cursor.All(ctx, &subs); err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	upserts := make([]mongo.WriteModel, len(subs))
+	for i, sub := range subs {
+		delivery := Delivery{
+			SubscriptionID: sub.ID,
+			Event:          event,
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		upserts[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"subscriptionId": sub.ID, "event.id": event.ID}).
+			SetUpdate(bson.M{"$setOnInsert": delivery}).
+			SetUpsert(true)
+	}
+	// A concurrent publish of the same event can lose the race to insert
+	// the delivery; the unique index then reports what is already queued.
+	if _, err := d.deliveries.BulkWrite(ctx, upserts, options.BulkWrite().SetOrdered(false)); err != nil && !onlyDuplicates(err) {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+// onlyDuplicates reports whether err is a bulk write error whose every
+// write failed on a duplicate key.
+func onlyDuplicates(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// notify wakes up an idle worker.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery workers until Shutdown is called.
+func (d *Dispatcher) Start() {
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+// Shutdown stops the workers and waits for them to return, or for ctx to
+// expire. Deliveries interrupted half way are retried once their lease runs
+// out.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for d.deliverNext() {
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// deliverNext claims the delivery that is due first and attempts it. It
+// returns false when nothing is due.
+func (d *Dispatcher) deliverNext() bool {
+	now := time.Now().UTC()
+	filter := bson.M{"status": StatusPending, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{"nextAttemptAt": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery Delivery
+	err := d.deliveries.FindOneAndUpdate(d.ctx, filter, update, opts).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) || d.ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		d.logger.Error("Failed to claim webhook delivery", zap.Error(err))
+		return false
+	}
+
+	d.attempt(delivery)
+	return true
+}
+
+// attempt sends delivery once and records the outcome.
+func (d *Dispatcher) attempt(delivery Delivery) {
+	logger := d.logger.With(zap.String("DeliveryID", delivery.ID.Hex()), zap.String("Event", string(delivery.Event.Type)))
+
+	var sub Subscription
+	err := d.subscriptions.FindOne(d.ctx, bson.M{"_id": delivery.SubscriptionID}).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		d.record(delivery, 0, errors.New("subscription deleted"), true)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load webhook subscription", zap.Error(err))
+		return
+	}
+
+	status, err := d.post(sub, delivery)
+	if d.ctx.Err() != nil {
+		// Shutting down; the lease brings the delivery back later.
+		return
+	}
+	if err != nil {
+		logger.Info("Webhook delivery failed", zap.Int("Attempt", delivery.Attempts), zap.Error(err))
+	} else {
+		logger.Debug("Webhook delivered", zap.Int("Attempt", delivery.Attempts))
+	}
+	d.record(delivery, status, err, delivery.Attempts >= maxAttempts)
+}
+
+// post sends the event to the subscriber. Any status other than 2xx is a
+// failure.
+func (d *Dispatcher) post(sub Subscription, delivery Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, attemptTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "books-authors-webhooks")
+	req.Header.Set(HeaderEventID, delivery.Event.ID)
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of an attempt. Failed deliveries are scheduled
+// for a retry unless final is set, in which case they are dead.
+func (d *Dispatcher) record(delivery Delivery, status int, deliveryErr error, final bool) {
+	now := time.Now().UTC()
+	set := bson.M{"lastAttemptAt": now}
+	unset := bson.M{}
+	if status != 0 {
+		set["responseStatus"] = status
+	} else {
+		unset["responseStatus"] = ""
+	}
+
+	switch {
+	case deliveryErr == nil:
+		set["status"] = StatusSucceeded
+		unset["lastError"] = ""
+	case final:
+		set["status"] = StatusDead
+		set["lastError"] = deliveryErr.Error()
+	default:
+		set["nextAttemptAt"] = now.Add(backoff(delivery.Attempts))
+		set["lastError"] = deliveryErr.Error()
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if _, err := d.deliveries.UpdateOne(d.ctx, bson.M{"_id": delivery.ID}, update); err != nil {
+		d.logger.Error("Failed to record webhook delivery", zap.String("DeliveryID", delivery.ID.Hex()), zap.Error(err))
+	}
+}
+
+// backoff returns the wait before the attempt after the given one, with up
+// to 10% jitter so that failures of one subscriber do not retry in lockstep.
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := maxBackoff
+	if attempts < 20 {
+		if exp := baseBackoff << (attempts - 1); exp < maxBackoff {
+			wait = exp
+		}
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait/10)+1))
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// subscription secret. Receivers recompute it from the X-Webhook-Timestamp
+// header and the raw body, and compare it with X-Webhook-Signature.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
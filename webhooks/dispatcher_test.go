@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/saifujnu/books-authors/config"
+	dbmongo "github.com/saifujnu/books-authors/db/mongo"
+	"github.com/saifujnu/books-authors/events"
+	"github.com/saifujnu/books-authors/mongotest"
+)
+
+func TestPostSignsDeliveries(t *testing.T) {
+	sub := Subscription{Secret: "s3cret"}
+	delivery := Delivery{ID: primitive.NewObjectID(), Event: events.Event{ID: "event-1", Type: events.BookCreated}}
+
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	sub.URL = server.URL
+
+	d := &Dispatcher{client: newClient(true), ctx: context.Background()}
+	if _, err := d.post(sub, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get(HeaderEventID) != "event-1" || got.Header.Get(HeaderEvent) != string(events.BookCreated) {
+		t.Errorf("event headers = %q, %q", got.Header.Get(HeaderEventID), got.Header.Get(HeaderEvent))
+	}
+	timestamp, _ := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if want := "sha256=" + Sign(sub.Secret, timestamp, body); got.Header.Get(HeaderSignature) != want {
+		t.Errorf("signature = %q, want %q", got.Header.Get(HeaderSignature), want)
+	}
+}
+
+func TestPostRefusesInternalTargets(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	defer server.Close()
+
+	// A subscription stored before its name started resolving to an
+	// internal address.
+	d := &Dispatcher{client: newClient(false), ctx: context.Background()}
+	if _, err := d.post(Subscription{URL: server.URL}, Delivery{}); err == nil {
+		t.Error("post to a loopback address succeeded")
+	}
+	if reached {
+		t.Error("the loopback subscriber was reached")
+	}
+}
+
+func TestSubscribeChecksTargets(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client := mongotest.Connect(t)
+
+	tests := []struct {
+		name          string
+		allowInternal bool
+		url           string
+		ok            bool
+	}{
+		{"public address", false, "https://93.184.216.34/hooks", true},
+		{"loopback", false, "http://127.0.0.1:9000/hooks", false},
+		{"metadata service", false, "http://169.254.169.254/", false},
+		{"loopback allowed", true, "http://127.0.0.1:9000/hooks", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Current.Webhooks.AllowInternalTargets = tt.allowInternal
+			d := NewDispatcher(client, zap.NewNop())
+			_, err := d.Subscribe(context.Background(), Subscription{URL: tt.url, Events: []events.Type{AllEvents}})
+			if tt.ok && err != nil {
+				t.Errorf("Subscribe = %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Subscribe = %v, want an ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestPublishQueuesOneDeliveryPerEvent(t *testing.T) {
+	defer func(saved config.Config) { config.Current = saved }(config.Current)
+	client := mongotest.Connect(t)
+	if err := dbmongo.EnsureIndexes(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	config.Current.Webhooks.AllowInternalTargets = true
+	d := NewDispatcher(client, zap.NewNop())
+	ctx := context.Background()
+	sub, err := d.Subscribe(ctx, Subscription{URL: "http://127.0.0.1:9/", Events: []events.Type{events.BookCreated}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := events.Event{ID: "event-1", Type: events.BookCreated}
+	for i := 0; i < 2; i++ {
+		if err := d.Publish(ctx, event); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+	if err := d.Publish(ctx, events.Event{ID: "event-2", Type: events.AuthorCreated}); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := d.Deliveries(ctx, sub.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event.ID != "event-1" {
+		t.Errorf("deliveries = %+v, want one of event-1", deliveries)
+	}
+}
+
+func TestOnlyDuplicates(t *testing.T) {
+	duplicate := mongo.BulkWriteError{WriteError: mongo.WriteError{Code: 11000}}
+	other := mongo.BulkWriteError{WriteError: mongo.WriteError{Code: 121}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"duplicates", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate, duplicate}}, true},
+		{"mixed", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate, other}}, false},
+		{"write concern", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate}, WriteConcernError: &mongo.WriteConcernError{}}, false},
+		{"other error", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := onlyDuplicates(tt.err); got != tt.want {
+			t.Errorf("%s: onlyDuplicates = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, private in
+// all but name.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// internalIP reports whether ip belongs to this host or its private
+// network: loopback, private, link-local, shared, unspecified or multicast.
+// Subscribers there would let anyone with a subscription probe the
+// internal network, the cloud metadata service included.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// checkTarget rejects URLs that are not http or https, or whose host
+// resolves to an internal address. The dispatcher checks again when it
+// dials, since the name can resolve differently later.
+func checkTarget(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: url must be http or https", ErrInvalid)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: url has no host", ErrInvalid)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s: %v", ErrInvalid, host, err)
+	}
+	for _, addr := range addrs {
+		if internalIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to the internal address %s", ErrInvalid, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// refuseInternal is a net.Dialer Control refusing connections to internal
+// addresses. It sees the address actually dialled, after resolution and
+// after every redirect.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+		return fmt.Errorf("webhooks: refusing to connect to the internal address %s", host)
+	}
+	return nil
+}
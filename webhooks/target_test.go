@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestInternalIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"100.128.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := internalIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("internalIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hooks", true},
+		{"http://[2606:4700::1111]:8080/hooks", true},
+		{"http://127.0.0.1:8080/hooks", false},
+		{"http://localhost/hooks", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/hooks", false},
+		{"ftp://93.184.216.34/hooks", false},
+		{"http:///hooks", false},
+	}
+	for _, tt := range tests {
+		err := checkTarget(context.Background(), tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkTarget(%s) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalid) {
+			t.Errorf("checkTarget(%s) = %v, want an ErrInvalid", tt.url, err)
+		}
+	}
+}
+
+func TestRefuseInternal(t *testing.T) {
+	tests := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"127.0.0.1:80", false},
+		{"[fe80::1]:80", false},
+		{"10.0.0.1:8080", false},
+	}
+	for _, tt := range tests {
+		if err := refuseInternal("tcp", tt.address, nil); (err == nil) != tt.ok {
+			t.Errorf("refuseInternal(%s) = %v, want ok %v", tt.address, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/saifujnu/books-authors/events"
+)
+
+// AllEvents subscribes to every event type.
+const AllEvents events.Type = "*"
+
+// Subscription asks for the events of the listed types to be POSTed to URL.
+// Secret keys the delivery signatures; it is only returned when the
+// subscription is created.
+type Subscription struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	URL       string             `json:"url" bson:"url" binding:"required,url"`
+	Events    []events.Type      `json:"events" bson:"events" binding:"required,min=1"`
+	Secret    string             `json:"secret,omitempty" bson:"secret"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	// StatusDead marks deliveries that ran out of attempts. They stay in the
+	// dead-letter list until they are replayed.
+	StatusDead Status = "dead"
+)
+
+// Delivery is one event on its way to one subscription.
+type Delivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubscriptionID primitive.ObjectID `json:"subscriptionId" bson:"subscriptionId"`
+	Event          events.Event       `json:"event" bson:"event"`
+	Status         Status             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastAttemptAt  *time.Time         `json:"lastAttemptAt,omitempty" bson:"lastAttemptAt,omitempty"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+var (
+	ErrNotFound = errors.New("webhooks: not found")
+	// ErrInvalid is wrapped by the errors of subscriptions that cannot be stored.
+	ErrInvalid = errors.New("invalid subscription")
+)
+
+// maxListed caps the number of deliveries returned by Deliveries.
+const maxListed = 100
+
+// Subscribe stores a new subscription. A secret is generated when none is
+// given. URLs on internal addresses are refused unless the configuration
+// allows them.
+func (d *Dispatcher) Subscribe(ctx context.Context, sub Subscription) (Subscription, error) {
+	if !d.allowInternal {
+		if err := checkTarget(ctx, sub.URL); err != nil {
+			return sub, err
+		}
+	}
+	for _, t := range sub.Events {
+		if t != AllEvents && !events.Valid(t) {
+			return sub, fmt.Errorf("%w: unknown event type %q", ErrInvalid, t)
+		}
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return sub, err
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	sub.ID = primitive.NewObjectID()
+	sub.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	if _, err := d.subscriptions.InsertOne(ctx, sub); err != nil {
+		return sub, err
+	}
+	return sub, nil
+}
+
+// Subscriptions returns every subscription, without secrets.
+func (d *Dispatcher) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	cursor, err := d.subscriptions.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"secret": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []Subscription{}
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Subscription returns one subscription, without its secret.
+func (d *Dispatcher) Subscription(ctx context.Context, id primitive.ObjectID) (Subscription, error) {
+	var sub Subscription
+	err := d.subscriptions.FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"secret": 0})).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sub, ErrNotFound
+	}
+	return sub, err
+}
+
+// Unsubscribe deletes a subscription together with its deliveries.
+func (d *Dispatcher) Unsubscribe(ctx context.Context, id primitive.ObjectID) error {
+	result, err := d.subscriptions.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = d.deliveries.DeleteMany(ctx, bson.M{"subscriptionId": id})
+	return err
+}
+
+// Deliveries returns the latest deliveries, newest first. A zero
+// subscriptionID or an empty status matches any.
+func (d *Dispatcher) Deliveries(ctx context.Context, subscriptionID primitive.ObjectID, status Status) ([]Delivery, error) {
+	filter := bson.M{}
+	if !subscriptionID.IsZero() {
+		filter["subscriptionId"] = subscriptionID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(maxListed)
+	cursor, err := d.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []Delivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Replay moves a dead delivery back to the queue with a fresh set of
+// attempts.
+func (d *Dispatcher) Replay(ctx context.Context, id primitive.ObjectID) (Delivery, error) {
+	update := bson.M{
+		"$set":   bson.M{"status": StatusPending, "attempts": 0, "nextAttemptAt": time.Now().UTC()},
+		"$unset": bson.M{"lastError": "", "responseStatus": ""},
+	}
+	var delivery Delivery
+	err := d.deliveries.FindOneAndUpdate(ctx, bson.M{"_id": id, "status": StatusDead}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return delivery, ErrNotFound
+	}
+	if err != nil {
+		return delivery, err
+	}
+	d.notify()
+	return delivery, nil
+}